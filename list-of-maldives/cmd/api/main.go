@@ -5,16 +5,12 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"list-of-maldives/internal/auth"
 	"list-of-maldives/internal/server"
-
-	"github.com/gorilla/sessions"
-	"github.com/markbates/goth/gothic"
 )
 
 func gracefulShutdown(apiServer *http.Server, done chan bool) {
@@ -42,22 +38,10 @@ func gracefulShutdown(apiServer *http.Server, done chan bool) {
 	done <- true
 }
 
-func initGothicSessionStore() {
-	sessionKey := os.Getenv("SESSION_SECRET")
-	if sessionKey == "" {
-		log.Fatal("SESSION_SECRET environment variable is not set. Cannot initialize session store.")
-	}
-
-	store := sessions.NewCookieStore([]byte(sessionKey))
-	store.MaxAge(86400 * 30)
-	store.Options.Path = "/"
-	store.Options.HttpOnly = true
-	gothic.Store = store
-}
-
 func main() {
-	auth.NewAuth()
-	initGothicSessionStore()
+	if err := auth.NewAuth(); err != nil {
+		log.Fatalf("failed to configure auth providers: %v", err)
+	}
 	server := server.NewServer()
 
 	// Create a done channel to signal when the shutdown is complete
@@ -4,44 +4,28 @@ import (
 	"log"
 	"os"
 
-	"github.com/gorilla/sessions"
 	"github.com/joho/godotenv"
-	"github.com/markbates/goth"
-	"github.com/markbates/goth/gothic"
-	"github.com/markbates/goth/providers/google"
-)
-
-const (
-	key    = "randomString"
-	MaxAge = 24 * 60 * 60 * 30
-	IsProd = false
 )
 
+// NewAuth registers the login providers. The session store gothic and the
+// OAuth flow keep their state in is set up by SessionStoreFromEnv.
 func NewAuth() error {
 	err := godotenv.Load()
 	if err != nil {
 		log.Fatal("Error loading .env file")
 	}
 
-	googleClientId := os.Getenv("GOOGLE_KEY")
-	googleClientSecret := os.Getenv("GOOGLE_SECRET")
 	backendURL := os.Getenv("BACKEND_URL")
 
-	store := sessions.NewCookieStore([]byte(key))
-	store.MaxAge(MaxAge)
-
-	store.Options.Path = "/"
-	store.Options.HttpOnly = true
-	store.Options.Secure = IsProd
-
-	gothic.Store = store
-	goth.UseProviders(
-		google.New(
-			googleClientId,
-			googleClientSecret,
-			backendURL+"/auth/google/callback",
-			"email", "profile",
-		),
-	)
+	// Providers come from AUTH_PROVIDERS_FILE, or Google from GOOGLE_KEY/GOOGLE_SECRET
+	providers := defaultProviders()
+	if path := os.Getenv("AUTH_PROVIDERS_FILE"); path != "" {
+		if providers, err = LoadProvidersFile(path); err != nil {
+			return err
+		}
+	}
+	if err := UseProviders(providers, backendURL); err != nil {
+		return err
+	}
 	return nil
 }
@@ -0,0 +1,25 @@
+package auth
+
+import "testing"
+
+func TestCSRFSigner(t *testing.T) {
+	signer, _ := NewCSRFSigner("secret")
+	token, err := signer.Generate("sid:1")
+	if err != nil {
+		t.Fatalf("error generating token. Err: %v", err)
+	}
+	if !signer.Valid(token, "sid:1") {
+		t.Errorf("expected issued token to be valid")
+	}
+	if signer.Valid(token, "sid:2") || signer.Valid(token, "") {
+		t.Errorf("expected token to be invalid for another session")
+	}
+
+	other, _ := NewCSRFSigner("")
+	forged, _ := other.Generate("sid:1")
+	for _, bad := range []string{"", "abc", "abc.", ".sig", forged, token + "x"} {
+		if signer.Valid(bad, "sid:1") {
+			t.Errorf("expected %q to be invalid", bad)
+		}
+	}
+}
@@ -6,64 +6,222 @@ import (
 	"os"
 	"time"
 
+	"list-of-maldives/internal/config"
+
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+// JWTService signs access tokens with the active key of its key set. Without
+// JWT_KEYS_DIR it falls back to HS256 with JWT_SECRET; when both are set the
+// secret is still accepted for tokens minted before the switch.
 type JWTService struct {
-	secretKey []byte
+	keys       *KeySet
+	secretKey  []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
 }
 
 type Claims struct {
 	UserID string `json:"user_id"`
 	Email  string `json:"email"`
+	// Purpose is empty for access tokens and names the action otherwise,
+	// so e.g. an email-verification token can't be used as an access token
+	Purpose string `json:"purpose,omitempty"`
+	// SessionID is the signed-in device an access token belongs to
+	SessionID string `json:"sid,omitempty"`
+	// Roles as of when the token was issued; sensitive routes re-check the DB
+	Roles []string `json:"roles,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Purposes of single-action tokens minted by GenerateActionToken
+const (
+	PurposeVerifyEmail  = "verify_email"
+	PurposeMFAChallenge = "mfa_challenge"
+	PurposeUnlock       = "unlock_account"
+	PurposeChangeEmail  = "change_email"
+)
+
+// Tokens are told apart by their typ header and audience, not just by the
+// purpose claim, so an action token is never mistaken for an access token
+// by this service or by anything else that trusts its keys
+const (
+	tokenIssuer     = "list-of-maldives"
+	accessTokenType = "at+jwt"
+	actionTokenType = "action+jwt"
+)
+
+// actionAudience is the aud claim of action tokens minted for purpose
+func actionAudience(purpose string) string {
+	return tokenIssuer + "/" + purpose
+}
+
 func NewJWTService() *JWTService {
+	var keys *KeySet
+	if dir := os.Getenv("JWT_KEYS_DIR"); dir != "" {
+		var err error
+		keys, err = LoadKeySet(dir, os.Getenv("JWT_ACTIVE_KID"))
+		if err != nil {
+			panic(fmt.Sprintf("failed to load JWT signing keys: %v", err))
+		}
+	}
+
 	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		panic("JWT_SECRET environment variable is required")
+	if secret == "" && keys == nil {
+		panic("JWT_SECRET or JWT_KEYS_DIR environment variable is required")
 	}
 	return &JWTService{
-		secretKey: []byte(secret),
+		keys:       keys,
+		secretKey:  []byte(secret),
+		accessTTL:  config.Duration("JWT_ACCESS_TTL", 15*time.Minute),
+		refreshTTL: config.Duration("JWT_REFRESH_TTL", 30*24*time.Hour),
 	}
 }
 
-// GenerateToken creates a new JWT token for a user
+// AccessTTL is the lifetime of access tokens minted by GenerateToken
+func (j *JWTService) AccessTTL() time.Duration {
+	return j.accessTTL
+}
+
+// RefreshTTL is the lifetime of the opaque refresh tokens paired with access tokens
+func (j *JWTService) RefreshTTL() time.Duration {
+	return j.refreshTTL
+}
+
+// GenerateToken creates a new short-lived JWT access token for a user
 func (j *JWTService) GenerateToken(userID, email string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour) // Token expires in 24 hours
+	return j.GenerateSessionToken(userID, email, "", nil)
+}
+
+// GenerateSessionToken is GenerateToken for a token tied to a session (sid
+// claim) that carries the user's roles
+func (j *JWTService) GenerateSessionToken(userID, email, sessionID string, roles []string) (string, error) {
+	expirationTime := time.Now().Add(j.accessTTL)
 
 	claims := &Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:    userID,
+		Email:     email,
+		SessionID: sessionID,
+		Roles:     roles,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "list-of-maldives",
+			Issuer:    tokenIssuer,
+			Audience:  jwt.ClaimStrings{tokenIssuer},
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(j.secretKey)
+	return j.sign(claims, accessTokenType)
 }
 
-// ValidateToken validates the JWT token and returns the claims
-func (j *JWTService) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+// sign uses the active asymmetric key, or the shared secret when none is
+// configured, and sets the typ header to typ
+func (j *JWTService) sign(claims jwt.Claims, typ string) (string, error) {
+	if j.keys == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		token.Header["typ"] = typ
+		return token.SignedString(j.secretKey)
+	}
+
+	active := j.keys.Active()
+	token := jwt.NewWithClaims(active.Method, claims)
+	token.Header["typ"] = typ
+	token.Header["kid"] = active.ID
+	return token.SignedString(active.Private)
+}
+
+// parse validates tokenString's signature, issuer and audience and checks
+// that it carries the typ header typ
+func (j *JWTService) parse(tokenString, typ, audience string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, j.keyFunc,
+		jwt.WithIssuer(tokenIssuer), jwt.WithAudience(audience))
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid || token.Header["typ"] != typ {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return claims, nil
+}
+
+// keyFunc resolves the verification key from the token's kid header and
+// refuses tokens whose alg doesn't match that key
+func (j *JWTService) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || len(j.secretKey) == 0 {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return j.secretKey, nil
-	})
+	}
 
+	if j.keys == nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	key, ok := j.keys.Lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.Public, nil
+}
+
+// JWKS returns the public verification keys; it is empty in HS256 mode
+func (j *JWTService) JWKS() JWKSet {
+	if j.keys == nil {
+		return JWKSet{Keys: []JWK{}}
+	}
+	return j.keys.JWKS()
+}
+
+// ValidateToken validates the JWT token and returns the claims
+func (j *JWTService) ValidateToken(tokenString string) (*Claims, error) {
+	claims, err := j.parse(tokenString, accessTokenType, tokenIssuer)
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	if claims.Purpose != "" {
+		return nil, fmt.Errorf("invalid token")
 	}
+	return claims, nil
+}
 
-	return nil, fmt.Errorf("invalid token")
+// GenerateActionToken signs a short-lived token that only authorizes purpose
+func (j *JWTService) GenerateActionToken(purpose, userID, email string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		UserID:  userID,
+		Email:   email,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    tokenIssuer,
+			Audience:  jwt.ClaimStrings{actionAudience(purpose)},
+		},
+	}
+	return j.sign(claims, actionTokenType)
+}
+
+// ValidateActionToken validates a token minted by GenerateActionToken for purpose
+func (j *JWTService) ValidateActionToken(tokenString, purpose string) (*Claims, error) {
+	claims, err := j.parse(tokenString, actionTokenType, actionAudience(purpose))
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Purpose != purpose {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return claims, nil
 }
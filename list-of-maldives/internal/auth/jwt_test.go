@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestTokenTypesAreNotInterchangeable(t *testing.T) {
+	j := &JWTService{secretKey: []byte("secret"), accessTTL: time.Minute}
+
+	access, err := j.GenerateToken("user-1", "user@example.com")
+	if err != nil {
+		t.Fatalf("error generating access token. Err: %v", err)
+	}
+	if _, err := j.ValidateToken(access); err != nil {
+		t.Fatalf("expected access token to validate. Err: %v", err)
+	}
+	if _, err := j.ValidateActionToken(access, PurposeMFAChallenge); err == nil {
+		t.Errorf("expected access token to be rejected as an action token")
+	}
+
+	action, err := j.GenerateActionToken(PurposeMFAChallenge, "user-1", "user@example.com", time.Minute)
+	if err != nil {
+		t.Fatalf("error generating action token. Err: %v", err)
+	}
+	if _, err := j.ValidateActionToken(action, PurposeMFAChallenge); err != nil {
+		t.Fatalf("expected action token to validate. Err: %v", err)
+	}
+	if _, err := j.ValidateToken(action); err == nil {
+		t.Errorf("expected action token to be rejected as an access token")
+	}
+	if _, err := j.ValidateActionToken(action, PurposeVerifyEmail); err == nil {
+		t.Errorf("expected action token to be rejected for another purpose")
+	}
+}
+
+func TestValidateTokenRequiresTypeAndAudience(t *testing.T) {
+	j := &JWTService{secretKey: []byte("secret"), accessTTL: time.Minute}
+	now := time.Now()
+	registered := jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		Issuer:    tokenIssuer,
+	}
+
+	// Signed with the right key but missing the aud claim
+	token, _ := j.sign(&Claims{UserID: "user-1", RegisteredClaims: registered}, accessTokenType)
+	if _, err := j.ValidateToken(token); err == nil {
+		t.Errorf("expected token without audience to be rejected")
+	}
+
+	// Right audience but an action token's typ header
+	registered.Audience = jwt.ClaimStrings{tokenIssuer}
+	token, _ = j.sign(&Claims{UserID: "user-1", RegisteredClaims: registered}, actionTokenType)
+	if _, err := j.ValidateToken(token); err == nil {
+		t.Errorf("expected token with action typ to be rejected")
+	}
+}
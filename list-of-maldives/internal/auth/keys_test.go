@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writePEM(t *testing.T, dir, kid, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600); err != nil {
+		t.Fatalf("error writing key. Err: %v", err)
+	}
+}
+
+func writePrivateKey(t *testing.T, dir, kid string, key crypto.Signer) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("error marshaling key. Err: %v", err)
+	}
+	writePEM(t, dir, kid, "PRIVATE KEY", der)
+}
+
+func TestKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	writePrivateKey(t, dir, "rsa-1", rsaKey)
+	writePrivateKey(t, dir, "ec-1", ecKey)
+	writePrivateKey(t, dir, "ed-1", edKey)
+
+	var tokens []string
+	for _, kid := range []string{"rsa-1", "ec-1", "ed-1"} {
+		keys, err := LoadKeySet(dir, kid)
+		if err != nil {
+			t.Fatalf("error loading key set. Err: %v", err)
+		}
+		j := &JWTService{keys: keys, accessTTL: time.Minute}
+		token, err := j.GenerateToken("user-1", "user@example.com")
+		if err != nil {
+			t.Fatalf("error signing with %s. Err: %v", kid, err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	// Retire rsa-1: only its public key remains, ed-1 becomes active
+	if err := os.Remove(filepath.Join(dir, "rsa-1.pem")); err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	writePEM(t, dir, "rsa-1", "PUBLIC KEY", pub)
+
+	keys, err := LoadKeySet(dir, "ed-1")
+	if err != nil {
+		t.Fatalf("error loading rotated key set. Err: %v", err)
+	}
+	j := &JWTService{keys: keys, accessTTL: time.Minute}
+	for i, token := range tokens {
+		claims, err := j.ValidateToken(token)
+		if err != nil {
+			t.Fatalf("token %d rejected after rotation. Err: %v", i, err)
+		}
+		if claims.UserID != "user-1" {
+			t.Errorf("expected user-1; got %v", claims.UserID)
+		}
+	}
+
+	if _, err := LoadKeySet(dir, "rsa-1"); err == nil {
+		t.Errorf("expected a public-only key to be refused as active key")
+	}
+
+	jwks := j.JWKS()
+	if len(jwks.Keys) != 3 {
+		t.Fatalf("expected 3 keys in JWKS; got %d", len(jwks.Keys))
+	}
+	expected := map[string]string{"ec-1": "ES256", "ed-1": "EdDSA", "rsa-1": "RS256"}
+	for _, key := range jwks.Keys {
+		if expected[key.Kid] != key.Alg {
+			t.Errorf("expected %s to use %s; got %s", key.Kid, expected[key.Kid], key.Alg)
+		}
+	}
+}
+
+func TestRejectsUnknownKidAndAlgConfusion(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	active, _ := NewSigningKey("ec-1", ecKey)
+	keys, _ := NewKeySet(active)
+	j := &JWTService{keys: keys, accessTTL: time.Minute}
+
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	other, _ := NewSigningKey("ec-2", otherKey)
+	otherKeys, _ := NewKeySet(other)
+	foreign := &JWTService{keys: otherKeys, accessTTL: time.Minute}
+	token, _ := foreign.GenerateToken("user-1", "user@example.com")
+	if _, err := j.ValidateToken(token); err == nil {
+		t.Errorf("expected token signed with unknown kid to be rejected")
+	}
+
+	// HS256 token without kid must not validate when no secret is configured
+	legacy := &JWTService{secretKey: []byte("secret"), accessTTL: time.Minute}
+	token, _ = legacy.GenerateToken("user-1", "user@example.com")
+	if _, err := j.ValidateToken(token); err == nil {
+		t.Errorf("expected HS256 token to be rejected without JWT_SECRET")
+	}
+}
@@ -0,0 +1,56 @@
+// auth/lockout.go
+package auth
+
+import (
+	"list-of-maldives/internal/config"
+	"time"
+)
+
+// LockoutPolicy decides how long a login key (an account or a client IP) is
+// blocked after consecutive failed attempts
+type LockoutPolicy struct {
+	// FreeAttempts failures are allowed before any delay applies
+	FreeAttempts int
+	// Each further failure doubles the delay, starting at BaseDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// At LockoutThreshold failures the key is locked for LockoutDuration;
+	// zero disables lockout
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// Failures older than Window are forgotten
+	Window time.Duration
+}
+
+// LockoutPolicyFromEnv reads <prefix>_FREE_ATTEMPTS, _BASE_DELAY, _MAX_DELAY,
+// _LOCKOUT_THRESHOLD, _LOCKOUT_DURATION and _WINDOW over defaults
+func LockoutPolicyFromEnv(prefix string, defaults LockoutPolicy) LockoutPolicy {
+	return LockoutPolicy{
+		FreeAttempts:     config.Int(prefix+"_FREE_ATTEMPTS", defaults.FreeAttempts),
+		BaseDelay:        config.Duration(prefix+"_BASE_DELAY", defaults.BaseDelay),
+		MaxDelay:         config.Duration(prefix+"_MAX_DELAY", defaults.MaxDelay),
+		LockoutThreshold: config.Int(prefix+"_LOCKOUT_THRESHOLD", defaults.LockoutThreshold),
+		LockoutDuration:  config.Duration(prefix+"_LOCKOUT_DURATION", defaults.LockoutDuration),
+		Window:           config.Duration(prefix+"_WINDOW", defaults.Window),
+	}
+}
+
+// BlockFor returns how long to refuse attempts after the given number of
+// consecutive failures, and whether that is a lockout rather than a delay
+func (p LockoutPolicy) BlockFor(failures int) (time.Duration, bool) {
+	if p.LockoutThreshold > 0 && failures >= p.LockoutThreshold {
+		return p.LockoutDuration, true
+	}
+	if failures <= p.FreeAttempts {
+		return 0, false
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay, false
+		}
+	}
+	return min(delay, p.MaxDelay), false
+}
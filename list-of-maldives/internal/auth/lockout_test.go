@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutPolicyBlockFor(t *testing.T) {
+	p := LockoutPolicy{
+		FreeAttempts:     3,
+		BaseDelay:        time.Second,
+		MaxDelay:         10 * time.Second,
+		LockoutThreshold: 10,
+		LockoutDuration:  30 * time.Minute,
+	}
+
+	cases := []struct {
+		failures int
+		delay    time.Duration
+		locked   bool
+	}{
+		{1, 0, false},
+		{3, 0, false},
+		{4, time.Second, false},
+		{5, 2 * time.Second, false},
+		{7, 8 * time.Second, false},
+		{8, 10 * time.Second, false},
+		{9, 10 * time.Second, false},
+		{10, 30 * time.Minute, true},
+		{42, 30 * time.Minute, true},
+	}
+	for _, c := range cases {
+		delay, locked := p.BlockFor(c.failures)
+		if delay != c.delay || locked != c.locked {
+			t.Errorf("after %d failures expected (%s, %t); got (%s, %t)", c.failures, c.delay, c.locked, delay, locked)
+		}
+	}
+
+	p.LockoutThreshold = 0
+	if _, locked := p.BlockFor(1000); locked {
+		t.Errorf("expected no lockout when the threshold is zero")
+	}
+}
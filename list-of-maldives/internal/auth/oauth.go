@@ -0,0 +1,319 @@
+// auth/oauth.go
+package auth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/gothic"
+	"github.com/markbates/goth/providers/azureadv2"
+	"github.com/markbates/goth/providers/github"
+	"github.com/markbates/goth/providers/google"
+	"github.com/markbates/goth/providers/okta"
+	"github.com/markbates/goth/providers/openidConnect"
+)
+
+// oauthSessionName holds the in-flight authorization request between the
+// redirect to the provider and its callback
+const oauthSessionName = "_oauth_flow"
+
+// oauthFlowTTL is how long the user has to finish signing in at the provider
+const oauthFlowTTL = 10 * time.Minute
+
+var (
+	ErrOAuthNoFlow        = errors.New("no OAuth flow in progress for this browser")
+	ErrOAuthStateMismatch = errors.New("OAuth state does not match this browser's flow")
+	ErrOAuthNonceMismatch = errors.New("ID token nonce does not match this browser's flow")
+	ErrOAuthNoIDToken     = errors.New("provider returned no ID token to check the nonce against")
+)
+
+// OAuthFlow runs the authorization code flow for the goth providers with a
+// random state bound to the browser session, PKCE (S256) and, for OpenID
+// Connect providers, a nonce checked against the returned ID token
+type OAuthFlow struct {
+	// Store keeps the flow between Begin and Complete; gothic.Store when nil
+	Store sessions.Store
+}
+
+func (f *OAuthFlow) store() sessions.Store {
+	if f.Store != nil {
+		return f.Store
+	}
+	return gothic.Store
+}
+
+// randomToken returns n random bytes as unpadded base64url
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// pkceChallenge is the S256 code challenge for verifier (RFC 7636)
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// issuesIDTokens reports whether a configured provider speaks OpenID Connect
+func issuesIDTokens(providerName string) bool {
+	switch registered[providerName].Type {
+	case "google", "okta", "azureadv2", "oidc", "openidConnect":
+		return true
+	}
+	return false
+}
+
+// Begin starts a login with providerName and returns the provider URL to
+// redirect the browser to. Any ?state= on r is ignored.
+func (f *OAuthFlow) Begin(w http.ResponseWriter, r *http.Request, providerName string) (string, error) {
+	provider, err := goth.GetProvider(providerName)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	var nonce string
+	if issuesIDTokens(providerName) {
+		if nonce, err = randomToken(32); err != nil {
+			return "", err
+		}
+	}
+
+	sess, err := provider.BeginAuth(state)
+	if err != nil {
+		return "", err
+	}
+	rawAuthURL, err := sess.GetAuthURL()
+	if err != nil {
+		return "", err
+	}
+	authURL, err := url.Parse(rawAuthURL)
+	if err != nil {
+		return "", err
+	}
+	query := authURL.Query()
+	query.Set("code_challenge", pkceChallenge(verifier))
+	query.Set("code_challenge_method", "S256")
+	if nonce != "" {
+		query.Set("nonce", nonce)
+	}
+	authURL.RawQuery = query.Encode()
+
+	session, _ := f.store().New(r, oauthSessionName)
+	session.Values = map[interface{}]interface{}{
+		"provider": providerName,
+		"state":    state,
+		"verifier": verifier,
+		"nonce":    nonce,
+		"session":  sess.Marshal(),
+		"expires":  time.Now().Add(oauthFlowTTL).Unix(),
+	}
+	session.Options.MaxAge = int(oauthFlowTTL.Seconds())
+	if err := session.Save(r, w); err != nil {
+		return "", err
+	}
+	return authURL.String(), nil
+}
+
+// Complete finishes the flow Begin started in this browser: it checks the
+// state, redeems the code with the PKCE verifier, checks the ID token nonce
+// and fetches the user. The flow is single use, whatever the outcome.
+func (f *OAuthFlow) Complete(w http.ResponseWriter, r *http.Request, providerName string) (goth.User, error) {
+	session, err := f.store().Get(r, oauthSessionName)
+	if err != nil || session.IsNew {
+		return goth.User{}, ErrOAuthNoFlow
+	}
+	values := session.Values
+	session.Values = map[interface{}]interface{}{}
+	session.Options.MaxAge = -1
+	session.Save(r, w)
+
+	flowProvider, _ := values["provider"].(string)
+	state, _ := values["state"].(string)
+	verifier, _ := values["verifier"].(string)
+	nonce, _ := values["nonce"].(string)
+	marshalled, _ := values["session"].(string)
+	expires, _ := values["expires"].(int64)
+	if flowProvider != providerName || state == "" || time.Now().Unix() > expires {
+		return goth.User{}, ErrOAuthNoFlow
+	}
+
+	params := r.URL.Query()
+	if r.Method == http.MethodPost {
+		r.ParseForm()
+		params = r.Form
+	}
+	if subtle.ConstantTimeCompare([]byte(params.Get("state")), []byte(state)) != 1 {
+		return goth.User{}, ErrOAuthStateMismatch
+	}
+	if code := params.Get("error"); code != "" {
+		return goth.User{}, fmt.Errorf("provider returned %s: %s", code, params.Get("error_description"))
+	}
+
+	provider, err := goth.GetProvider(providerName)
+	if err != nil {
+		return goth.User{}, err
+	}
+	sess, err := provider.UnmarshalSession(marshalled)
+	if err != nil {
+		return goth.User{}, err
+	}
+
+	exchange := &exchangeTransport{verifier: verifier}
+	provider = withExchange(provider, exchange)
+	if _, err := sess.Authorize(provider, params); err != nil {
+		return goth.User{}, err
+	}
+
+	// A nonce was only sent to providers that issue ID tokens, so a
+	// response without one can't be checked and is refused
+	if nonce != "" {
+		if exchange.idToken == "" {
+			return goth.User{}, ErrOAuthNoIDToken
+		}
+		if err := checkNonce(exchange.idToken, nonce); err != nil {
+			return goth.User{}, err
+		}
+	}
+
+	return provider.FetchUser(sess)
+}
+
+// checkNonce compares the nonce claim of an ID token fetched directly from
+// the provider's token endpoint with the one sent in the authorization request
+func checkNonce(idToken, nonce string) error {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed ID token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("malformed ID token: %w", err)
+	}
+	var claims struct {
+		Nonce string `json:"nonce"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return fmt.Errorf("malformed ID token: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return ErrOAuthNonceMismatch
+	}
+	return nil
+}
+
+// withExchange returns a copy of provider whose HTTP client goes through t.
+// goth has no hook for extra token request parameters, so this is how the
+// code verifier reaches the token endpoint.
+func withExchange(provider goth.Provider, t *exchangeTransport) goth.Provider {
+	wrap := func(c *http.Client) *http.Client {
+		wrapped := &http.Client{Transport: t}
+		t.base = http.DefaultTransport
+		if c != nil {
+			wrapped.Timeout = c.Timeout
+			if c.Transport != nil {
+				t.base = c.Transport
+			}
+		}
+		return wrapped
+	}
+
+	switch p := provider.(type) {
+	case *google.Provider:
+		cp := *p
+		cp.HTTPClient = wrap(p.HTTPClient)
+		return &cp
+	case *github.Provider:
+		cp := *p
+		cp.HTTPClient = wrap(p.HTTPClient)
+		return &cp
+	case *okta.Provider:
+		cp := *p
+		cp.HTTPClient = wrap(p.HTTPClient)
+		return &cp
+	case *azureadv2.Provider:
+		cp := *p
+		cp.HTTPClient = wrap(p.HTTPClient)
+		return &cp
+	case *openidConnect.Provider:
+		cp := *p
+		cp.HTTPClient = wrap(p.HTTPClient)
+		return &cp
+	}
+	return provider
+}
+
+// exchangeTransport adds the PKCE code verifier to authorization code token
+// requests and remembers the ID token in the response
+type exchangeTransport struct {
+	base     http.RoundTripper
+	verifier string
+	idToken  string
+}
+
+func (t *exchangeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPost || req.Body == nil {
+		return t.base.RoundTrip(req)
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil || form.Get("grant_type") != "authorization_code" {
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		return t.base.RoundTrip(req)
+	}
+
+	form.Set("code_verifier", t.verifier)
+	encoded := form.Encode()
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(strings.NewReader(encoded))
+	req.ContentLength = int64(len(encoded))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if json.Unmarshal(respBody, &token) == nil {
+		t.idToken = token.IDToken
+	} else if values, err := url.ParseQuery(string(respBody)); err == nil {
+		t.idToken = values.Get("id_token")
+	}
+	return resp, nil
+}
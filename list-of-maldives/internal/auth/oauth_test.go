@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// fakeOIDC is an OpenID Connect provider that issues one code per
+// authorization request and insists on the matching PKCE verifier
+type fakeOIDC struct {
+	*httptest.Server
+	mu         sync.Mutex
+	requests   map[string]url.Values // code -> authorization request
+	wrongNonce bool
+	noIDToken  bool
+}
+
+func newFakeOIDC(t *testing.T) *fakeOIDC {
+	t.Helper()
+	f := &fakeOIDC{requests: map[string]url.Values{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 f.URL,
+			"authorization_endpoint": f.URL + "/authorize",
+			"token_endpoint":         f.URL + "/token",
+		})
+	})
+	mux.HandleFunc("/token", f.token)
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+
+	err := UseProviders([]ProviderConfig{{
+		Name:         "fake",
+		Type:         "oidc",
+		ClientID:     "client",
+		ClientSecret: "secret",
+		DiscoveryURL: f.URL + "/.well-known/openid-configuration",
+	}}, "http://backend.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// authorize plays the user approving the request at the provider
+func (f *fakeOIDC) authorize(t *testing.T, authURL string) (code string, query url.Values) {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query = u.Query()
+	code = "code-" + query.Get("state")[:8]
+	f.mu.Lock()
+	f.requests[code] = query
+	f.mu.Unlock()
+	return code, query
+}
+
+func (f *fakeOIDC) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	f.mu.Lock()
+	req, ok := f.requests[r.Form.Get("code")]
+	delete(f.requests, r.Form.Get("code"))
+	f.mu.Unlock()
+
+	if !ok || r.Form.Get("grant_type") != "authorization_code" ||
+		req.Get("code_challenge_method") != "S256" ||
+		pkceChallenge(r.Form.Get("code_verifier")) != req.Get("code_challenge") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	nonce := req.Get("nonce")
+	if f.wrongNonce {
+		nonce = "somebody-elses-nonce"
+	}
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   f.URL,
+		"aud":   "client",
+		"sub":   "subject-1",
+		"email": "user@example.com",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": nonce,
+	})
+	response := map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+	}
+	if !f.noIDToken {
+		response["id_token"] = "e30." + base64.RawURLEncoding.EncodeToString(claims) + ".sig"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// begin starts a flow and returns the provider URL and the browser's cookies
+func begin(t *testing.T, flow *OAuthFlow, target string) (string, []*http.Cookie) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	authURL, err := flow.Begin(rec, httptest.NewRequest(http.MethodGet, target, nil), "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authURL, rec.Result().Cookies()
+}
+
+func callback(flow *OAuthFlow, query url.Values, cookies []*http.Cookie) error {
+	r := httptest.NewRequest(http.MethodGet, "/auth/fake/callback?"+query.Encode(), nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	_, err := flow.Complete(httptest.NewRecorder(), r, "fake")
+	return err
+}
+
+func newTestFlow() *OAuthFlow {
+	return &OAuthFlow{Store: sessions.NewCookieStore([]byte("0123456789abcdef0123456789abcdef"))}
+}
+
+func TestOAuthFlowPKCEAndNonce(t *testing.T) {
+	provider := newFakeOIDC(t)
+	flow := newTestFlow()
+
+	// A state smuggled into the begin request must not be used
+	authURL, cookies := begin(t, flow, "/auth/fake?state=attacker-chosen")
+	code, query := provider.authorize(t, authURL)
+	if query.Get("state") == "attacker-chosen" || len(query.Get("state")) < 32 {
+		t.Fatalf("state %q is not freshly generated", query.Get("state"))
+	}
+	if query.Get("code_challenge") == "" || query.Get("nonce") == "" {
+		t.Fatalf("missing PKCE challenge or nonce in %s", authURL)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/auth/fake/callback?"+url.Values{
+		"code": {code}, "state": {query.Get("state")},
+	}.Encode(), nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	user, err := flow.Complete(httptest.NewRecorder(), r, "fake")
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if user.UserID != "subject-1" || user.Email != "user@example.com" {
+		t.Fatalf("unexpected user %+v", user)
+	}
+}
+
+func TestOAuthFlowRejectsWrongState(t *testing.T) {
+	provider := newFakeOIDC(t)
+	flow := newTestFlow()
+
+	authURL, cookies := begin(t, flow, "/auth/fake")
+	code, _ := provider.authorize(t, authURL)
+
+	err := callback(flow, url.Values{"code": {code}, "state": {"forged"}}, cookies)
+	if !errors.Is(err, ErrOAuthStateMismatch) {
+		t.Fatalf("expected state mismatch, got %v", err)
+	}
+}
+
+func TestOAuthFlowRejectsOtherBrowser(t *testing.T) {
+	provider := newFakeOIDC(t)
+	flow := newTestFlow()
+
+	// A callback URL for someone else's flow, opened without their cookies
+	authURL, _ := begin(t, flow, "/auth/fake")
+	code, query := provider.authorize(t, authURL)
+
+	err := callback(flow, url.Values{"code": {code}, "state": {query.Get("state")}}, nil)
+	if !errors.Is(err, ErrOAuthNoFlow) {
+		t.Fatalf("expected no flow, got %v", err)
+	}
+}
+
+func TestOAuthFlowRejectsWrongVerifier(t *testing.T) {
+	provider := newFakeOIDC(t)
+	flow := newTestFlow()
+
+	// The code was issued to a different flow, so this browser's verifier can't redeem it
+	otherURL, _ := begin(t, flow, "/auth/fake")
+	code, _ := provider.authorize(t, otherURL)
+	authURL, cookies := begin(t, flow, "/auth/fake")
+	_, query := provider.authorize(t, authURL)
+
+	if err := callback(flow, url.Values{"code": {code}, "state": {query.Get("state")}}, cookies); err == nil {
+		t.Fatal("expected the token endpoint to refuse the code")
+	}
+}
+
+func TestOAuthFlowRejectsWrongNonce(t *testing.T) {
+	provider := newFakeOIDC(t)
+	provider.wrongNonce = true
+	flow := newTestFlow()
+
+	authURL, cookies := begin(t, flow, "/auth/fake")
+	code, query := provider.authorize(t, authURL)
+
+	err := callback(flow, url.Values{"code": {code}, "state": {query.Get("state")}}, cookies)
+	if !errors.Is(err, ErrOAuthNonceMismatch) {
+		t.Fatalf("expected nonce mismatch, got %v", err)
+	}
+}
+
+func TestOAuthFlowRequiresIDTokenForNonce(t *testing.T) {
+	provider := newFakeOIDC(t)
+	provider.noIDToken = true
+	flow := newTestFlow()
+
+	authURL, cookies := begin(t, flow, "/auth/fake")
+	code, query := provider.authorize(t, authURL)
+
+	err := callback(flow, url.Values{"code": {code}, "state": {query.Get("state")}}, cookies)
+	if !errors.Is(err, ErrOAuthNoIDToken) {
+		t.Fatalf("expected missing ID token to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+// Package passkeytest is a software WebAuthn authenticator for tests
+package passkeytest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+)
+
+// Authenticator is a minimal software passkey: one ES256 credential,
+// "none" attestation, resident user handle
+type Authenticator struct {
+	key          *ecdsa.PrivateKey
+	CredentialID []byte
+	Handle       []byte
+	// Counter is the signature counter; each assertion increments it
+	Counter uint32
+}
+
+func New(t *testing.T) *Authenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credID := make([]byte, 16)
+	rand.Read(credID)
+	return &Authenticator{key: key, CredentialID: credID}
+}
+
+// CBOR helpers covering the handful of types WebAuthn needs
+func cborHead(major byte, n int) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 256:
+		return []byte{major<<5 | 24, byte(n)}
+	default:
+		return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+	}
+}
+
+func cborBytes(b []byte) []byte { return append(cborHead(2, len(b)), b...) }
+func cborText(s string) []byte  { return append(cborHead(3, len(s)), s...) }
+
+func cborInt(n int) []byte {
+	if n < 0 {
+		return cborHead(1, -1-n)
+	}
+	return cborHead(0, n)
+}
+
+func (a *Authenticator) coseKey() []byte {
+	x := a.key.PublicKey.X.FillBytes(make([]byte, 32))
+	y := a.key.PublicKey.Y.FillBytes(make([]byte, 32))
+
+	var key []byte
+	key = append(key, cborHead(5, 5)...)
+	key = append(key, cborInt(1)...)
+	key = append(key, cborInt(2)...) // kty: EC2
+	key = append(key, cborInt(3)...)
+	key = append(key, cborInt(-7)...) // alg: ES256
+	key = append(key, cborInt(-1)...)
+	key = append(key, cborInt(1)...) // crv: P-256
+	key = append(key, cborInt(-2)...)
+	key = append(key, cborBytes(x)...)
+	key = append(key, cborInt(-3)...)
+	key = append(key, cborBytes(y)...)
+	return key
+}
+
+func (a *Authenticator) authData(rpID string, attested bool) []byte {
+	rpHash := sha256.Sum256([]byte(rpID))
+	flags := byte(0x01 | 0x04) // user present, user verified
+
+	data := append([]byte{}, rpHash[:]...)
+	if attested {
+		flags |= 0x40
+	}
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.Counter)
+	if attested {
+		data = append(data, make([]byte, 16)...) // AAGUID
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.CredentialID)))
+		data = append(data, a.CredentialID...)
+		data = append(data, a.coseKey()...)
+	}
+	return data
+}
+
+func clientData(typ, challenge, origin string) []byte {
+	data, _ := json.Marshal(map[string]string{"type": typ, "challenge": challenge, "origin": origin})
+	return data
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+// Create answers a registration challenge for the user with handle, as
+// the PublicKeyCredential JSON a browser would send
+func (a *Authenticator) Create(t *testing.T, rpID, challenge, origin string, handle []byte) []byte {
+	t.Helper()
+	a.Handle = handle
+
+	var att []byte
+	att = append(att, cborHead(5, 3)...)
+	att = append(att, cborText("fmt")...)
+	att = append(att, cborText("none")...)
+	att = append(att, cborText("attStmt")...)
+	att = append(att, cborHead(5, 0)...)
+	att = append(att, cborText("authData")...)
+	att = append(att, cborBytes(a.authData(rpID, true))...)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"id":    b64(a.CredentialID),
+		"rawId": b64(a.CredentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64(clientData("webauthn.create", challenge, origin)),
+			"attestationObject": b64(att),
+		},
+	})
+	return body
+}
+
+// Get answers a login challenge with a signed assertion
+func (a *Authenticator) Get(t *testing.T, rpID, challenge, origin string) []byte {
+	t.Helper()
+	a.Counter++
+
+	authData := a.authData(rpID, false)
+	cdj := clientData("webauthn.get", challenge, origin)
+	cdHash := sha256.Sum256(cdj)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), cdHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"id":    b64(a.CredentialID),
+		"rawId": b64(a.CredentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64(cdj),
+			"authenticatorData": b64(authData),
+			"signature":         b64(sig),
+			"userHandle":        b64(a.Handle),
+		},
+	})
+	return body
+}
@@ -0,0 +1,250 @@
+// auth/providers.go
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/azureadv2"
+	"github.com/markbates/goth/providers/github"
+	"github.com/markbates/goth/providers/google"
+	"github.com/markbates/goth/providers/okta"
+	"github.com/markbates/goth/providers/openidConnect"
+	"gopkg.in/yaml.v3"
+)
+
+// ProviderConfig describes one OAuth/OIDC login provider in the providers file.
+// ClientID and ClientSecret may reference env vars as ${NAME} so secrets stay
+// out of the file.
+type ProviderConfig struct {
+	Name         string   `json:"name" yaml:"name"`
+	Type         string   `json:"type" yaml:"type"`
+	DisplayName  string   `json:"display_name" yaml:"display_name"`
+	ClientID     string   `json:"client_id" yaml:"client_id"`
+	ClientSecret string   `json:"client_secret" yaml:"client_secret"`
+	CallbackURL  string   `json:"callback_url" yaml:"callback_url"`
+	Scopes       []string `json:"scopes" yaml:"scopes"`
+	// TrustEmail treats every email from this provider as verified, for
+	// identity providers that don't send an email_verified claim
+	TrustEmail bool `json:"trust_email" yaml:"trust_email"`
+
+	// oidc: the issuer's .well-known/openid-configuration URL
+	DiscoveryURL string `json:"discovery_url" yaml:"discovery_url"`
+	// okta: the organisation URL, e.g. https://example.okta.com
+	OrgURL string `json:"org_url" yaml:"org_url"`
+	// azureadv2: common, organizations, consumers or a tenant ID
+	Tenant string `json:"tenant" yaml:"tenant"`
+}
+
+// ProvidersFile is the top-level document read from AUTH_PROVIDERS_FILE
+type ProvidersFile struct {
+	Providers []ProviderConfig `json:"providers" yaml:"providers"`
+}
+
+// ProviderInfo is the public description of a provider, used for login buttons
+type ProviderInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Type        string `json:"type"`
+	LoginURL    string `json:"login_url"`
+}
+
+// registered holds the providers configured at startup, keyed by name
+var registered = map[string]ProviderConfig{}
+
+// providerNamePattern keeps names usable as a single /auth/{provider} path segment
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// reservedProviderNames are the /auth routes matched before /auth/{provider};
+// a provider with one of these names could never be reached
+var reservedProviderNames = map[string]bool{
+	"me": true, "providers": true, "csrf": true, "verify-email": true,
+	"email": true, "password": true, "unlock": true, "mfa": true,
+	"webauthn": true, "magic-link": true, "register": true, "login": true,
+	"refresh": true, "log-out": true,
+}
+
+// LoadProvidersFile parses a YAML or JSON providers file (by extension)
+func LoadProvidersFile(path string) ([]ProviderConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file ProvidersFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	// Only the credentials are expanded, so a $ elsewhere (e.g. in a URL) is kept
+	for i := range file.Providers {
+		file.Providers[i].ClientID = os.ExpandEnv(file.Providers[i].ClientID)
+		file.Providers[i].ClientSecret = os.ExpandEnv(file.Providers[i].ClientSecret)
+	}
+	return file.Providers, nil
+}
+
+// defaultProviders keeps the env-only Google setup working without a providers file
+func defaultProviders() []ProviderConfig {
+	if os.Getenv("GOOGLE_KEY") == "" {
+		return nil
+	}
+	return []ProviderConfig{{
+		Name:         "google",
+		Type:         "google",
+		DisplayName:  "Google",
+		ClientID:     os.Getenv("GOOGLE_KEY"),
+		ClientSecret: os.Getenv("GOOGLE_SECRET"),
+		Scopes:       []string{"email", "profile"},
+	}}
+}
+
+// UseProviders builds goth providers from configs and registers them with goth
+func UseProviders(configs []ProviderConfig, backendURL string) error {
+	var providers []goth.Provider
+	seen := map[string]ProviderConfig{}
+
+	for _, cfg := range configs {
+		if cfg.Name == "" {
+			return fmt.Errorf("provider without a name")
+		}
+		if !providerNamePattern.MatchString(cfg.Name) {
+			return fmt.Errorf("provider name %q must be lowercase letters, digits, - and _", cfg.Name)
+		}
+		if reservedProviderNames[cfg.Name] {
+			return fmt.Errorf("provider name %q is reserved by the /auth/%s route", cfg.Name, cfg.Name)
+		}
+		if _, dup := seen[cfg.Name]; dup {
+			return fmt.Errorf("provider %q configured twice", cfg.Name)
+		}
+		if cfg.Type == "" {
+			cfg.Type = cfg.Name
+		}
+		if cfg.DisplayName == "" {
+			cfg.DisplayName = cfg.Name
+		}
+		if cfg.CallbackURL == "" {
+			cfg.CallbackURL = backendURL + "/auth/" + cfg.Name + "/callback"
+		}
+
+		provider, err := newProvider(cfg)
+		if err != nil {
+			return fmt.Errorf("provider %q: %w", cfg.Name, err)
+		}
+		providers = append(providers, provider)
+		seen[cfg.Name] = cfg
+	}
+
+	goth.ClearProviders()
+	goth.UseProviders(providers...)
+	registered = seen
+	return nil
+}
+
+func newProvider(cfg ProviderConfig) (goth.Provider, error) {
+	var provider interface {
+		goth.Provider
+		SetName(name string)
+	}
+
+	switch cfg.Type {
+	case "google":
+		provider = google.New(cfg.ClientID, cfg.ClientSecret, cfg.CallbackURL, withOpenID(cfg.Scopes, "email")...)
+	case "github":
+		provider = github.New(cfg.ClientID, cfg.ClientSecret, cfg.CallbackURL, cfg.Scopes...)
+	case "okta":
+		if cfg.OrgURL == "" {
+			return nil, fmt.Errorf("okta requires org_url")
+		}
+		provider = okta.New(cfg.ClientID, cfg.ClientSecret, cfg.OrgURL, cfg.CallbackURL, withOpenID(cfg.Scopes, "profile", "email")...)
+	case "azureadv2":
+		opts := azureadv2.ProviderOptions{Tenant: azureadv2.TenantType(cfg.Tenant)}
+		if opts.Tenant == "" {
+			opts.Tenant = azureadv2.CommonTenant
+		}
+		// Without scopes goth asks for its defaults, which include openid
+		if len(cfg.Scopes) > 0 {
+			for _, scope := range withOpenID(cfg.Scopes) {
+				opts.Scopes = append(opts.Scopes, azureadv2.ScopeType(scope))
+			}
+		}
+		provider = azureadv2.New(cfg.ClientID, cfg.ClientSecret, cfg.CallbackURL, opts)
+	case "oidc", "openidConnect":
+		if cfg.DiscoveryURL == "" {
+			return nil, fmt.Errorf("oidc requires discovery_url")
+		}
+		scopes := cfg.Scopes
+		if len(scopes) == 0 {
+			scopes = []string{"email", "profile"}
+		}
+		p, err := openidConnect.New(cfg.ClientID, cfg.ClientSecret, cfg.CallbackURL, cfg.DiscoveryURL, scopes...)
+		if err != nil {
+			return nil, err
+		}
+		provider = p
+	default:
+		return nil, fmt.Errorf("unsupported provider type %q", cfg.Type)
+	}
+
+	provider.SetName(cfg.Name)
+	return provider, nil
+}
+
+// withOpenID returns scopes, or defaults when none are configured, with
+// "openid" added. Logins with the providers issuesIDTokens lists send a nonce
+// and fail without an ID token, which only comes back for that scope.
+func withOpenID(scopes []string, defaults ...string) []string {
+	if len(scopes) == 0 {
+		scopes = defaults
+	}
+	if slices.Contains(scopes, "openid") {
+		return scopes
+	}
+	return append([]string{"openid"}, scopes...)
+}
+
+// Providers lists the configured providers, sorted by name
+func Providers() []ProviderInfo {
+	list := make([]ProviderInfo, 0, len(registered))
+	for _, cfg := range registered {
+		list = append(list, ProviderInfo{
+			Name:        cfg.Name,
+			DisplayName: cfg.DisplayName,
+			Type:        cfg.Type,
+			LoginURL:    "/auth/" + cfg.Name,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// EmailVerified reports whether the provider asserted that user.Email is verified,
+// either through an email_verified/verified_email claim or trust_email
+func EmailVerified(providerName string, user goth.User) bool {
+	if user.Email == "" {
+		return false
+	}
+	if cfg, ok := registered[providerName]; ok && cfg.TrustEmail {
+		return true
+	}
+	for _, claim := range []string{"email_verified", "verified_email"} {
+		switch v := user.RawData[claim].(type) {
+		case bool:
+			return v
+		case string:
+			return strings.EqualFold(v, "true")
+		}
+	}
+	return false
+}
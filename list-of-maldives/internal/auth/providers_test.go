@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/markbates/goth"
+)
+
+func TestLoadProvidersFileExpandsOnlyCredentials(t *testing.T) {
+	t.Setenv("TEST_CLIENT_ID", "id-from-env")
+	t.Setenv("TEST_CLIENT_SECRET", "secret-from-env")
+	t.Setenv("TEST_ORG", "expanded")
+
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "providers.yaml")
+	os.WriteFile(yamlPath, []byte(`providers:
+  - name: okta
+    type: okta
+    client_id: ${TEST_CLIENT_ID}
+    client_secret: ${TEST_CLIENT_SECRET}
+    org_url: https://example.okta.com/$TEST_ORG
+    scopes: [openid, email]
+`), 0o600)
+	jsonPath := filepath.Join(dir, "providers.json")
+	os.WriteFile(jsonPath, []byte(`{"providers": [{"name": "github", "client_id": "${TEST_CLIENT_ID}", "display_name": "$TEST_ORG"}]}`), 0o600)
+
+	configs, err := LoadProvidersFile(yamlPath)
+	if err != nil {
+		t.Fatalf("failed to load %s: %v", yamlPath, err)
+	}
+	if len(configs) != 1 {
+		t.Fatalf("expected 1 provider; got %d", len(configs))
+	}
+	cfg := configs[0]
+	if cfg.ClientID != "id-from-env" || cfg.ClientSecret != "secret-from-env" {
+		t.Errorf("expected the credentials to be expanded; got %q, %q", cfg.ClientID, cfg.ClientSecret)
+	}
+	if cfg.OrgURL != "https://example.okta.com/$TEST_ORG" {
+		t.Errorf("expected org_url to be kept as written; got %q", cfg.OrgURL)
+	}
+	if len(cfg.Scopes) != 2 || cfg.Scopes[1] != "email" {
+		t.Errorf("unexpected scopes %v", cfg.Scopes)
+	}
+
+	configs, err = LoadProvidersFile(jsonPath)
+	if err != nil {
+		t.Fatalf("failed to load %s: %v", jsonPath, err)
+	}
+	if configs[0].ClientID != "id-from-env" || configs[0].DisplayName != "$TEST_ORG" {
+		t.Errorf("unexpected JSON provider %+v", configs[0])
+	}
+}
+
+func TestUseProvidersRejectsBadNames(t *testing.T) {
+	t.Cleanup(func() { UseProviders(nil, "") })
+
+	cases := []struct {
+		configs []ProviderConfig
+		err     string
+	}{
+		{[]ProviderConfig{{Name: "login", Type: "github"}}, "reserved"},
+		{[]ProviderConfig{{Name: "me", Type: "google"}}, "reserved"},
+		{[]ProviderConfig{{Name: "providers", Type: "github"}}, "reserved"},
+		{[]ProviderConfig{{Name: "My SSO", Type: "github"}}, "lowercase"},
+		{[]ProviderConfig{{Name: "a/b", Type: "github"}}, "lowercase"},
+		{[]ProviderConfig{{Type: "github"}}, "without a name"},
+		{[]ProviderConfig{{Name: "gh", Type: "github"}, {Name: "gh", Type: "github"}}, "twice"},
+		{[]ProviderConfig{{Name: "corp", Type: "saml"}}, "unsupported"},
+		{[]ProviderConfig{{Name: "corp", Type: "oidc"}}, "discovery_url"},
+		{[]ProviderConfig{{Name: "corp", Type: "okta"}}, "org_url"},
+	}
+	for _, c := range cases {
+		err := UseProviders(c.configs, "http://localhost:8082")
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%+v: expected an error containing %q; got %v", c.configs, c.err, err)
+		}
+	}
+}
+
+func TestUseProvidersRegistersProviders(t *testing.T) {
+	t.Cleanup(func() { UseProviders(nil, "") })
+
+	err := UseProviders([]ProviderConfig{
+		{Name: "github", ClientID: "id", ClientSecret: "secret"},
+		{Name: "corp-google", Type: "google", DisplayName: "Corp", TrustEmail: true},
+	}, "http://localhost:8082")
+	if err != nil {
+		t.Fatalf("failed to register providers: %v", err)
+	}
+
+	list := Providers()
+	if len(list) != 2 {
+		t.Fatalf("expected 2 providers; got %+v", list)
+	}
+	if list[0].Name != "corp-google" || list[0].DisplayName != "Corp" || list[0].Type != "google" || list[0].LoginURL != "/auth/corp-google" {
+		t.Errorf("unexpected provider %+v", list[0])
+	}
+	if list[1].Name != "github" || list[1].Type != "github" || list[1].DisplayName != "github" {
+		t.Errorf("expected type and display name to default to the name; got %+v", list[1])
+	}
+	if _, err := goth.GetProvider("corp-google"); err != nil {
+		t.Errorf("expected corp-google to be registered with goth: %v", err)
+	}
+
+	if !EmailVerified("corp-google", goth.User{Email: "a@example.com"}) {
+		t.Errorf("expected trust_email to verify any email")
+	}
+	if EmailVerified("github", goth.User{Email: "a@example.com"}) {
+		t.Errorf("expected an email without a claim to be unverified")
+	}
+	if !EmailVerified("github", goth.User{Email: "a@example.com", RawData: map[string]interface{}{"email_verified": true}}) {
+		t.Errorf("expected email_verified to be honoured")
+	}
+}
+
+func TestNewProviderRequestsOpenIDScope(t *testing.T) {
+	for _, cfg := range []ProviderConfig{
+		{Name: "google", Type: "google"},
+		{Name: "google-drive", Type: "google", Scopes: []string{"email", "https://www.googleapis.com/auth/drive.readonly"}},
+		{Name: "okta", Type: "okta", OrgURL: "https://example.okta.com", Scopes: []string{"email"}},
+		{Name: "azure", Type: "azureadv2", Scopes: []string{"email"}},
+	} {
+		cfg.ClientID, cfg.ClientSecret, cfg.CallbackURL = "id", "secret", "http://localhost/auth/"+cfg.Name+"/callback"
+		provider, err := newProvider(cfg)
+		if err != nil {
+			t.Fatalf("%s: newProvider failed: %v", cfg.Name, err)
+		}
+		session, err := provider.BeginAuth("state")
+		if err != nil {
+			t.Fatalf("%s: BeginAuth failed: %v", cfg.Name, err)
+		}
+		authURL, _ := session.GetAuthURL()
+		parsed, err := url.Parse(authURL)
+		if err != nil {
+			t.Fatalf("%s: invalid auth URL %q", cfg.Name, authURL)
+		}
+		if scopes := strings.Fields(parsed.Query().Get("scope")); !slices.Contains(scopes, "openid") {
+			t.Errorf("%s: expected the openid scope; got %v", cfg.Name, scopes)
+		}
+	}
+}
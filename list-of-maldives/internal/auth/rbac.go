@@ -0,0 +1,80 @@
+// auth/rbac.go
+package auth
+
+import (
+	"slices"
+	"sort"
+)
+
+// Roles that can be granted to users
+const (
+	RoleAdmin   = "admin"
+	RoleSupport = "support"
+)
+
+// Permissions checked by RequirePermission
+const (
+	PermUsersRead          = "users:read"
+	PermUsersManage        = "users:manage"
+	PermRolesManage        = "roles:manage"
+	PermLoginThrottlesRead = "login_throttles:read"
+	PermLoginThrottlesEdit = "login_throttles:manage"
+	PermAuditRead          = "audit:read"
+)
+
+// permissionAll grants every permission
+const permissionAll = "*"
+
+// rolePermissions is what each role allows
+var rolePermissions = map[string][]string{
+	RoleAdmin: {permissionAll},
+	RoleSupport: {
+		PermUsersRead,
+		PermLoginThrottlesRead,
+		PermLoginThrottlesEdit,
+		PermAuditRead,
+	},
+}
+
+// RoleInfo describes a role for the admin API
+type RoleInfo struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// Roles lists the grantable roles, sorted by name
+func Roles() []RoleInfo {
+	list := make([]RoleInfo, 0, len(rolePermissions))
+	for name, perms := range rolePermissions {
+		list = append(list, RoleInfo{Name: name, Permissions: perms})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// ValidRole reports whether role can be granted
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasRole reports whether roles contains any of wanted
+func HasRole(roles []string, wanted ...string) bool {
+	for _, role := range wanted {
+		if slices.Contains(roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission reports whether any of roles allows permission
+func HasPermission(roles []string, permission string) bool {
+	for _, role := range roles {
+		perms := rolePermissions[role]
+		if slices.Contains(perms, permissionAll) || slices.Contains(perms, permission) {
+			return true
+		}
+	}
+	return false
+}
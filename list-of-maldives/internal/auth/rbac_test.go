@@ -0,0 +1,36 @@
+package auth
+
+import "testing"
+
+func TestHasPermission(t *testing.T) {
+	cases := []struct {
+		roles      []string
+		permission string
+		want       bool
+	}{
+		{[]string{RoleAdmin}, PermRolesManage, true},
+		{[]string{RoleAdmin}, "anything:else", true},
+		{[]string{RoleSupport}, PermUsersRead, true},
+		{[]string{RoleSupport}, PermRolesManage, false},
+		{[]string{"retired-role", RoleSupport}, PermAuditRead, true},
+		{[]string{"retired-role"}, PermUsersRead, false},
+		{nil, PermUsersRead, false},
+	}
+	for _, c := range cases {
+		if got := HasPermission(c.roles, c.permission); got != c.want {
+			t.Errorf("HasPermission(%v, %q) = %t; want %t", c.roles, c.permission, got, c.want)
+		}
+	}
+}
+
+func TestHasRole(t *testing.T) {
+	if !HasRole([]string{RoleSupport}, RoleAdmin, RoleSupport) {
+		t.Error("expected support to match")
+	}
+	if HasRole([]string{RoleSupport}, RoleAdmin) || HasRole(nil, RoleAdmin) {
+		t.Error("unexpected role match")
+	}
+	if ValidRole("superuser") || !ValidRole(RoleAdmin) {
+		t.Error("ValidRole disagrees with the role table")
+	}
+}
@@ -0,0 +1,58 @@
+// auth/redirect.go
+package auth
+
+import (
+	"list-of-maldives/internal/config"
+	"list-of-maldives/internal/cors"
+	"net/url"
+	"strings"
+)
+
+// RedirectPolicy decides where the browser may be sent back to after login
+type RedirectPolicy struct {
+	// Base resolves relative return_to paths, normally FRONTEND_URL
+	Base *url.URL
+	// Allowed holds origins, exact or wildcard subdomains as in cors.MatchOrigin
+	Allowed []string
+}
+
+// RedirectPolicyFromEnv resolves relative paths against FRONTEND_URL and allows
+// AUTH_REDIRECT_ALLOWLIST, defaulting to the CORS allowed origins
+func RedirectPolicyFromEnv() RedirectPolicy {
+	base, err := url.Parse(config.String("FRONTEND_URL", "http://localhost:5173"))
+	if err != nil {
+		base = &url.URL{}
+	}
+	allowed := config.List("AUTH_REDIRECT_ALLOWLIST")
+	if len(allowed) == 0 {
+		allowed = cors.DefaultPolicyFromEnv().AllowedOrigins
+	}
+	return RedirectPolicy{Base: base, Allowed: allowed}
+}
+
+// Resolve returns the absolute URL for returnTo if it is a path on the frontend
+// or a URL on an allowed origin
+func (p RedirectPolicy) Resolve(returnTo string) (string, bool) {
+	// Browsers treat "\" like "/", so "/\evil.com" would be protocol-relative
+	if returnTo == "" || strings.ContainsAny(returnTo, "\\\r\n\t") {
+		return "", false
+	}
+
+	u, err := url.Parse(returnTo)
+	if err != nil || u.User != nil {
+		return "", false
+	}
+	if !u.IsAbs() {
+		if u.Host != "" || !strings.HasPrefix(u.Path, "/") {
+			return "", false
+		}
+		u = p.Base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", false
+	}
+	if !cors.MatchOrigin(p.Allowed, u.Scheme+"://"+u.Host) {
+		return "", false
+	}
+	return u.String(), true
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestRedirectPolicyResolve(t *testing.T) {
+	base, _ := url.Parse("https://app.example.com")
+	p := RedirectPolicy{Base: base, Allowed: []string{"https://app.example.com", "https://*.preview.example.com"}}
+
+	allowed := map[string]string{
+		"/lists/5?tab=mine":                       "https://app.example.com/lists/5?tab=mine",
+		"https://app.example.com/settings#mfa":    "https://app.example.com/settings#mfa",
+		"https://pr-7.preview.example.com/lists/": "https://pr-7.preview.example.com/lists/",
+	}
+	for in, want := range allowed {
+		got, ok := p.Resolve(in)
+		if !ok || got != want {
+			t.Errorf("Resolve(%q) = %q, %t; want %q", in, got, ok, want)
+		}
+	}
+
+	for _, in := range []string{
+		"",
+		"//evil.com/path",
+		"/\\evil.com",
+		"https://evil.com/",
+		"https://app.example.com.evil.com/",
+		"https://user@app.example.com/",
+		"javascript:alert(1)",
+		"lists/5",
+		"http://app.example.com/",
+	} {
+		if got, ok := p.Resolve(in); ok {
+			t.Errorf("expected %q to be refused; got %q", in, got)
+		}
+	}
+}
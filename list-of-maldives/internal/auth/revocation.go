@@ -0,0 +1,171 @@
+// auth/revocation.go
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// RevocationStore records access tokens that must be rejected before they expire.
+// Single tokens are revoked by jti; RevokeUserTokens rejects every token a user
+// was issued before the given instant (password change, account deletion).
+// Stores keep that instant as its RevocationCutoff.
+type RevocationStore interface {
+	RevokeToken(jti string, expiresAt time.Time) error
+	RevokeUserTokens(userID string, before time.Time) error
+	IsTokenRevoked(jti string) (bool, error)
+	UserTokensRevokedBefore(userID string) (time.Time, error)
+}
+
+// IsRevoked reports whether the token described by claims has been revoked
+func IsRevoked(store RevocationStore, claims *Claims) (bool, error) {
+	if claims.ID != "" {
+		revoked, err := store.IsTokenRevoked(claims.ID)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+
+	cutoff, err := store.UserTokensRevokedBefore(claims.UserID)
+	if err != nil || cutoff.IsZero() {
+		return false, err
+	}
+	return claims.IssuedAt == nil || claims.IssuedAt.Time.Before(cutoff), nil
+}
+
+// RevocationCutoff is when tokens become valid again after a user's tokens
+// were revoked at before. iat only has whole seconds, so it is the start of
+// the next second: a token minted in the same second as the revocation can't
+// be told apart from one minted just before it.
+func RevocationCutoff(before time.Time) time.Time {
+	return before.Truncate(time.Second).Add(time.Second)
+}
+
+// CachedRevocationStore fronts a shared store with an in-memory cache.
+// Revocations are cached until the token expires; negative lookups are only
+// trusted for negativeTTL so revocations made by other instances are picked
+// up quickly.
+type CachedRevocationStore struct {
+	backend     RevocationStore
+	negativeTTL time.Duration
+
+	mu        sync.Mutex
+	tokens    map[string]cachedRevocation
+	cutoffs   map[string]cachedCutoff
+	lastEvict time.Time
+}
+
+type cachedRevocation struct {
+	revoked bool
+	until   time.Time
+}
+
+type cachedCutoff struct {
+	before time.Time
+	until  time.Time
+}
+
+func NewCachedRevocationStore(backend RevocationStore, negativeTTL time.Duration) *CachedRevocationStore {
+	return &CachedRevocationStore{
+		backend:     backend,
+		negativeTTL: negativeTTL,
+		tokens:      make(map[string]cachedRevocation),
+		cutoffs:     make(map[string]cachedCutoff),
+	}
+}
+
+func (c *CachedRevocationStore) RevokeToken(jti string, expiresAt time.Time) error {
+	if err := c.backend.RevokeToken(jti, expiresAt); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.tokens[jti] = cachedRevocation{revoked: true, until: expiresAt}
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *CachedRevocationStore) RevokeUserTokens(userID string, before time.Time) error {
+	if err := c.backend.RevokeUserTokens(userID, before); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.cutoffs[userID] = cachedCutoff{before: RevocationCutoff(before), until: time.Now().Add(c.negativeTTL)}
+	c.mu.Unlock()
+	return nil
+}
+
+// ForgetUser drops the cached cutoff of userID, for revocations that were
+// written to the backend directly, e.g. in a transaction with other changes
+func (c *CachedRevocationStore) ForgetUser(userID string) {
+	c.mu.Lock()
+	delete(c.cutoffs, userID)
+	c.mu.Unlock()
+}
+
+func (c *CachedRevocationStore) IsTokenRevoked(jti string) (bool, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.tokens[jti]
+	c.mu.Unlock()
+	if ok && now.Before(entry.until) {
+		return entry.revoked, nil
+	}
+
+	revoked, err := c.backend.IsTokenRevoked(jti)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evictExpiredLocked(now)
+	if revoked {
+		// The token can't outlive its exp anyway; keep the entry for a while.
+		c.tokens[jti] = cachedRevocation{revoked: true, until: now.Add(24 * time.Hour)}
+	} else {
+		c.tokens[jti] = cachedRevocation{until: now.Add(c.negativeTTL)}
+	}
+	return revoked, nil
+}
+
+func (c *CachedRevocationStore) UserTokensRevokedBefore(userID string) (time.Time, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.cutoffs[userID]
+	c.mu.Unlock()
+	if ok && now.Before(entry.until) {
+		return entry.before, nil
+	}
+
+	before, err := c.backend.UserTokensRevokedBefore(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	c.mu.Lock()
+	c.cutoffs[userID] = cachedCutoff{before: before, until: now.Add(c.negativeTTL)}
+	c.mu.Unlock()
+	return before, nil
+}
+
+// evictExpiredLocked keeps the cache bounded by dropping stale entries,
+// at most once a minute
+func (c *CachedRevocationStore) evictExpiredLocked(now time.Time) {
+	if now.Sub(c.lastEvict) < time.Minute {
+		return
+	}
+	c.lastEvict = now
+
+	for jti, entry := range c.tokens {
+		if now.After(entry.until) {
+			delete(c.tokens, jti)
+		}
+	}
+	for userID, entry := range c.cutoffs {
+		if now.After(entry.until) {
+			delete(c.cutoffs, userID)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type memoryRevocationStore struct {
+	tokens  map[string]bool
+	cutoffs map[string]time.Time
+}
+
+func newMemoryRevocationStore() *memoryRevocationStore {
+	return &memoryRevocationStore{tokens: map[string]bool{}, cutoffs: map[string]time.Time{}}
+}
+
+func (s *memoryRevocationStore) RevokeToken(jti string, _ time.Time) error {
+	s.tokens[jti] = true
+	return nil
+}
+
+func (s *memoryRevocationStore) RevokeUserTokens(userID string, before time.Time) error {
+	s.cutoffs[userID] = RevocationCutoff(before)
+	return nil
+}
+
+func (s *memoryRevocationStore) IsTokenRevoked(jti string) (bool, error) {
+	return s.tokens[jti], nil
+}
+
+func (s *memoryRevocationStore) UserTokensRevokedBefore(userID string) (time.Time, error) {
+	return s.cutoffs[userID], nil
+}
+
+func TestIsRevoked(t *testing.T) {
+	store := newMemoryRevocationStore()
+	revokedAt := time.Date(2025, 1, 2, 3, 4, 5, 600_000_000, time.UTC)
+	store.RevokeUserTokens("user", revokedAt)
+	store.RevokeToken("stolen", revokedAt.Add(time.Hour))
+
+	claimsIssuedAt := func(jti string, iat time.Time) *Claims {
+		return &Claims{UserID: "user", RegisteredClaims: jwt.RegisteredClaims{
+			ID:       jti,
+			IssuedAt: jwt.NewNumericDate(iat),
+		}}
+	}
+
+	cases := []struct {
+		name    string
+		claims  *Claims
+		revoked bool
+	}{
+		{"issued a second before", claimsIssuedAt("a", revokedAt.Add(-time.Second)), true},
+		// iat is truncated to 03:04:05, so it can't be told from a token
+		// issued just before the revocation
+		{"issued later in the same second", claimsIssuedAt("b", revokedAt.Add(300*time.Millisecond)), true},
+		{"issued the next second", claimsIssuedAt("c", revokedAt.Add(400*time.Millisecond)), false},
+		{"revoked by jti", claimsIssuedAt("stolen", revokedAt.Add(time.Minute)), true},
+		{"without iat", &Claims{UserID: "user"}, true},
+		{"other user", &Claims{UserID: "other"}, false},
+	}
+	for _, c := range cases {
+		revoked, err := IsRevoked(store, c.claims)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", c.name, err)
+		}
+		if revoked != c.revoked {
+			t.Errorf("%s: expected revoked=%t; got %t", c.name, c.revoked, revoked)
+		}
+	}
+}
+
+func TestCachedRevocationStoreCutoff(t *testing.T) {
+	backend := newMemoryRevocationStore()
+	cache := NewCachedRevocationStore(backend, time.Minute)
+	revokedAt := time.Date(2025, 1, 2, 3, 4, 5, 600_000_000, time.UTC)
+
+	if err := cache.RevokeUserTokens("user", revokedAt); err != nil {
+		t.Fatalf("RevokeUserTokens failed: %v", err)
+	}
+	cutoff, err := cache.UserTokensRevokedBefore("user")
+	if err != nil || !cutoff.Equal(RevocationCutoff(revokedAt)) {
+		t.Fatalf("expected the cutoff %v; got %v, %v", RevocationCutoff(revokedAt), cutoff, err)
+	}
+
+	// A revocation written to the backend directly is only seen once the
+	// cached cutoff is forgotten
+	later := revokedAt.Add(time.Hour)
+	backend.RevokeUserTokens("user", later)
+	if cutoff, _ := cache.UserTokensRevokedBefore("user"); cutoff.Equal(RevocationCutoff(later)) {
+		t.Fatalf("expected the cached cutoff before ForgetUser")
+	}
+	cache.ForgetUser("user")
+	if cutoff, _ := cache.UserTokensRevokedBefore("user"); !cutoff.Equal(RevocationCutoff(later)) {
+		t.Errorf("expected the backend's cutoff %v after ForgetUser; got %v", RevocationCutoff(later), cutoff)
+	}
+}
@@ -0,0 +1,236 @@
+// auth/sessions.go
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"list-of-maldives/internal/config"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// defaultSessionMaxAge matches the lifetime the gothic store always had
+const defaultSessionMaxAge = 30 * 24 * time.Hour
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionKey is one signing (HMAC-SHA256) and encryption (AES-256) key pair
+type SessionKey struct {
+	Signing    []byte
+	Encryption []byte
+}
+
+// deriveKey stretches a configured secret to a 32-byte key; label keeps the
+// signing and encryption keys derived from one secret apart
+func deriveKey(label, secret string) []byte {
+	sum := sha256.Sum256([]byte(label + ":" + secret))
+	return sum[:]
+}
+
+// ParseSessionKeys reads comma-separated keys, newest first. An entry is
+// either "<signing>:<encryption>" or a single secret both keys are derived from.
+func ParseSessionKeys(entries []string) ([]SessionKey, error) {
+	var keys []SessionKey
+	for _, entry := range entries {
+		signing, encryption, pair := strings.Cut(entry, ":")
+		if !pair {
+			encryption = signing
+		}
+		if signing == "" || encryption == "" {
+			return nil, fmt.Errorf("session key %q needs both a signing and an encryption secret", entry)
+		}
+		keys = append(keys, SessionKey{
+			Signing:    deriveKey("sign", signing),
+			Encryption: deriveKey("encrypt", encryption),
+		})
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no session keys configured")
+	}
+	return keys, nil
+}
+
+// SessionKeysFromEnv reads SESSION_KEYS, falling back to SESSION_SECRET.
+// Prepend a new key to rotate; sessions sealed with the older ones still open.
+func SessionKeysFromEnv() ([]SessionKey, error) {
+	entries := config.List("SESSION_KEYS")
+	if len(entries) == 0 && os.Getenv("SESSION_SECRET") != "" {
+		entries = []string{os.Getenv("SESSION_SECRET")}
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("SESSION_KEYS or SESSION_SECRET must be set")
+	}
+	return ParseSessionKeys(entries)
+}
+
+// SessionOptionsFromEnv builds the cookie options. Cookies are Secure in
+// production unless SESSION_COOKIE_SECURE says otherwise, and SameSite comes
+// from SESSION_COOKIE_SAMESITE (lax by default; none forces Secure).
+func SessionOptionsFromEnv() (*sessions.Options, error) {
+	opts := &sessions.Options{
+		Path:     "/",
+		MaxAge:   int(config.Duration("SESSION_MAX_AGE", defaultSessionMaxAge).Seconds()),
+		HttpOnly: true,
+		Secure:   config.Bool("SESSION_COOKIE_SECURE", config.IsProduction()),
+	}
+	switch strings.ToLower(config.String("SESSION_COOKIE_SAMESITE", "lax")) {
+	case "lax":
+		opts.SameSite = http.SameSiteLaxMode
+	case "strict":
+		opts.SameSite = http.SameSiteStrictMode
+	case "none":
+		opts.SameSite = http.SameSiteNoneMode
+		opts.Secure = true
+	default:
+		return nil, fmt.Errorf("invalid SESSION_COOKIE_SAMESITE=%q", os.Getenv("SESSION_COOKIE_SAMESITE"))
+	}
+	return opts, nil
+}
+
+// sessionCodecs signs and encrypts with the first key and accepts all of them
+func sessionCodecs(keys []SessionKey, maxAge int) []securecookie.Codec {
+	codecs := make([]securecookie.Codec, 0, len(keys))
+	for _, key := range keys {
+		codec := securecookie.New(key.Signing, key.Encryption)
+		codec.MaxAge(maxAge)
+		codecs = append(codecs, codec)
+	}
+	return codecs
+}
+
+// NewCookieSessionStore keeps session values in the cookie itself
+func NewCookieSessionStore(keys []SessionKey, opts *sessions.Options) *sessions.CookieStore {
+	copied := *opts
+	return &sessions.CookieStore{
+		Codecs:  sessionCodecs(keys, opts.MaxAge),
+		Options: &copied,
+	}
+}
+
+// SessionBackend persists server-side session data, already sealed, by session ID
+type SessionBackend interface {
+	Load(ctx context.Context, id string) (string, error)
+	Save(ctx context.Context, id, data string, expiresAt time.Time) error
+	Delete(ctx context.Context, id string) error
+}
+
+// ServerSessionStore keeps session values in a SessionBackend and only a
+// sealed session ID in the cookie, so every API instance sees the same
+// sessions. Values are sealed with the session keys before they are stored.
+type ServerSessionStore struct {
+	codecs  []securecookie.Codec
+	options *sessions.Options
+	backend SessionBackend
+}
+
+func NewServerSessionStore(keys []SessionKey, opts *sessions.Options, backend SessionBackend) *ServerSessionStore {
+	codecs := sessionCodecs(keys, opts.MaxAge)
+	for _, codec := range codecs {
+		// Values live in the backend, not the cookie, so the 4KB cap doesn't apply
+		codec.(*securecookie.SecureCookie).MaxLength(0)
+	}
+	copied := *opts
+	return &ServerSessionStore{codecs: codecs, options: &copied, backend: backend}
+}
+
+// Get returns the named session, cached for the rest of the request
+func (s *ServerSessionStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the named session, or returns a new one if the cookie is
+// missing, unreadable or points at a session that has expired
+func (s *ServerSessionStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.options
+	session.Options = &opts
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	var id string
+	if err := securecookie.DecodeMulti(name, cookie.Value, &id, s.codecs...); err != nil {
+		return session, err
+	}
+	data, err := s.backend.Load(r.Context(), id)
+	if errors.Is(err, ErrSessionNotFound) {
+		return session, nil
+	} else if err != nil {
+		return session, err
+	}
+	if err := securecookie.DecodeMulti(name, data, &session.Values, s.codecs...); err != nil {
+		return session, err
+	}
+	session.ID = id
+	session.IsNew = false
+	return session, nil
+}
+
+// Save stores the session and sets its cookie; MaxAge <= 0 deletes it
+func (s *ServerSessionStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge <= 0 {
+		if session.ID != "" {
+			if err := s.backend.Delete(r.Context(), session.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		id, err := randomToken(32)
+		if err != nil {
+			return err
+		}
+		session.ID = id
+	}
+	data, err := securecookie.EncodeMulti(session.Name(), session.Values, s.codecs...)
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(time.Duration(session.Options.MaxAge) * time.Second)
+	if err := s.backend.Save(r.Context(), session.ID, data, expiresAt); err != nil {
+		return err
+	}
+
+	cookie, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), cookie, session.Options))
+	return nil
+}
+
+// SessionStoreFromEnv builds the store for OAuth state and the other
+// short-lived flow sessions. SESSION_STORE=postgres keeps them in backend so
+// any API instance can finish a flow another one started; the default keeps
+// them in cookies.
+func SessionStoreFromEnv(backend SessionBackend) (sessions.Store, error) {
+	keys, err := SessionKeysFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	opts, err := SessionOptionsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind := config.String("SESSION_STORE", "cookie"); kind {
+	case "cookie":
+		return NewCookieSessionStore(keys, opts), nil
+	case "postgres":
+		return NewServerSessionStore(keys, opts, backend), nil
+	default:
+		return nil, fmt.Errorf("unknown SESSION_STORE %q", kind)
+	}
+}
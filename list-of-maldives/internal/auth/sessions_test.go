@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+type memorySessionBackend map[string]string
+
+func (b memorySessionBackend) Load(_ context.Context, id string) (string, error) {
+	data, ok := b[id]
+	if !ok {
+		return "", ErrSessionNotFound
+	}
+	return data, nil
+}
+
+func (b memorySessionBackend) Save(_ context.Context, id, data string, _ time.Time) error {
+	b[id] = data
+	return nil
+}
+
+func (b memorySessionBackend) Delete(_ context.Context, id string) error {
+	delete(b, id)
+	return nil
+}
+
+func testSessionOptions() *sessions.Options {
+	return &sessions.Options{Path: "/", MaxAge: 600, HttpOnly: true}
+}
+
+// saveSession stores value in a fresh "flow" session and returns its cookies
+func saveSession(t *testing.T, store sessions.Store, value string) []*http.Cookie {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	session, _ := store.New(r, "flow")
+	session.Values["value"] = value
+	if err := session.Save(r, rec); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Result().Cookies()
+}
+
+// loadSession returns the "flow" value the store finds for cookies
+func loadSession(store sessions.Store, cookies []*http.Cookie) (string, error) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	session, err := store.New(r, "flow")
+	if err != nil {
+		return "", err
+	}
+	value, _ := session.Values["value"].(string)
+	return value, nil
+}
+
+func TestSessionKeyRotation(t *testing.T) {
+	for name, newStore := range map[string]func(keys []SessionKey) sessions.Store{
+		"cookie": func(keys []SessionKey) sessions.Store { return NewCookieSessionStore(keys, testSessionOptions()) },
+		"server": func() func(keys []SessionKey) sessions.Store {
+			backend := memorySessionBackend{}
+			return func(keys []SessionKey) sessions.Store {
+				return NewServerSessionStore(keys, testSessionOptions(), backend)
+			}
+		}(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			oldKeys, _ := ParseSessionKeys([]string{"old-secret"})
+			rotated, _ := ParseSessionKeys([]string{"new-sign:new-encrypt", "old-secret"})
+			retired, _ := ParseSessionKeys([]string{"new-sign:new-encrypt"})
+
+			cookies := saveSession(t, newStore(oldKeys), "state-1")
+			if value, err := loadSession(newStore(rotated), cookies); err != nil || value != "state-1" {
+				t.Fatalf("rotated keys should open old sessions: %q, %v", value, err)
+			}
+			if value, _ := loadSession(newStore(retired), cookies); value != "" {
+				t.Fatalf("retired key still opens sessions: %q", value)
+			}
+		})
+	}
+}
+
+func TestServerSessionStoreKeepsValuesServerSide(t *testing.T) {
+	keys, _ := ParseSessionKeys([]string{"secret"})
+	backend := memorySessionBackend{}
+	store := NewServerSessionStore(keys, testSessionOptions(), backend)
+
+	cookies := saveSession(t, store, "a-value-that-stays-on-the-server")
+	if len(backend) != 1 {
+		t.Fatalf("expected one stored session, got %d", len(backend))
+	}
+	for _, data := range backend {
+		if strings.Contains(data, "a-value-that-stays-on-the-server") {
+			t.Fatal("stored session data is not sealed")
+		}
+	}
+
+	// Deleting the session removes it from the backend
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	session, err := store.New(r, "flow")
+	if err != nil || session.IsNew {
+		t.Fatalf("expected the saved session, got new=%t err=%v", session.IsNew, err)
+	}
+	session.Options.MaxAge = -1
+	if err := session.Save(r, httptest.NewRecorder()); err != nil {
+		t.Fatal(err)
+	}
+	if len(backend) != 0 {
+		t.Fatal("deleted session is still stored")
+	}
+	if value, _ := loadSession(store, cookies); value != "" {
+		t.Fatalf("deleted session still loads: %q", value)
+	}
+}
+
+func TestSessionOptionsFromEnv(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "production")
+	t.Setenv("SESSION_COOKIE_SAMESITE", "")
+	opts, err := SessionOptionsFromEnv()
+	if err != nil || !opts.Secure || opts.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("production defaults: %+v, %v", opts, err)
+	}
+
+	t.Setenv("ENVIRONMENT", "development")
+	t.Setenv("SESSION_COOKIE_SAMESITE", "none")
+	if opts, err := SessionOptionsFromEnv(); err != nil || !opts.Secure {
+		t.Fatalf("SameSite=None must be Secure: %+v, %v", opts, err)
+	}
+
+	t.Setenv("SESSION_COOKIE_SAMESITE", "sideways")
+	if _, err := SessionOptionsFromEnv(); err == nil {
+		t.Fatal("expected an invalid SameSite to be refused")
+	}
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B, SHA-1 key "12345678901234567890"
+func TestHOTPMatchesRFC6238Vectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, v := range vectors {
+		if got := hotp(key, uint64(v.unix/totpPeriod), 8); got != v.code {
+			t.Errorf("at %d expected %s; got %s", v.unix, v.code, got)
+		}
+	}
+}
+
+func TestValidateTOTPAllowsSkewOnly(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+
+	code, _ := TOTPCode(secret, now.Add(-totpPeriod*time.Second))
+	step, ok := ValidateTOTP(secret, code, now)
+	if !ok || step != now.Unix()/totpPeriod-1 {
+		t.Errorf("expected previous step to be accepted; got ok=%v step=%d", ok, step)
+	}
+
+	code, _ = TOTPCode(secret, now.Add(-3*totpPeriod*time.Second))
+	if _, ok := ValidateTOTP(secret, code, now); ok {
+		t.Errorf("expected code three steps old to be rejected")
+	}
+}
+
+func TestSecretBoxRoundTrip(t *testing.T) {
+	box, _ := NewSecretBox("passphrase")
+	sealed, err := box.Seal("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := box.Open(sealed)
+	if err != nil || opened != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("expected round trip; got %q, %v", opened, err)
+	}
+
+	other, _ := NewSecretBox("other")
+	if _, err := other.Open(sealed); err == nil {
+		t.Errorf("expected a different key to fail")
+	}
+}
@@ -0,0 +1,129 @@
+// auth/webauthn.go
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"list-of-maldives/internal/config"
+	"net/url"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// ErrPasskeyCloned is returned when an assertion's signature counter went
+// backwards, which suggests the credential's private key has been copied
+var ErrPasskeyCloned = errors.New("passkey signature counter did not increase")
+
+// PasskeyUser adapts an account and its stored credentials to webauthn.User.
+// Handle is the opaque user handle stored on the authenticator.
+type PasskeyUser struct {
+	Handle      []byte
+	Name        string
+	DisplayName string
+	Credentials []webauthn.Credential
+}
+
+func (u *PasskeyUser) WebAuthnID() []byte                         { return u.Handle }
+func (u *PasskeyUser) WebAuthnName() string                       { return u.Name }
+func (u *PasskeyUser) WebAuthnDisplayName() string                { return u.DisplayName }
+func (u *PasskeyUser) WebAuthnCredentials() []webauthn.Credential { return u.Credentials }
+
+// PasskeyLookup resolves the user handle returned by a discoverable credential
+type PasskeyLookup func(handle []byte) (*PasskeyUser, error)
+
+// Passkeys is the WebAuthn relying party. Ceremony state (webauthn.SessionData)
+// is returned to the caller, which keeps it between begin and finish.
+type Passkeys struct {
+	rp *webauthn.WebAuthn
+}
+
+// NewPasskeys creates a relying party for rpID accepting the given origins
+func NewPasskeys(rpID, rpName string, origins []string) (*Passkeys, error) {
+	rp, err := webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: rpName,
+		RPOrigins:     origins,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Passkeys{rp: rp}, nil
+}
+
+// PasskeysFromEnv reads WEBAUTHN_RP_ID, WEBAUTHN_RP_NAME and WEBAUTHN_RP_ORIGINS.
+// The origin defaults to FRONTEND_URL and the RP ID to its hostname.
+func PasskeysFromEnv() (*Passkeys, error) {
+	origins := config.List("WEBAUTHN_RP_ORIGINS")
+	if len(origins) == 0 {
+		origins = []string{config.String("FRONTEND_URL", "http://localhost:5173")}
+	}
+
+	rpID := config.String("WEBAUTHN_RP_ID", "")
+	if rpID == "" {
+		u, err := url.Parse(origins[0])
+		if err != nil || u.Hostname() == "" {
+			return nil, fmt.Errorf("cannot derive WEBAUTHN_RP_ID from %q", origins[0])
+		}
+		rpID = u.Hostname()
+	}
+
+	return NewPasskeys(rpID, config.String("WEBAUTHN_RP_NAME", "List of Maldives"), origins)
+}
+
+// BeginRegistration creates options for a new discoverable credential,
+// excluding the ones user already has
+func (p *Passkeys) BeginRegistration(user *PasskeyUser) (*protocol.CredentialCreation, *webauthn.SessionData, error) {
+	exclude := make([]protocol.CredentialDescriptor, 0, len(user.Credentials))
+	for _, cred := range user.Credentials {
+		exclude = append(exclude, cred.Descriptor())
+	}
+
+	return p.rp.BeginRegistration(user,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(exclude),
+	)
+}
+
+// FinishRegistration verifies the authenticator's attestation response (the
+// JSON-encoded PublicKeyCredential) and returns the credential to store
+func (p *Passkeys) FinishRegistration(user *PasskeyUser, session webauthn.SessionData, response []byte) (*webauthn.Credential, error) {
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, err
+	}
+	return p.rp.CreateCredential(user, session, parsed)
+}
+
+// BeginLogin creates options for a usernameless (discoverable) assertion
+func (p *Passkeys) BeginLogin() (*protocol.CredentialAssertion, *webauthn.SessionData, error) {
+	return p.rp.BeginDiscoverableLogin()
+}
+
+// FinishLogin verifies an assertion response and returns the user it belongs to
+// together with the credential carrying its updated sign count and flags
+func (p *Passkeys) FinishLogin(session webauthn.SessionData, response []byte, lookup PasskeyLookup) (*PasskeyUser, *webauthn.Credential, error) {
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var user *PasskeyUser
+	handler := func(rawID, handle []byte) (webauthn.User, error) {
+		u, err := lookup(handle)
+		if err != nil {
+			return nil, err
+		}
+		user = u
+		return u, nil
+	}
+
+	_, cred, err := p.rp.ValidatePasskeyLogin(handler, session, parsed)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cred.Authenticator.CloneWarning {
+		return nil, nil, ErrPasskeyCloned
+	}
+	return user, cred, nil
+}
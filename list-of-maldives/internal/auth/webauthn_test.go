@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"list-of-maldives/internal/auth/passkeytest"
+	"testing"
+)
+
+const testOrigin = "http://localhost:5173"
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	p, err := NewPasskeys("localhost", "List of Maldives", []string{testOrigin})
+	if err != nil {
+		t.Fatalf("error creating relying party. Err: %v", err)
+	}
+	user := &PasskeyUser{Handle: []byte("user-1"), Name: "user@example.com", DisplayName: "User"}
+	authenticator := passkeytest.New(t)
+
+	// Registration
+	_, session, err := p.BeginRegistration(user)
+	if err != nil {
+		t.Fatalf("error beginning registration. Err: %v", err)
+	}
+	cred, err := p.FinishRegistration(user, *session, authenticator.Create(t, "localhost", session.Challenge, testOrigin, user.Handle))
+	if err != nil {
+		t.Fatalf("error finishing registration. Err: %v", err)
+	}
+	if !bytes.Equal(cred.ID, authenticator.CredentialID) {
+		t.Fatalf("expected credential %x; got %x", authenticator.CredentialID, cred.ID)
+	}
+	user.Credentials = append(user.Credentials, *cred)
+
+	lookup := func(handle []byte) (*PasskeyUser, error) {
+		if !bytes.Equal(handle, user.Handle) {
+			return nil, errors.New("unknown user")
+		}
+		return user, nil
+	}
+
+	// Login
+	_, session, err = p.BeginLogin()
+	if err != nil {
+		t.Fatalf("error beginning login. Err: %v", err)
+	}
+	assertion := authenticator.Get(t, "localhost", session.Challenge, testOrigin)
+	found, used, err := p.FinishLogin(*session, assertion, lookup)
+	if err != nil {
+		t.Fatalf("error finishing login. Err: %v", err)
+	}
+	if found != user {
+		t.Errorf("expected the registered user to be returned")
+	}
+	if used.Authenticator.SignCount != 1 {
+		t.Errorf("expected sign count 1; got %d", used.Authenticator.SignCount)
+	}
+	user.Credentials[0] = *used
+
+	// A captured assertion doesn't answer a new challenge
+	_, session, _ = p.BeginLogin()
+	if _, _, err := p.FinishLogin(*session, assertion, lookup); err == nil {
+		t.Errorf("expected replayed assertion to be rejected")
+	}
+
+	// Assertions made for another origin are rejected
+	_, session, _ = p.BeginLogin()
+	if _, _, err := p.FinishLogin(*session, authenticator.Get(t, "localhost", session.Challenge, "https://evil.example"), lookup); err == nil {
+		t.Errorf("expected assertion for a foreign origin to be rejected")
+	}
+
+	// A copy of the key replaying an old counter looks cloned
+	authenticator.Counter = 0
+	_, session, _ = p.BeginLogin()
+	if _, _, err := p.FinishLogin(*session, authenticator.Get(t, "localhost", session.Challenge, testOrigin), lookup); !errors.Is(err, ErrPasskeyCloned) {
+		t.Errorf("expected ErrPasskeyCloned; got %v", err)
+	}
+}
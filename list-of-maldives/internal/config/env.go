@@ -0,0 +1,76 @@
+// config/env.go
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IsProduction reports whether the API runs with ENVIRONMENT=production
+func IsProduction() bool {
+	return os.Getenv("ENVIRONMENT") == "production"
+}
+
+// String returns the named env var, or fallback when it is unset
+func String(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// Duration parses the named env var as a time.Duration ("15m", "720h")
+func Duration(name string, fallback time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Printf("invalid %s=%q, using default %s", name, v, fallback)
+		return fallback
+	}
+	return d
+}
+
+// Int parses the named env var as an integer
+func Int(name string, fallback int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("invalid %s=%q, using default %d", name, v, fallback)
+		return fallback
+	}
+	return n
+}
+
+// Bool parses the named env var as a boolean ("true", "1", "false", ...)
+func Bool(name string, fallback bool) bool {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("invalid %s=%q, using default %t", name, v, fallback)
+		return fallback
+	}
+	return b
+}
+
+// List splits a comma-separated env var, dropping empty entries
+func List(name string) []string {
+	var out []string
+	for _, part := range strings.Split(os.Getenv(name), ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
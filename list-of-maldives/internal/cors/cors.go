@@ -0,0 +1,221 @@
+// cors/cors.go
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"list-of-maldives/internal/config"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy describes which cross-origin requests a route accepts
+type Policy struct {
+	// AllowedOrigins holds exact origins ("https://app.example.com"),
+	// wildcard subdomains ("https://*.example.com") or "*" for any origin
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// Route overrides the default policy for paths starting with Prefix
+type Route struct {
+	Prefix string
+	Policy Policy
+}
+
+// CORS applies the policy of the longest matching route, or the default one
+type CORS struct {
+	defaultPolicy Policy
+	routes        []Route
+}
+
+var errWildcardCredentials = errors.New(`"*" origin cannot be combined with credentials`)
+
+func New(defaultPolicy Policy, routes ...Route) (*CORS, error) {
+	for _, p := range append([]Policy{defaultPolicy}, routesPolicies(routes)...) {
+		if p.AllowCredentials && slices.Contains(p.AllowedOrigins, "*") {
+			return nil, errWildcardCredentials
+		}
+		for _, origin := range p.AllowedOrigins {
+			if origin != "*" && normalizeOrigin(strings.Replace(origin, "*.", "", 1)) == "" {
+				return nil, fmt.Errorf("invalid CORS origin %q", origin)
+			}
+		}
+	}
+	return &CORS{defaultPolicy: defaultPolicy, routes: routes}, nil
+}
+
+func routesPolicies(routes []Route) []Policy {
+	policies := make([]Policy, len(routes))
+	for i, r := range routes {
+		policies[i] = r.Policy
+	}
+	return policies
+}
+
+// DefaultPolicyFromEnv allows CORS_ALLOWED_ORIGINS (comma-separated, default
+// the FRONTEND_URL origin) with credentials
+func DefaultPolicyFromEnv() Policy {
+	origins := config.List("CORS_ALLOWED_ORIGINS")
+	if len(origins) == 0 {
+		origins = []string{config.String("FRONTEND_URL", "http://localhost:5173")}
+	}
+	for i, origin := range origins {
+		if origin != "*" && !strings.Contains(origin, "*.") {
+			if normalized := normalizeOrigin(origin); normalized != "" {
+				origins[i] = normalized
+			}
+		}
+	}
+
+	return Policy{
+		AllowedOrigins:   origins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		ExposedHeaders:   []string{"WWW-Authenticate", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy"},
+		AllowCredentials: true,
+		MaxAge:           config.Duration("CORS_MAX_AGE", 24*time.Hour),
+	}
+}
+
+// normalizeOrigin returns the lower-case scheme://host[:port] of origin,
+// or "" if origin isn't one
+func normalizeOrigin(origin string) string {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+		return ""
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// MatchOrigin reports whether origin matches one of patterns. A pattern like
+// "https://*.example.com" matches subdomains only, with the same scheme and port.
+func MatchOrigin(patterns []string, origin string) bool {
+	origin = normalizeOrigin(origin)
+	if origin == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if pattern == "*" {
+			return true
+		}
+		scheme, rest, ok := strings.Cut(strings.ToLower(pattern), "://*.")
+		if !ok {
+			if normalizeOrigin(pattern) == origin {
+				return true
+			}
+			continue
+		}
+		// rest is the parent domain plus optional port; origin needs a label in front of it
+		prefix, found := strings.CutSuffix(origin, "."+rest)
+		if found && strings.HasPrefix(prefix, scheme+"://") && len(prefix) > len(scheme)+3 &&
+			!strings.ContainsAny(prefix[len(scheme)+3:], ":/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORS) policyFor(path string) Policy {
+	policy, best := c.defaultPolicy, -1
+	for _, route := range c.routes {
+		if strings.HasPrefix(path, route.Prefix) && len(route.Prefix) > best {
+			policy, best = route.Policy, len(route.Prefix)
+		}
+	}
+	return policy
+}
+
+func containsFold(list []string, value string) bool {
+	return slices.ContainsFunc(list, func(item string) bool { return strings.EqualFold(item, value) })
+}
+
+// Handler answers preflights and sets CORS headers on actual requests.
+// Preflights from disallowed origins, or asking for disallowed methods or
+// headers, are refused with 403; other OPTIONS requests get 204.
+func (c *CORS) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy := c.policyFor(r.URL.Path)
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		// Responses differ per origin, so caches must key on it
+		w.Header().Add("Vary", "Origin")
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		allowed := origin != "" && MatchOrigin(policy.AllowedOrigins, origin)
+
+		if preflight {
+			if !allowed {
+				http.Error(w, "CORS origin not allowed", http.StatusForbidden)
+				return
+			}
+			if !containsFold(policy.AllowedMethods, r.Header.Get("Access-Control-Request-Method")) {
+				http.Error(w, "CORS method not allowed", http.StatusForbidden)
+				return
+			}
+			var headers []string
+			for _, h := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+				if h = strings.TrimSpace(h); h != "" {
+					if !containsFold(policy.AllowedHeaders, h) {
+						http.Error(w, "CORS header not allowed", http.StatusForbidden)
+						return
+					}
+					headers = append(headers, h)
+				}
+			}
+
+			c.setOriginHeaders(w, policy, origin)
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(policy.AllowedMethods, ", "))
+			if len(headers) > 0 {
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+			}
+			if policy.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if allowed {
+			c.setOriginHeaders(w, policy, origin)
+			if len(policy.ExposedHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(policy.ExposedHeaders, ", "))
+			}
+		}
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *CORS) setOriginHeaders(w http.ResponseWriter, policy Policy, origin string) {
+	if slices.Contains(policy.AllowedOrigins, "*") {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if policy.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// FromEnv builds the API's CORS policy: DefaultPolicyFromEnv plus the given
+// per-route overrides
+func FromEnv(routes ...Route) (*CORS, error) {
+	return New(DefaultPolicyFromEnv(), routes...)
+}
@@ -0,0 +1,88 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchOrigin(t *testing.T) {
+	patterns := []string{"https://app.example.com", "https://*.staging.example.com", "http://localhost:5173"}
+
+	cases := map[string]bool{
+		"https://app.example.com":               true,
+		"HTTPS://APP.EXAMPLE.COM":               true,
+		"http://localhost:5173":                 true,
+		"https://pr-1.staging.example.com":      true,
+		"https://a.b.staging.example.com":       true,
+		"https://staging.example.com":           false,
+		"http://pr-1.staging.example.com":       false,
+		"https://evilstaging.example.com":       false,
+		"https://app.example.com.evil.com":      false,
+		"https://pr-1.staging.example.com:8443": false,
+		"http://localhost:3000":                 false,
+		"null":                                  false,
+	}
+	for origin, want := range cases {
+		if got := MatchOrigin(patterns, origin); got != want {
+			t.Errorf("MatchOrigin(%q) = %t; want %t", origin, got, want)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	c, err := New(Policy{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type"},
+		AllowCredentials: true,
+	}, Route{Prefix: "/public", Policy: Policy{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}}})
+	if err != nil {
+		t.Fatalf("error creating CORS. Err: %v", err)
+	}
+	handler := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(method, path, origin string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("OPTIONS", "/auth/login", "https://app.example.com", map[string]string{
+		"Access-Control-Request-Method":  "POST",
+		"Access-Control-Request-Headers": "content-type",
+	})
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("expected allowed preflight; got %d %v", rec.Code, rec.Header())
+	}
+
+	rec = serve("OPTIONS", "/auth/login", "https://evil.example", map[string]string{"Access-Control-Request-Method": "POST"})
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected preflight from unknown origin to be refused; got %d", rec.Code)
+	}
+	rec = serve("OPTIONS", "/auth/login", "https://app.example.com", map[string]string{"Access-Control-Request-Method": "DELETE"})
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected preflight for DELETE to be refused; got %d", rec.Code)
+	}
+
+	rec = serve("GET", "/auth/me", "https://evil.example", nil)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" || rec.Header().Get("Vary") != "Origin" {
+		t.Errorf("expected no CORS headers but Vary: Origin; got %v", rec.Header())
+	}
+
+	rec = serve("GET", "/public/keys", "https://anyone.example", nil)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" || rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("expected the /public override to allow any origin without credentials; got %v", rec.Header())
+	}
+
+	if _, err := New(Policy{AllowedOrigins: []string{"*"}, AllowCredentials: true}); err == nil {
+		t.Errorf(`expected "*" with credentials to be refused`)
+	}
+}
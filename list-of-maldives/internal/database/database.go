@@ -33,16 +33,10 @@ func (s *service) GormDB() *gorm.DB {
 }
 
 // ---------------------------------------------------------------------
-// Environment variables (required)
+// Environment variables (required) – read by New, so packages importing
+// this one can be tested without them
 // ---------------------------------------------------------------------
-var (
-	dbName   = mustEnv("BLUEPRINT_DB_DATABASE")
-	password = mustEnv("BLUEPRINT_DB_PASSWORD")
-	username = mustEnv("BLUEPRINT_DB_USERNAME")
-	host     = mustEnv("BLUEPRINT_DB_HOST")
-	portStr  = mustEnv("BLUEPRINT_DB_PORT")
-	schema   = os.Getenv("BLUEPRINT_DB_SCHEMA")
-)
+var dbName, password, username, host, portStr, schema string
 
 // singleton
 var dbInstance *service
@@ -63,6 +57,13 @@ func New() Service {
 		return dbInstance
 	}
 
+	dbName = mustEnv("BLUEPRINT_DB_DATABASE")
+	password = mustEnv("BLUEPRINT_DB_PASSWORD")
+	username = mustEnv("BLUEPRINT_DB_USERNAME")
+	host = mustEnv("BLUEPRINT_DB_HOST")
+	portStr = mustEnv("BLUEPRINT_DB_PORT")
+	schema = os.Getenv("BLUEPRINT_DB_SCHEMA")
+
 	// ---- validate port -------------------------------------------------
 	port, err := strconv.Atoi(portStr)
 	if err != nil || port <= 0 || port > 65535 {
@@ -0,0 +1,85 @@
+// Package dbtest gives tests a throwaway PostgreSQL schema
+package dbtest
+
+import (
+	"database/sql"
+	"fmt"
+	"list-of-maldives/internal/database"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// service is a database.Service over one test's schema
+type service struct {
+	db *gorm.DB
+}
+
+func (s *service) Health() map[string]string { return map[string]string{"status": "up"} }
+func (s *service) GormDB() *gorm.DB          { return s.db }
+
+func (s *service) Close() error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// Open connects to the database in TEST_DATABASE_URL, creates a schema of
+// its own for t with tables for models, and drops it when t ends. The test
+// is skipped when TEST_DATABASE_URL is not set.
+func Open(t *testing.T, models ...interface{}) database.Service {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("failed to connect to the test database: %v", err)
+	}
+	schema := "test_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		t.Fatalf("failed to create schema %s: %v", schema, err)
+	}
+
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		t.Fatalf("invalid TEST_DATABASE_URL: %v", err)
+	}
+	config.RuntimeParams["search_path"] = schema
+	conn := stdlib.OpenDB(*config)
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open gorm: %v", err)
+	}
+	s := &service{db: db}
+
+	t.Cleanup(func() {
+		s.Close()
+		if _, err := admin.Exec(fmt.Sprintf("DROP SCHEMA %s CASCADE", schema)); err != nil {
+			t.Errorf("failed to drop schema %s: %v", schema, err)
+		}
+		admin.Close()
+	})
+
+	for _, model := range models {
+		if err := db.AutoMigrate(model); err != nil {
+			t.Fatalf("failed to migrate %T: %v", model, err)
+		}
+	}
+	return s
+}
@@ -0,0 +1,66 @@
+// mailer/mailer.go
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"list-of-maldives/internal/config"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional email (verification, password reset, sign-in links)
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// FromEnv picks the mailer selected by MAIL_DRIVER: "smtp", "file" or "log"
+// (the default outside production). The log driver prints the links in
+// emails, so production has to choose another one.
+func FromEnv() (Mailer, error) {
+	from := config.String("MAIL_FROM", "no-reply@localhost")
+
+	driver := config.String("MAIL_DRIVER", "log")
+	if config.IsProduction() && driver == "log" {
+		return nil, fmt.Errorf("MAIL_DRIVER must be smtp or file in production; the log driver writes sign-in links to the server log")
+	}
+
+	switch driver {
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return nil, fmt.Errorf("SMTP_HOST is required for MAIL_DRIVER=smtp")
+		}
+		return &SMTPMailer{
+			Host:     host,
+			Port:     config.Int("SMTP_PORT", 587),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}, nil
+	case "file":
+		return &FileMailer{Dir: config.String("MAIL_FILE_DIR", "tmp/mail"), From: from}, nil
+	case "log":
+		return &LogMailer{From: from}, nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_DRIVER %q", driver)
+	}
+}
+
+// LogMailer prints messages to the server log; meant for local development
+type LogMailer struct {
+	From string
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("mail from=%s to=%s subject=%q\n%s", m.From, msg.To, msg.Subject, msg.Body)
+	return nil
+}
@@ -0,0 +1,28 @@
+package mailer
+
+import "testing"
+
+func TestFromEnvRefusesLogDriverInProduction(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "production")
+	for _, driver := range []string{"", "log"} {
+		t.Setenv("MAIL_DRIVER", driver)
+		if _, err := FromEnv(); err == nil {
+			t.Errorf("expected MAIL_DRIVER=%q to be refused in production", driver)
+		}
+	}
+
+	t.Setenv("MAIL_DRIVER", "file")
+	if m, err := FromEnv(); err != nil {
+		t.Fatalf("expected the file driver to be accepted: %v", err)
+	} else if _, ok := m.(*FileMailer); !ok {
+		t.Errorf("expected a FileMailer; got %T", m)
+	}
+
+	t.Setenv("ENVIRONMENT", "development")
+	t.Setenv("MAIL_DRIVER", "")
+	if m, err := FromEnv(); err != nil {
+		t.Fatalf("expected the log driver by default outside production: %v", err)
+	} else if _, ok := m.(*LogMailer); !ok {
+		t.Errorf("expected a LogMailer; got %T", m)
+	}
+}
@@ -0,0 +1,40 @@
+// ratelimit/memory.go
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps buckets in this process only. Full buckets are dropped
+// at most once a minute.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]time.Time), now: time.Now}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	tat, result := limit.Allow(s.buckets[key], now)
+	s.buckets[key] = tat
+
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, t := range s.buckets {
+			if t.Before(now) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	return result, nil
+}
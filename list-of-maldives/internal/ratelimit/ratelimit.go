@@ -0,0 +1,147 @@
+// ratelimit/ratelimit.go
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests per Period with bursts of up to Burst requests
+// (Requests when zero). It is enforced as a token bucket using GCRA, which
+// only needs one timestamp per key, so shared stores stay cheap.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// Result describes the bucket after a request, for the RateLimit-* headers
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until a denied request would be allowed
+	RetryAfter time.Duration
+}
+
+// Store keeps buckets per key. Take counts one request against key.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Requests)
+}
+
+// Allow applies one request at now to a bucket whose theoretical arrival time
+// is tat, and returns the tat to store
+func (l Limit) Allow(tat, now time.Time) (time.Time, Result) {
+	interval := l.interval()
+	burst := l.burst()
+
+	if tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(interval)
+	allowAt := next.Add(-interval * time.Duration(burst))
+
+	if now.Before(allowAt) {
+		return tat, Result{
+			Limit:      burst,
+			Reset:      tat.Sub(now),
+			RetryAfter: allowAt.Sub(now),
+		}
+	}
+	return next, Result{
+		Allowed:   true,
+		Limit:     burst,
+		Remaining: int(now.Sub(allowAt) / interval),
+		Reset:     next.Sub(now),
+	}
+}
+
+// Rule applies a Limit to requests whose path starts with Prefix. By names
+// what a bucket belongs to: "ip" or "user".
+type Rule struct {
+	Prefix string
+	Limit  Limit
+	By     string
+}
+
+// ParseRules reads rules separated by ";", each written as
+//
+//	<prefix> <requests>/<period> [burst=<n>] [by=ip|user]
+//
+// e.g. "/auth/login 10/1m by=ip; /api 120/1m burst=20 by=user"
+func ParseRules(spec string) ([]Rule, error) {
+	var rules []Rule
+	for _, entry := range strings.Split(spec, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("rate limit %q: expected <prefix> <requests>/<period>", entry)
+		}
+
+		rule := Rule{Prefix: fields[0], By: "ip"}
+		requests, period, ok := strings.Cut(fields[1], "/")
+		n, err := strconv.Atoi(requests)
+		if !ok || err != nil || n <= 0 {
+			return nil, fmt.Errorf("rate limit %q: invalid request count", entry)
+		}
+		d, err := time.ParseDuration(period)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("rate limit %q: invalid period", entry)
+		}
+		rule.Limit = Limit{Requests: n, Period: d}
+
+		for _, option := range fields[2:] {
+			name, value, _ := strings.Cut(option, "=")
+			switch name {
+			case "burst":
+				if rule.Limit.Burst, err = strconv.Atoi(value); err != nil || rule.Limit.Burst <= 0 {
+					return nil, fmt.Errorf("rate limit %q: invalid burst", entry)
+				}
+			case "by":
+				switch value {
+				case "ip", "user":
+				case "api_key":
+					// Nothing issues or validates API keys yet
+					return nil, fmt.Errorf("rate limit %q: by=api_key is not supported", entry)
+				default:
+					return nil, fmt.Errorf("rate limit %q: unknown key %q", entry, value)
+				}
+				rule.By = value
+			default:
+				return nil, fmt.Errorf("rate limit %q: unknown option %q", entry, name)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Match returns the rule with the longest prefix matching path
+func Match(rules []Rule, path string) (Rule, bool) {
+	var best Rule
+	found := false
+	for _, rule := range rules {
+		if strings.HasPrefix(path, rule.Prefix) && (!found || len(rule.Prefix) > len(best.Prefix)) {
+			best, found = rule, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreBurstAndRefill(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := Limit{Requests: 6, Period: time.Minute, Burst: 3}
+
+	for i := 2; i >= 0; i-- {
+		res, _ := store.Take(context.Background(), "k", limit)
+		if !res.Allowed || res.Remaining != i {
+			t.Fatalf("expected allowed with %d remaining; got %+v", i, res)
+		}
+	}
+
+	res, _ := store.Take(context.Background(), "k", limit)
+	if res.Allowed {
+		t.Fatalf("expected the fourth request in a burst to be denied")
+	}
+	if res.RetryAfter != 10*time.Second {
+		t.Errorf("expected retry after 10s; got %s", res.RetryAfter)
+	}
+	if res.Reset != 30*time.Second {
+		t.Errorf("expected reset in 30s; got %s", res.Reset)
+	}
+
+	// One token comes back every 10s
+	now = now.Add(10 * time.Second)
+	if res, _ := store.Take(context.Background(), "k", limit); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("expected a refilled token; got %+v", res)
+	}
+	if res, _ := store.Take(context.Background(), "other", limit); !res.Allowed || res.Remaining != 2 {
+		t.Errorf("expected keys to have separate buckets; got %+v", res)
+	}
+}
+
+func TestParseRulesAndMatch(t *testing.T) {
+	rules, err := ParseRules("/auth 60/1m; /auth/login 10/1m burst=3 by=ip ;/api 120/1m by=user")
+	if err != nil {
+		t.Fatalf("error parsing rules. Err: %v", err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("expected 3 rules; got %d", len(rules))
+	}
+
+	rule, ok := Match(rules, "/auth/login")
+	if !ok || rule.Prefix != "/auth/login" || rule.Limit.Burst != 3 {
+		t.Errorf("expected the longest prefix to win; got %+v", rule)
+	}
+	if rule, _ := Match(rules, "/auth/register"); rule.Prefix != "/auth" || rule.By != "ip" {
+		t.Errorf("expected /auth rule keyed by ip; got %+v", rule)
+	}
+	if _, ok := Match(rules, "/health"); ok {
+		t.Errorf("expected no rule for /health")
+	}
+
+	for _, bad := range []string{"/auth", "/auth ten/1m", "/auth 10/soon", "/auth 10/1m by=cookie", "/api 10/1m by=api_key", "/auth 10/1m burst=0"} {
+		if _, err := ParseRules(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
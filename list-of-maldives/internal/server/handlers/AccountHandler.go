@@ -0,0 +1,125 @@
+// handlers/account_handler.go
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"list-of-maldives/internal/config"
+	"list-of-maldives/internal/server/middleware"
+	"list-of-maldives/internal/server/models"
+	"log"
+	"net/http"
+	"time"
+)
+
+// DeleteAccountRequest needs CurrentPassword unless the account has no
+// password, in which case it must have signed in recently
+type DeleteAccountRequest struct {
+	CurrentPassword string `json:"current_password"`
+}
+
+// DeleteAccount deletes the current user's account and signs it out
+// everywhere. The account is erased for good after ACCOUNT_PURGE_GRACE;
+// until then support can restore it.
+func (h *AuthHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.UserContextKey).(*models.User)
+
+	var req DeleteAccountRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if !h.confirmIdentity(w, r, user, req.CurrentPassword) {
+		return
+	}
+
+	purgeAt := time.Now().Add(config.Duration("ACCOUNT_PURGE_GRACE", 30*24*time.Hour))
+	if err := models.DeleteOwnAccount(h.db, user, purgeAt); err != nil {
+		http.Error(w, "Failed to delete account", http.StatusInternalServerError)
+		return
+	}
+	h.forgetRevocationCutoff(user)
+	clearAuthCookies(w)
+	h.audit(r, models.AuditAccountDeleted, user, map[string]string{"purge_at": purgeAt.Format(time.RFC3339)})
+
+	h.notifyUser(user.Email, *user, "Your account was deleted",
+		fmt.Sprintf("Your account was deleted and you were signed out everywhere. Its data will be erased on %s; contact support before then if you want it back.",
+			purgeAt.Format("2 January 2006")))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":  "Account deleted",
+		"purge_at": purgeAt,
+	})
+}
+
+// ExportAccount downloads everything stored about the current user, as one
+// JSON document or, with ?format=zip, as a ZIP archive with a file per section
+func (h *AuthHandler) ExportAccount(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.UserContextKey).(*models.User)
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "zip" {
+		http.Error(w, "format must be json or zip", http.StatusBadRequest)
+		return
+	}
+
+	export, err := models.ExportUserData(h.db, user)
+	if err != nil {
+		http.Error(w, "Failed to export account data", http.StatusInternalServerError)
+		return
+	}
+	h.audit(r, models.AuditAccountExported, user, map[string]string{"format": format})
+
+	filename := fmt.Sprintf("list-of-maldives-export-%s.%s", export.ExportedAt.Format("2006-01-02"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Cache-Control", "no-store")
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(export)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	archive := zip.NewWriter(w)
+	for _, file := range []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", map[string]interface{}{
+			"exported_at":   export.ExportedAt,
+			"user":          export.Profile,
+			"status_reason": export.StatusReason,
+		}},
+		{"roles.json", export.Roles},
+		{"identities.json", export.Identities},
+		{"sessions.json", export.Sessions},
+		{"passkeys.json", export.Passkeys},
+		{"status_history.json", export.StatusHistory},
+		{"audit_events.json", export.AuditEvents},
+	} {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			log.Printf("failed to write export for user %s: %v", user.UUID, err)
+			return
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			log.Printf("failed to write export for user %s: %v", user.UUID, err)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		log.Printf("failed to write export for user %s: %v", user.UUID, err)
+	}
+}
@@ -0,0 +1,96 @@
+// handlers/audit_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"list-of-maldives/internal/server/middleware"
+	"list-of-maldives/internal/server/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// auditFilterFromQuery reads the ListAuditEvents query parameters
+func auditFilterFromQuery(r *http.Request) (models.AuditFilter, error) {
+	query := r.URL.Query()
+	filter := models.AuditFilter{
+		UserUUID: query.Get("user"),
+		Email:    query.Get("email"),
+		IP:       query.Get("ip"),
+		Cursor:   query.Get("cursor"),
+	}
+
+	if value := query.Get("type"); value != "" {
+		for _, t := range strings.Split(value, ",") {
+			filter.Types = append(filter.Types, models.AuditEventType(strings.TrimSpace(t)))
+		}
+	}
+	for name, target := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := query.Get(name); value != "" {
+			t, err := parseTimeParam(value)
+			if err != nil {
+				return filter, errors.New(name + " must be a date or RFC 3339 timestamp")
+			}
+			*target = t
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return filter, errors.New("limit must be a positive number")
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+// writeAuditEvents answers with one page of events matching filter
+func (h *AuthHandler) writeAuditEvents(w http.ResponseWriter, filter models.AuditFilter) {
+	events, next, err := models.ListAuditEvents(h.db, filter)
+	if errors.Is(err, models.ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to load audit events", http.StatusInternalServerError)
+		return
+	}
+	if events == nil {
+		events = []models.AuditEvent{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"events":      events,
+		"next_cursor": next,
+	})
+}
+
+// ListAuditEvents pages through the audit log, newest first. Filter with
+// ?type= (comma separated), ?user= (UUID), ?email=, ?ip=, ?since= and
+// ?until=, and pass the returned next_cursor as ?cursor= for the next page.
+func (h *AuthHandler) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := auditFilterFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.writeAuditEvents(w, filter)
+}
+
+// ListActivity is the current user's own audit log. It takes the same
+// parameters as ListAuditEvents, except that user, email and ip are ignored.
+func (h *AuthHandler) ListActivity(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.UserContextKey).(*models.User)
+
+	filter, err := auditFilterFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.UserUUID = user.UUID
+	filter.Email = ""
+	filter.IP = ""
+	h.writeAuditEvents(w, filter)
+}
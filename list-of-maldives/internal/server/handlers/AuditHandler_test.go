@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"list-of-maldives/internal/server/models"
+	"net/http"
+	"testing"
+)
+
+// decodeAuditEvents reads a page written by writeAuditEvents
+func decodeAuditEvents(t *testing.T, body *bytes.Buffer) ([]models.AuditEvent, string) {
+	t.Helper()
+	var page struct {
+		Events     []models.AuditEvent `json:"events"`
+		NextCursor string              `json:"next_cursor"`
+	}
+	if err := json.Unmarshal(body.Bytes(), &page); err != nil {
+		t.Fatalf("failed to decode audit events: %v", err)
+	}
+	return page.Events, page.NextCursor
+}
+
+func TestListAuditEventsEndpoint(t *testing.T) {
+	h, s, _ := newTestHandler(t)
+	admin := createUser(t, s, "admin@example.com", "password123", true)
+	for _, event := range []models.AuditEvent{
+		{Type: models.AuditLoginFailed, Email: "a@example.com", IP: "10.0.0.1"},
+		{Type: models.AuditLoginFailed, Email: "b@example.com", IP: "10.0.0.2"},
+		{Type: models.AuditLoginSucceeded, Email: "a@example.com", IP: "10.0.0.1"},
+	} {
+		if err := models.RecordAuditEvent(s, &event); err != nil {
+			t.Fatalf("failed to record audit event: %v", err)
+		}
+	}
+
+	rec := serve(h.ListAuditEvents, http.MethodGet, "/admin/audit-events?type=login.failed&ip=10.0.0.1", nil, admin)
+	expectStatus(t, rec, http.StatusOK)
+	events, next := decodeAuditEvents(t, rec.Body)
+	if len(events) != 1 || events[0].Email != "a@example.com" || next != "" {
+		t.Errorf("expected the one failed login from 10.0.0.1; got %+v, %q", events, next)
+	}
+
+	rec = serve(h.ListAuditEvents, http.MethodGet, "/admin/audit-events?limit=2", nil, admin)
+	expectStatus(t, rec, http.StatusOK)
+	events, next = decodeAuditEvents(t, rec.Body)
+	if len(events) != 2 || next == "" {
+		t.Fatalf("expected a full first page with a cursor; got %d events, %q", len(events), next)
+	}
+	rec = serve(h.ListAuditEvents, http.MethodGet, "/admin/audit-events?limit=2&cursor="+next, nil, admin)
+	expectStatus(t, rec, http.StatusOK)
+	if events, next = decodeAuditEvents(t, rec.Body); len(events) != 1 || next != "" {
+		t.Errorf("expected the last event on the second page; got %d events, %q", len(events), next)
+	}
+
+	for _, query := range []string{"limit=0", "since=yesterday", "cursor=not-a-cursor"} {
+		expectStatus(t, serve(h.ListAuditEvents, http.MethodGet, "/admin/audit-events?"+query, nil, admin), http.StatusBadRequest)
+	}
+}
+
+func TestListActivityOnlyShowsOwnEvents(t *testing.T) {
+	h, s, _ := newTestHandler(t)
+	user := createUser(t, s, "user@example.com", "password123", true)
+	other := createUser(t, s, "other@example.com", "password123", true)
+	for _, event := range []models.AuditEvent{
+		{Type: models.AuditLoginSucceeded, UserUUID: user.UUID, Email: user.Email},
+		{Type: models.AuditLoginSucceeded, UserUUID: other.UUID, Email: other.Email},
+		{Type: models.AuditLoginFailed, Email: user.Email},
+	} {
+		if err := models.RecordAuditEvent(s, &event); err != nil {
+			t.Fatalf("failed to record audit event: %v", err)
+		}
+	}
+
+	// Asking for someone else's events still only returns the user's own
+	rec := serve(h.ListActivity, http.MethodGet, "/auth/me/activity?user="+other.UUID+"&email="+other.Email, nil, user)
+	expectStatus(t, rec, http.StatusOK)
+	events, _ := decodeAuditEvents(t, rec.Body)
+	if len(events) != 1 || events[0].UserUUID != user.UUID {
+		t.Errorf("expected only the user's own event; got %+v", events)
+	}
+}
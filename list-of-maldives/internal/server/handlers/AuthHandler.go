@@ -4,13 +4,15 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/config"
 	"list-of-maldives/internal/database"
+	"list-of-maldives/internal/mailer"
 	"list-of-maldives/internal/server/middleware"
 	"list-of-maldives/internal/server/models"
+	"log"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -19,14 +21,35 @@ import (
 )
 
 type AuthHandler struct {
-	db         database.Service
-	jwtService *auth.JWTService
+	db          database.Service
+	jwtService  *auth.JWTService
+	revocations auth.RevocationStore
+	mailer      mailer.Mailer
+	mfaSecrets  *auth.SecretBox
+	passkeys    *auth.Passkeys
+	redirects   auth.RedirectPolicy
+	oauth       *auth.OAuthFlow
+
+	accountLockout auth.LockoutPolicy
+	ipLockout      auth.LockoutPolicy
+	// mfaMaxAttempts wrong codes revoke an MFA challenge
+	mfaMaxAttempts int
 }
 
-func NewAuthHandler(db database.Service, jwtService *auth.JWTService) *AuthHandler {
+func NewAuthHandler(db database.Service, jwtService *auth.JWTService, revocations auth.RevocationStore, mailer mailer.Mailer) *AuthHandler {
 	return &AuthHandler{
-		db:         db,
-		jwtService: jwtService,
+		db:          db,
+		jwtService:  jwtService,
+		revocations: revocations,
+		mailer:      mailer,
+		mfaSecrets:  newMFASecretBox(),
+		passkeys:    newPasskeys(),
+		redirects:   auth.RedirectPolicyFromEnv(),
+		oauth:       &auth.OAuthFlow{},
+
+		accountLockout: auth.LockoutPolicyFromEnv("LOGIN_ACCOUNT", defaultAccountLockout),
+		ipLockout:      auth.LockoutPolicyFromEnv("LOGIN_IP", defaultIPLockout),
+		mfaMaxAttempts: config.Int("MFA_MAX_ATTEMPTS", 5),
 	}
 }
 
@@ -42,63 +65,100 @@ type RegisterRequest struct {
 	Nickname string `json:"nickname"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 type AuthResponse struct {
-	Token string       `json:"token,omitempty"`
-	User  *models.User `json:"user"`
+	Token        string       `json:"token,omitempty"`
+	RefreshToken string       `json:"refresh_token,omitempty"`
+	ExpiresIn    int64        `json:"expires_in,omitempty"`
+	User         *models.User `json:"user"`
+}
+
+// GetProviders lists the configured OAuth/OIDC providers for the login page
+func (h *AuthHandler) GetProviders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"providers": auth.Providers()})
 }
 
 // GetAuth initiates OAuth authentication flow
 func (h *AuthHandler) GetAuth(w http.ResponseWriter, r *http.Request) {
 	provider := mux.Vars(r)["provider"]
 
-	// Set the provider in the context for Gothic
-	r = r.WithContext(context.WithValue(r.Context(), "provider", provider))
+	// Remember ?return_to= so the callback can send the user back to their deep link
+	if !h.rememberReturnTo(w, r) {
+		return
+	}
 
-	// Begin the OAuth authentication process
-	gothic.BeginAuthHandler(w, r)
+	// Begin the OAuth authentication process with PKCE and a session-bound state
+	authURL, err := h.oauth.Begin(w, r, provider)
+	if err != nil {
+		http.Error(w, "Unknown provider", http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
 }
 
 // GetAuthCallback handles OAuth callback and creates user in DB
 func (h *AuthHandler) GetAuthCallback(w http.ResponseWriter, r *http.Request) {
 	provider := mux.Vars(r)["provider"]
 
-	r = r.WithContext(context.WithValue(r.Context(), "provider", provider))
+	linkUserUUID := pendingLink(r)
+	returnTo := h.takeReturnTo(w, r)
 
-	user, err := gothic.CompleteUserAuth(w, r)
+	user, err := h.oauth.Complete(w, r, provider)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error completing authentication: %v", err), http.StatusInternalServerError)
+		log.Printf("error completing %s authentication: %v", provider, err)
+		h.audit(r, models.AuditOAuthFailed, nil, map[string]string{"provider": provider, "error": err.Error()})
+		h.redirectAuthError(w, r, authErrorOAuthFailed)
+		return
+	}
+
+	// Flow started from POST /auth/me/identities/{provider}
+	if linkUserUUID != "" {
+		h.completeLink(w, r, provider, linkUserUUID, user, returnTo)
 		return
 	}
 
 	// Find or create user in database
-	dbUser, err := models.FindOrCreateByProvider(h.db, provider, user.UserID, user.Email, user.NickName)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error creating user: %v", err), http.StatusInternalServerError)
+	started := time.Now()
+	dbUser, err := models.FindOrCreateByProvider(h.db, provider, user.UserID, user.Email, user.NickName, auth.EmailVerified(provider, user))
+	if errors.Is(err, models.ErrEmailInUse) {
+		// Sign in another way and link this provider from the profile
+		h.audit(r, models.AuditOAuthFailed, &models.User{Email: user.Email}, map[string]string{"provider": provider, "reason": "email_in_use"})
+		h.redirectAuthError(w, r, authErrorEmailInUse)
+		return
+	} else if errors.Is(err, models.ErrAccountDeleted) {
+		h.audit(r, models.AuditOAuthFailed, &models.User{Email: user.Email}, map[string]string{"provider": provider, "reason": "account_deleted"})
+		h.redirectAuthError(w, r, authErrorAccountDeleted)
 		return
+	} else if err != nil {
+		log.Printf("error creating user from %s login: %v", provider, err)
+		h.redirectAuthError(w, r, authErrorServer)
+		return
+	}
+	// Claiming an unverified account signed it out everywhere
+	h.forgetRevocationCutoff(dbUser)
+	if !dbUser.CreatedAt.Before(started) {
+		h.audit(r, models.AuditRegistered, dbUser, map[string]string{"provider": provider})
 	}
-	fmt.Println("User created:", dbUser)
 
-	// Generate JWT token for OAuth user
-	token, err := h.jwtService.GenerateToken(dbUser.UUID, dbUser.Email)
-	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+	// The provider only stands in for the password; the login page
+	// completes the second factor
+	if dbUser.MFAEnabled {
+		h.redirectMFAChallenge(w, r, dbUser, provider)
 		return
 	}
 
-	// Set HTTP-only cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "auth_token",
-		Value:    token,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   os.Getenv("ENVIRONMENT") == "production",
-		SameSite: http.SameSiteLaxMode,
-		Expires:  time.Now().Add(24 * time.Hour),
-	})
+	// Generate access and refresh tokens for OAuth user
+	if _, err := h.startSession(w, r, dbUser, provider); err != nil {
+		h.redirectAuthError(w, r, sessionErrorCode(err))
+		return
+	}
 
-	// Redirect to frontend with success
-	frontendURL := os.Getenv("FRONTEND_URL")
-	http.Redirect(w, r, frontendURL+"?auth=success", http.StatusSeeOther)
+	// Redirect to the deep link, or the frontend, with success
+	h.redirectWithParam(w, r, returnTo, "auth", "success")
 }
 
 // Register handles email/password registration
@@ -116,10 +176,14 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 
 	db := h.db.GormDB()
 
-	// Check if user already exists
+	// Check if user already exists, whichever way they signed up. Deleted
+	// accounts keep their email until they are purged.
 	var existingUser models.User
-	err := db.Where("email = ? AND provider = 'email'", req.Email).First(&existingUser).Error
-	if err == nil {
+	err := db.Unscoped().Where("email = ?", req.Email).First(&existingUser).Error
+	if err == nil && existingUser.DeletedAt.Valid {
+		http.Error(w, "An account with this email was deleted", http.StatusConflict)
+		return
+	} else if err == nil {
 		http.Error(w, "User already exists", http.StatusBadRequest)
 		return
 	} else if err != gorm.ErrRecordNotFound {
@@ -139,28 +203,19 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to create user", http.StatusInternalServerError)
 		return
 	}
+	h.audit(r, models.AuditRegistered, &user, map[string]string{"provider": "email"})
 
-	// Generate JWT token
-	token, err := h.jwtService.GenerateToken(user.UUID, user.Email)
-	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
-		return
+	// Ask the user to confirm their address; registration succeeds either way
+	markVerificationSent(db, &user)
+	if err := h.sendVerificationEmail(r.Context(), &user); err != nil {
+		log.Printf("failed to send verification email to user %s: %v", user.UUID, err)
 	}
 
-	// Set HTTP-only cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "auth_token",
-		Value:    token,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   os.Getenv("ENVIRONMENT") == "production",
-		SameSite: http.SameSiteLaxMode,
-		Expires:  time.Now().Add(24 * time.Hour),
-	})
-
-	response := AuthResponse{
-		Token: token,
-		User:  &user,
+	// Generate access and refresh tokens
+	response, err := h.startSession(w, r, &user, "email")
+	if err != nil {
+		writeSessionError(w, err)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -180,47 +235,120 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuse guesses while the account or this client is locked out
+	if h.loginBlocked(w, r, req.Email) {
+		return
+	}
+
 	// Find user by email
 	db := h.db.GormDB()
 	var user models.User
 	err := db.Where("email = ?", req.Email).First(&user).Error
 	if err != nil {
+		h.recordLoginFailure(r, req.Email, nil)
 		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
 		return
 	}
 
-	if user.Provider != "email" {
+	// OAuth-only accounts have no password to check
+	if user.Password == "" {
 		http.Error(w, "Please use the correct login method", http.StatusUnauthorized)
 		return
 	}
 
 	if !user.CheckPassword(req.Password) {
+		h.recordLoginFailure(r, req.Email, &user)
 		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
 		return
 	}
+	// With MFA the failures are only forgiven once the second factor is
+	// proven too, so a known password can't reset the count between codes
+	if !user.MFAEnabled {
+		h.clearLoginFailures(req.Email)
+	}
 
-	// Generate JWT token
-	token, err := h.jwtService.GenerateToken(user.UUID, user.Email)
+	// Only tell a suspended account apart once the password is proven
+	if err := user.CanSignIn(); err != nil {
+		h.audit(r, models.AuditLoginBlocked, &user, map[string]string{"provider": "email", "reason": err.Error()})
+		writeSessionError(w, err)
+		return
+	}
+	// An admin asked for a new password; the reset link was emailed
+	if user.MustResetPassword {
+		h.audit(r, models.AuditLoginBlocked, &user, map[string]string{"provider": "email", "reason": "password reset required"})
+		http.Error(w, "A password reset is required, check your email for the link", http.StatusForbidden)
+		return
+	}
+
+	// Accounts with MFA get a challenge to complete at /auth/mfa/verify
+	if user.MFAEnabled {
+		h.respondMFAChallenge(w, r, &user)
+		return
+	}
+
+	// Generate access and refresh tokens
+	response, err := h.startSession(w, r, &user, "email")
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Refresh exchanges a refresh token for a new access token, rotating the refresh token
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	raw := refreshTokenFromRequest(r)
+	if raw == "" {
+		http.Error(w, "Refresh token required", http.StatusUnauthorized)
+		return
+	}
+
+	next, rotated, err := models.RotateRefreshToken(h.db, raw, h.jwtService.RefreshTTL())
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		clearAuthCookies(w)
+		if errors.Is(err, models.ErrRefreshTokenReused) {
+			// A rotated-out token came back: the family was revoked as stolen
+			h.audit(r, models.AuditTokenReused, nil, nil)
+		}
+		if errors.Is(err, models.ErrRefreshTokenReused) || errors.Is(err, models.ErrRefreshTokenInvalid) {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
 		return
 	}
 
-	// Set HTTP-only cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "auth_token",
-		Value:    token,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   os.Getenv("ENVIRONMENT") == "production",
-		SameSite: http.SameSiteLaxMode,
-		Expires:  time.Now().Add(24 * time.Hour),
-	})
+	var user models.User
+	if err := h.db.GormDB().First(&user, rotated.UserID).Error; err != nil {
+		clearAuthCookies(w)
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	// The device session lives as long as its refresh tokens keep rotating
+	session, err := models.RefreshSession(h.db, user.ID, rotated.FamilyID, middleware.ClientIP(r), r.UserAgent(), rotated.ExpiresAt)
+	if err != nil {
+		clearAuthCookies(w)
+		if errors.Is(err, models.ErrSessionRevoked) {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
 
-	response := AuthResponse{
-		Token: token,
-		User:  &user,
+	response, err := h.issueTokens(w, &user, session.UUID, next, rotated.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, models.ErrAccountSuspended) || errors.Is(err, models.ErrAccountDeleted) {
+			// The account was blocked after this device signed in
+			h.audit(r, models.AuditLoginBlocked, &user, map[string]string{"provider": "refresh", "reason": err.Error(), "session_id": session.UUID})
+		}
+		writeSessionError(w, err)
+		return
 	}
+	h.audit(r, models.AuditTokenRefreshed, &user, map[string]string{"session_id": session.UUID})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -228,16 +356,37 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 // Logout handles user logout
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
-	// Clear the auth cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "auth_token",
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   os.Getenv("ENVIRONMENT") == "production",
-		SameSite: http.SameSiteLaxMode,
-		Expires:  time.Now().Add(-time.Hour),
-	})
+	// Denylist the access token so it stops working before it expires
+	if claims, ok := r.Context().Value(middleware.ClaimsContextKey).(*auth.Claims); ok && claims.ID != "" {
+		if err := h.revocations.RevokeToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+			http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Revoke the refresh token family so it can't mint new access tokens
+	if raw := refreshTokenFromRequest(r); raw != "" {
+		if err := models.RevokeRefreshToken(h.db, raw); err != nil {
+			http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Bearer clients may not send their refresh token; end the device session by its sid
+	claims, _ := r.Context().Value(middleware.ClaimsContextKey).(*auth.Claims)
+	user, _ := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if claims != nil && user != nil && claims.SessionID != "" {
+		if err := models.RevokeSession(h.db, user.ID, claims.SessionID); err != nil && !errors.Is(err, models.ErrSessionNotFound) {
+			http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Clear the auth cookies
+	clearAuthCookies(w)
+	if user != nil {
+		h.audit(r, models.AuditLogout, user, nil)
+	}
 
 	// Also handle OAuth logout if provider is specified
 	provider := mux.Vars(r)["provider"]
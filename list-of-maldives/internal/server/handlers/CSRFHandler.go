@@ -0,0 +1,24 @@
+// handlers/csrf_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"list-of-maldives/internal/server/middleware"
+	"net/http"
+)
+
+// CSRFToken returns the CSRF token to send as X-CSRF-Token, setting the
+// csrf_token cookie if the browser doesn't have a valid one yet
+func CSRFToken(csrf *middleware.CSRF) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := csrf.Token(w, r)
+		if err != nil {
+			http.Error(w, "Failed to generate CSRF token", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(map[string]string{"csrf_token": token})
+	}
+}
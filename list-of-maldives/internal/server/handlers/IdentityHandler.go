@@ -0,0 +1,136 @@
+// handlers/identity_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"list-of-maldives/internal/server/middleware"
+	"list-of-maldives/internal/server/models"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/gothic"
+)
+
+// linkSessionName is a separate gothic-store session remembering which signed-in
+// user started a link flow, so the OAuth callback links instead of signing in
+const linkSessionName = "_link_session"
+
+// ListIdentities returns the external logins linked to the current user
+func (h *AuthHandler) ListIdentities(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.UserContextKey).(*models.User)
+
+	identities, err := models.ListIdentities(h.db, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to load identities", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"identities":   identities,
+		"has_password": user.Password != "",
+	})
+}
+
+// LinkIdentity starts an OAuth flow whose callback attaches the provider login to
+// the current user. It returns the provider URL for the browser to navigate to.
+func (h *AuthHandler) LinkIdentity(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.UserContextKey).(*models.User)
+	provider := mux.Vars(r)["provider"]
+
+	if _, err := goth.GetProvider(provider); err != nil {
+		http.Error(w, "Unknown provider", http.StatusNotFound)
+		return
+	}
+
+	if !h.rememberReturnTo(w, r) {
+		return
+	}
+
+	session, _ := gothic.Store.New(r, linkSessionName)
+	session.Values["user_uuid"] = user.UUID
+	session.Options.MaxAge = 10 * 60
+	if err := session.Save(r, w); err != nil {
+		http.Error(w, "Failed to start link flow", http.StatusInternalServerError)
+		return
+	}
+
+	authURL, err := h.oauth.Begin(w, r, provider)
+	if err != nil {
+		http.Error(w, "Failed to start link flow", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"auth_url": authURL})
+}
+
+// UnlinkIdentity removes a provider login from the current user
+func (h *AuthHandler) UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.UserContextKey).(*models.User)
+	provider := mux.Vars(r)["provider"]
+
+	err := models.UnlinkIdentity(h.db, user, provider)
+	switch {
+	case errors.Is(err, models.ErrIdentityNotFound):
+		http.Error(w, "Identity not found", http.StatusNotFound)
+		return
+	case errors.Is(err, models.ErrLastLoginMethod):
+		http.Error(w, "Cannot remove the only way to sign in", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to unlink identity", http.StatusInternalServerError)
+		return
+	}
+	h.audit(r, models.AuditIdentityUnlinked, user, map[string]string{"provider": provider})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pendingLink returns the user UUID of a link flow started in this browser, if any
+func pendingLink(r *http.Request) string {
+	session, err := gothic.Store.Get(r, linkSessionName)
+	if err != nil {
+		return ""
+	}
+	uuid, _ := session.Values["user_uuid"].(string)
+	return uuid
+}
+
+func clearPendingLink(w http.ResponseWriter, r *http.Request) {
+	session, err := gothic.Store.Get(r, linkSessionName)
+	if err != nil {
+		return
+	}
+	session.Options.MaxAge = -1
+	session.Save(r, w)
+}
+
+// completeLink attaches an OAuth login to the signed-in user that started the flow
+func (h *AuthHandler) completeLink(w http.ResponseWriter, r *http.Request, provider, userUUID string, user goth.User, returnTo string) {
+	clearPendingLink(w, r)
+
+	current, ok := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if !ok || current.UUID != userUUID {
+		h.redirectAuthError(w, r, authErrorReauthRequired)
+		return
+	}
+
+	_, err := models.LinkIdentity(h.db, current, provider, user.UserID, user.Email)
+	switch {
+	case errors.Is(err, models.ErrIdentityLinked):
+		h.redirectAuthError(w, r, authErrorIdentityLinked)
+		return
+	case errors.Is(err, models.ErrProviderAlreadyInUse):
+		h.redirectAuthError(w, r, authErrorProviderInUse)
+		return
+	case err != nil:
+		h.redirectAuthError(w, r, authErrorServer)
+		return
+	}
+	h.audit(r, models.AuditIdentityLinked, current, map[string]string{"provider": provider})
+
+	h.redirectWithParam(w, r, returnTo, "link", "success")
+}
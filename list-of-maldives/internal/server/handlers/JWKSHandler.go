@@ -0,0 +1,17 @@
+// handlers/jwks_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"list-of-maldives/internal/auth"
+	"net/http"
+)
+
+// JWKS serves the public signing keys so other services can verify our tokens
+func JWKS(jwtService *auth.JWTService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		json.NewEncoder(w).Encode(jwtService.JWKS())
+	}
+}
@@ -0,0 +1,210 @@
+// handlers/lockout_handler.go
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/config"
+	"list-of-maldives/internal/mailer"
+	"list-of-maldives/internal/server/middleware"
+	"list-of-maldives/internal/server/models"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+type UnlockAccountRequest struct {
+	Token string `json:"token"`
+}
+
+// Failed logins per account delay and then lock that account; failures per
+// client IP slow down guessing across many accounts
+var (
+	defaultAccountLockout = auth.LockoutPolicy{
+		FreeAttempts:     5,
+		BaseDelay:        time.Second,
+		MaxDelay:         time.Minute,
+		LockoutThreshold: 10,
+		LockoutDuration:  30 * time.Minute,
+		Window:           time.Hour,
+	}
+	defaultIPLockout = auth.LockoutPolicy{
+		FreeAttempts:     20,
+		BaseDelay:        time.Second,
+		MaxDelay:         time.Minute,
+		LockoutThreshold: 100,
+		LockoutDuration:  time.Hour,
+		Window:           time.Hour,
+	}
+)
+
+// writeTooManyRequests answers 429 with Retry-After rounded up to whole seconds
+func writeTooManyRequests(w http.ResponseWriter, wait time.Duration, message string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, message, http.StatusTooManyRequests)
+}
+
+// loginBlocked answers 429 if the account or the client IP is currently blocked
+func (h *AuthHandler) loginBlocked(w http.ResponseWriter, r *http.Request, email string) bool {
+	wait, err := models.LoginBlockedFor(h.db, models.AccountThrottleKey(email), models.IPThrottleKey(middleware.ClientIP(r)))
+	if err != nil {
+		log.Printf("login throttle check failed: %v", err)
+		return false
+	}
+	if wait > 0 {
+		h.audit(r, models.AuditLoginBlocked, &models.User{Email: email}, map[string]string{"reason": "throttled"})
+		writeTooManyRequests(w, wait, "Too many failed login attempts, try again later")
+		return true
+	}
+	return false
+}
+
+// recordLoginFailure counts a failed login for the account and the client IP.
+// user is nil when no account has this email; otherwise it is emailed an
+// unlock link whenever a failure locks the account.
+func (h *AuthHandler) recordLoginFailure(r *http.Request, email string, user *models.User) {
+	if user != nil {
+		h.audit(r, models.AuditLoginFailed, user, nil)
+	} else {
+		h.audit(r, models.AuditLoginFailed, &models.User{Email: email}, map[string]string{"reason": "unknown_account"})
+	}
+	h.countLoginFailure(r, email, user)
+}
+
+// countLoginFailure is recordLoginFailure without the audit event, for
+// failures that are audited as something else, such as a wrong MFA code
+func (h *AuthHandler) countLoginFailure(r *http.Request, email string, user *models.User) {
+	if _, err := models.RecordLoginFailure(h.db, models.IPThrottleKey(middleware.ClientIP(r)), h.ipLockout); err != nil {
+		log.Printf("failed to record login failure: %v", err)
+	}
+
+	throttle, err := models.RecordLoginFailure(h.db, models.AccountThrottleKey(email), h.accountLockout)
+	if err != nil {
+		log.Printf("failed to record login failure: %v", err)
+		return
+	}
+	if user != nil && throttle.Locked {
+		go h.sendUnlockEmail(*user)
+	}
+}
+
+// clearLoginFailures resets the account counter after a successful login
+func (h *AuthHandler) clearLoginFailures(email string) {
+	if _, err := models.ClearLoginThrottle(h.db, models.AccountThrottleKey(email)); err != nil {
+		log.Printf("failed to clear login failures: %v", err)
+	}
+}
+
+func (h *AuthHandler) sendUnlockEmail(user models.User) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	ttl := h.accountLockout.LockoutDuration
+	token, err := h.jwtService.GenerateActionToken(auth.PurposeUnlock, user.UUID, user.Email, ttl)
+	if err != nil {
+		log.Printf("failed to create unlock token for user %s: %v", user.UUID, err)
+		return
+	}
+
+	link := config.String("ACCOUNT_UNLOCK_URL", os.Getenv("FRONTEND_URL")+"/unlock-account")
+	link += "?token=" + url.QueryEscape(token)
+
+	err = h.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your account has been locked",
+		Body: fmt.Sprintf("Hi %s,\n\nSign-in to your account was locked after too many failed password attempts. It unlocks by itself in %s, or you can unlock it now:\n\n%s\n\nIf these attempts weren't you, consider resetting your password.\n",
+			user.NickName, ttl, link),
+	})
+	if err != nil {
+		log.Printf("failed to send unlock email to user %s: %v", user.UUID, err)
+	}
+}
+
+// UnlockAccount clears a login lockout using the emailed unlock token
+func (h *AuthHandler) UnlockAccount(w http.ResponseWriter, r *http.Request) {
+	var req UnlockAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := h.jwtService.ValidateActionToken(req.Token, auth.PurposeUnlock)
+	if err != nil {
+		http.Error(w, "Invalid or expired unlock link", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := models.ClearLoginThrottle(h.db, models.AccountThrottleKey(claims.Email)); err != nil {
+		http.Error(w, "Failed to unlock account", http.StatusInternalServerError)
+		return
+	}
+	h.audit(r, models.AuditAccountUnlocked, &models.User{UUID: claims.UserID, Email: claims.Email}, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Account unlocked, you can sign in again"})
+}
+
+// ListLoginThrottles shows failed-login counters for support. Filter with
+// ?email=, ?ip= or ?key=, and ?blocked=true for keys that are blocked now.
+func (h *AuthHandler) ListLoginThrottles(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	prefix := query.Get("key")
+	switch {
+	case query.Get("email") != "":
+		prefix = models.AccountThrottleKey(query.Get("email"))
+	case query.Get("ip") != "":
+		prefix = models.IPThrottleKey(query.Get("ip"))
+	}
+	blocked, _ := strconv.ParseBool(query.Get("blocked"))
+
+	throttles, err := models.ListLoginThrottles(h.db, prefix, blocked, 100)
+	if err != nil {
+		http.Error(w, "Failed to load login throttles", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"throttles": throttles})
+}
+
+// ClearLoginThrottle lifts a lockout for support, by ?email=, ?ip= or ?key=
+func (h *AuthHandler) ClearLoginThrottle(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	key := query.Get("key")
+	switch {
+	case query.Get("email") != "":
+		key = models.AccountThrottleKey(query.Get("email"))
+	case query.Get("ip") != "":
+		key = models.IPThrottleKey(query.Get("ip"))
+	}
+	if key == "" {
+		http.Error(w, "email, ip or key is required", http.StatusBadRequest)
+		return
+	}
+
+	cleared, err := models.ClearLoginThrottle(h.db, key)
+	if err != nil {
+		http.Error(w, "Failed to clear login throttle", http.StatusInternalServerError)
+		return
+	}
+	if !cleared {
+		http.Error(w, "No failed logins recorded for this key", http.StatusNotFound)
+		return
+	}
+
+	var subject *models.User
+	if email := query.Get("email"); email != "" {
+		subject = &models.User{Email: email}
+	}
+	h.audit(r, models.AuditThrottleCleared, subject, map[string]string{"key": key})
+
+	w.WriteHeader(http.StatusNoContent)
+}
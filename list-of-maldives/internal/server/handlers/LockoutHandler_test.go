@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"list-of-maldives/internal/auth"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLoginLocksOutAfterRepeatedFailures(t *testing.T) {
+	h, s, mail := newTestHandler(t)
+	h.accountLockout = auth.LockoutPolicy{FreeAttempts: 2, BaseDelay: time.Minute, MaxDelay: time.Minute, LockoutThreshold: 3, LockoutDuration: time.Hour, Window: time.Hour}
+	user := createUser(t, s, "locked@example.com", "password123", true)
+	wrong := LoginRequest{Email: user.Email, Password: "wrong-password"}
+
+	for i := 0; i < 2; i++ {
+		expectStatus(t, serve(h.Login, http.MethodPost, "/auth/login", wrong, nil), http.StatusUnauthorized)
+	}
+	// The third failure is over the free attempts and locks the account
+	expectStatus(t, serve(h.Login, http.MethodPost, "/auth/login", wrong, nil), http.StatusUnauthorized)
+
+	// Even the right password is refused while locked
+	rec := serve(h.Login, http.MethodPost, "/auth/login", LoginRequest{Email: user.Email, Password: "password123"}, nil)
+	expectStatus(t, rec, http.StatusTooManyRequests)
+	if rec.Header().Get("Retry-After") == "" {
+		t.Errorf("expected a Retry-After header")
+	}
+
+	// The emailed unlock link lifts the lockout
+	token := tokenFromEmail(t, mail.last(t, user.Email))
+	expectStatus(t, serve(h.UnlockAccount, http.MethodPost, "/auth/unlock", UnlockAccountRequest{Token: token}, nil), http.StatusOK)
+	expectStatus(t, serve(h.Login, http.MethodPost, "/auth/login", LoginRequest{Email: user.Email, Password: "password123"}, nil), http.StatusOK)
+}
+
+func TestLoginFailuresForUnknownAccountsCountPerIP(t *testing.T) {
+	h, _, _ := newTestHandler(t)
+	h.ipLockout = auth.LockoutPolicy{FreeAttempts: 1, BaseDelay: time.Minute, MaxDelay: time.Minute, Window: time.Hour}
+
+	// Guessing across many accounts from one address is slowed down too
+	expectStatus(t, serve(h.Login, http.MethodPost, "/auth/login", LoginRequest{Email: "a@example.com", Password: "guess"}, nil), http.StatusUnauthorized)
+	expectStatus(t, serve(h.Login, http.MethodPost, "/auth/login", LoginRequest{Email: "b@example.com", Password: "guess"}, nil), http.StatusUnauthorized)
+	expectStatus(t, serve(h.Login, http.MethodPost, "/auth/login", LoginRequest{Email: "c@example.com", Password: "guess"}, nil), http.StatusTooManyRequests)
+}
@@ -0,0 +1,299 @@
+// handlers/mfa_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/config"
+	"list-of-maldives/internal/server/middleware"
+	"list-of-maldives/internal/server/models"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const recoveryCodeCount = 10
+
+const (
+	// mfaChallengeCookie carries the challenge of a browser sign-in that
+	// ended in a redirect, so the token never appears in a URL
+	mfaChallengeCookie = "mfa_challenge"
+	mfaPath            = "/auth/mfa"
+)
+
+type TOTPConfirmRequest struct {
+	Code string `json:"code"`
+}
+
+type MFAVerifyRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// newMFASecretBox returns the box TOTP secrets are encrypted with, or nil
+// when MFA_ENCRYPTION_KEY isn't set and enrollment is unavailable
+func newMFASecretBox() *auth.SecretBox {
+	box, err := auth.NewSecretBox(os.Getenv("MFA_ENCRYPTION_KEY"))
+	if err != nil {
+		log.Printf("MFA enrollment disabled: %v", err)
+		return nil
+	}
+	return box
+}
+
+// SetupTOTP generates a new TOTP secret for the current user. MFA stays off
+// until ConfirmTOTP receives a code from the authenticator app.
+func (h *AuthHandler) SetupTOTP(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if h.mfaSecrets == nil {
+		http.Error(w, "MFA is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if user.MFAEnabled {
+		http.Error(w, "MFA is already enabled", http.StatusConflict)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
+		return
+	}
+	sealed, err := h.mfaSecrets.Seal(secret)
+	if err != nil {
+		http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
+		return
+	}
+	if err := h.db.GormDB().Model(user).Update("totp_secret", sealed).Error; err != nil {
+		http.Error(w, "Failed to save secret", http.StatusInternalServerError)
+		return
+	}
+
+	issuer := config.String("MFA_ISSUER", "List of Maldives")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":      secret,
+		"otpauth_uri": auth.TOTPURI(issuer, user.Email, secret),
+	})
+}
+
+// ConfirmTOTP enables MFA once the user proves their app produces valid codes,
+// and returns the recovery codes. They are only ever shown this once.
+func (h *AuthHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.UserContextKey).(*models.User)
+
+	var req TOTPConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "Code is required", http.StatusBadRequest)
+		return
+	}
+	if h.mfaSecrets == nil || user.TOTPSecret == "" {
+		http.Error(w, "Start TOTP setup first", http.StatusBadRequest)
+		return
+	}
+	if user.MFAEnabled {
+		http.Error(w, "MFA is already enabled", http.StatusConflict)
+		return
+	}
+
+	secret, err := h.mfaSecrets.Open(user.TOTPSecret)
+	if err != nil {
+		http.Error(w, "Failed to read secret", http.StatusInternalServerError)
+		return
+	}
+	step, ok := auth.ValidateTOTP(secret, req.Code, time.Now())
+	if !ok {
+		http.Error(w, "Invalid code", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		http.Error(w, "Failed to generate recovery codes", http.StatusInternalServerError)
+		return
+	}
+	if err := models.EnableTOTP(h.db, user, step, codes); err != nil {
+		http.Error(w, "Failed to enable MFA", http.StatusInternalServerError)
+		return
+	}
+	h.audit(r, models.AuditMFAEnabled, user, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":        "MFA enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// respondMFAChallenge answers a correct password with a short-lived challenge
+// token instead of a session when the account has MFA enabled
+func (h *AuthHandler) respondMFAChallenge(w http.ResponseWriter, r *http.Request, user *models.User) {
+	ttl := config.Duration("MFA_CHALLENGE_TTL", 5*time.Minute)
+	token, err := h.jwtService.GenerateActionToken(auth.PurposeMFAChallenge, user.UUID, user.Email, ttl)
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	h.audit(r, models.AuditMFAChallenged, user, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MFAChallengeResponse{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresIn:   int64(ttl.Seconds()),
+	})
+}
+
+// redirectMFAChallenge is respondMFAChallenge for sign-ins that end in a
+// redirect (OAuth, magic links): the challenge is set as an HttpOnly cookie
+// and the login page is sent ?mfa=required to ask for the code
+func (h *AuthHandler) redirectMFAChallenge(w http.ResponseWriter, r *http.Request, user *models.User, provider string) {
+	ttl := config.Duration("MFA_CHALLENGE_TTL", 5*time.Minute)
+	token, err := h.jwtService.GenerateActionToken(auth.PurposeMFAChallenge, user.UUID, user.Email, ttl)
+	if err != nil {
+		h.redirectAuthError(w, r, authErrorServer)
+		return
+	}
+	h.audit(r, models.AuditMFAChallenged, user, map[string]string{"provider": provider})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     mfaChallengeCookie,
+		Value:    token,
+		Path:     mfaPath,
+		HttpOnly: true,
+		Secure:   config.IsProduction(),
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   int(ttl.Seconds()),
+	})
+	login := h.redirects.Base.ResolveReference(&url.URL{Path: "/login"})
+	h.redirectWithParam(w, r, login.String(), "mfa", "required")
+}
+
+// clearMFAChallengeCookie expires the cookie set by redirectMFAChallenge
+func clearMFAChallengeCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     mfaChallengeCookie,
+		Value:    "",
+		Path:     mfaPath,
+		HttpOnly: true,
+		Secure:   config.IsProduction(),
+		SameSite: http.SameSiteStrictMode,
+		Expires:  time.Now().Add(-time.Hour),
+	})
+}
+
+// VerifyMFA exchanges a challenge token plus a TOTP or recovery code for a
+// session. The token comes from the body, or from the challenge cookie when
+// the sign-in ended in a redirect.
+func (h *AuthHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	var req MFAVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.MFAToken == "" {
+		if cookie, err := r.Cookie(mfaChallengeCookie); err == nil {
+			req.MFAToken = cookie.Value
+		}
+	}
+	if req.MFAToken == "" {
+		http.Error(w, "MFA token is required", http.StatusBadRequest)
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		http.Error(w, "Code or recovery code is required", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := h.jwtService.ValidateActionToken(req.MFAToken, auth.PurposeMFAChallenge)
+	if err != nil {
+		http.Error(w, "Invalid or expired MFA challenge", http.StatusUnauthorized)
+		return
+	}
+	if used, err := h.revocations.IsTokenRevoked(claims.ID); err != nil || used {
+		http.Error(w, "Invalid or expired MFA challenge", http.StatusUnauthorized)
+		return
+	}
+
+	var user models.User
+	if err := h.db.GormDB().Where("uuid = ?", claims.UserID).First(&user).Error; err != nil || !user.MFAEnabled {
+		http.Error(w, "Invalid or expired MFA challenge", http.StatusUnauthorized)
+		return
+	}
+
+	// Codes are guessed against the same throttle as passwords
+	if h.loginBlocked(w, r, user.Email) {
+		return
+	}
+
+	challengeKey := models.MFAChallengeThrottleKey(claims.ID)
+	if !h.checkSecondFactor(&user, req) {
+		h.audit(r, models.AuditMFAFailed, &user, nil)
+		h.countLoginFailure(r, user.Email, &user)
+
+		// Each challenge only gets a few codes; after that the password
+		// has to be entered again for a new one
+		throttle, err := models.RecordLoginFailure(h.db, challengeKey, auth.LockoutPolicy{})
+		if err != nil || throttle.Failures >= h.mfaMaxAttempts {
+			if err := h.revocations.RevokeToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+				log.Printf("failed to revoke MFA challenge for user %s: %v", user.UUID, err)
+			}
+			models.ClearLoginThrottle(h.db, challengeKey)
+			clearMFAChallengeCookie(w)
+			http.Error(w, "Too many invalid codes, sign in again", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	// The challenge is single-use
+	if err := h.revocations.RevokeToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+		http.Error(w, "Failed to complete sign-in", http.StatusInternalServerError)
+		return
+	}
+	models.ClearLoginThrottle(h.db, challengeKey)
+	h.clearLoginFailures(user.Email)
+	clearMFAChallengeCookie(w)
+
+	response, err := h.startSession(w, r, &user, "mfa")
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// checkSecondFactor validates a TOTP code (once per time step) or consumes a recovery code
+func (h *AuthHandler) checkSecondFactor(user *models.User, req MFAVerifyRequest) bool {
+	if req.RecoveryCode != "" {
+		ok, err := models.UseRecoveryCode(h.db, user.ID, req.RecoveryCode)
+		return err == nil && ok
+	}
+
+	if h.mfaSecrets == nil {
+		return false
+	}
+	secret, err := h.mfaSecrets.Open(user.TOTPSecret)
+	if err != nil {
+		log.Printf("failed to open TOTP secret for user %s: %v", user.UUID, err)
+		return false
+	}
+	step, ok := auth.ValidateTOTP(secret, req.Code, time.Now())
+	if !ok {
+		return false
+	}
+	accepted, err := models.AcceptTOTPStep(h.db, user.ID, step)
+	return err == nil && accepted
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/server/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// newMFAChallenge enables MFA for user with recoveryCode and returns a fresh challenge token
+func newMFAChallenge(t *testing.T, h *AuthHandler, user *models.User, recoveryCode string) string {
+	t.Helper()
+	if !user.MFAEnabled {
+		if err := models.EnableTOTP(h.db, user, 0, []string{recoveryCode}); err != nil {
+			t.Fatalf("failed to enable MFA: %v", err)
+		}
+	}
+	token, err := h.jwtService.GenerateActionToken(auth.PurposeMFAChallenge, user.UUID, user.Email, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create MFA challenge: %v", err)
+	}
+	return token
+}
+
+func TestVerifyMFARevokesChallengeAfterMaxAttempts(t *testing.T) {
+	h, s, _ := newTestHandler(t)
+	user := createUser(t, s, "mfa@example.com", "password123", true)
+	challenge := newMFAChallenge(t, h, user, "good-code")
+
+	for i := 1; i < h.mfaMaxAttempts; i++ {
+		rec := serve(h.VerifyMFA, http.MethodPost, "/auth/mfa/verify", MFAVerifyRequest{MFAToken: challenge, RecoveryCode: "bad-code"}, nil)
+		expectStatus(t, rec, http.StatusUnauthorized)
+	}
+	rec := serve(h.VerifyMFA, http.MethodPost, "/auth/mfa/verify", MFAVerifyRequest{MFAToken: challenge, RecoveryCode: "bad-code"}, nil)
+	expectStatus(t, rec, http.StatusUnauthorized)
+
+	// The right code no longer helps once the challenge is spent
+	rec = serve(h.VerifyMFA, http.MethodPost, "/auth/mfa/verify", MFAVerifyRequest{MFAToken: challenge, RecoveryCode: "good-code"}, nil)
+	expectStatus(t, rec, http.StatusUnauthorized)
+
+	var throttle models.LoginThrottle
+	if err := s.GormDB().Where("key = ?", models.AccountThrottleKey(user.Email)).First(&throttle).Error; err != nil {
+		t.Fatalf("expected wrong codes to count against the account: %v", err)
+	}
+	if throttle.Failures != h.mfaMaxAttempts {
+		t.Errorf("expected %d account failures; got %d", h.mfaMaxAttempts, throttle.Failures)
+	}
+
+	// A new challenge starts over and clears the account's failures on success
+	models.ClearLoginThrottle(s, models.AccountThrottleKey(user.Email))
+	models.RecordLoginFailure(s, models.AccountThrottleKey(user.Email), auth.LockoutPolicy{})
+	challenge = newMFAChallenge(t, h, reload(t, s, user), "good-code")
+	rec = serve(h.VerifyMFA, http.MethodPost, "/auth/mfa/verify", MFAVerifyRequest{MFAToken: challenge, RecoveryCode: "good-code"}, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if err := s.GormDB().Where("key = ?", models.AccountThrottleKey(user.Email)).First(&throttle).Error; err == nil {
+		t.Errorf("expected account failures to be cleared after MFA succeeded")
+	}
+}
+
+func TestRedirectMFAChallengeKeepsTokenOutOfURL(t *testing.T) {
+	h, s, _ := newTestHandler(t)
+	user := createUser(t, s, "mfa@example.com", "password123", true)
+	newMFAChallenge(t, h, user, "good-code")
+
+	rec := httptest.NewRecorder()
+	h.redirectMFAChallenge(rec, httptest.NewRequest(http.MethodGet, "/auth/magic-link/callback", nil), user, "magic")
+	expectStatus(t, rec, http.StatusSeeOther)
+
+	location, _ := url.Parse(rec.Header().Get("Location"))
+	if location.Query().Get("mfa") != "required" || location.Query().Has("mfa_token") {
+		t.Fatalf("expected a redirect with only ?mfa=required; got %s", location)
+	}
+	var challenge *http.Cookie
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == mfaChallengeCookie {
+			challenge = cookie
+		}
+	}
+	if challenge == nil || !challenge.HttpOnly || challenge.Path != mfaPath {
+		t.Fatalf("expected an HttpOnly challenge cookie on %s; got %+v", mfaPath, challenge)
+	}
+
+	body, _ := json.Marshal(MFAVerifyRequest{RecoveryCode: "good-code"})
+	r := httptest.NewRequest(http.MethodPost, "/auth/mfa/verify", bytes.NewReader(body))
+	r.AddCookie(challenge)
+	rec = httptest.NewRecorder()
+	h.VerifyMFA(rec, r)
+	expectStatus(t, rec, http.StatusOK)
+
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == mfaChallengeCookie && cookie.Value != "" {
+			t.Errorf("expected the challenge cookie to be cleared; got %+v", cookie)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// handlers/magic_link_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/config"
+	"list-of-maldives/internal/mailer"
+	"list-of-maldives/internal/server/models"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	// magicLinkNonceCookie binds a magic link to the browser that requested it
+	magicLinkNonceCookie = "magic_link_nonce"
+	magicLinkPath        = "/auth/magic-link"
+)
+
+type MagicLinkRequest struct {
+	Email string `json:"email"`
+}
+
+// RequestMagicLink emails a one-time sign-in link. The link only works in
+// this browser, which receives the matching nonce cookie.
+func (h *AuthHandler) RequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	var req MagicLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		http.Error(w, "Email is required", http.StatusBadRequest)
+		return
+	}
+	addr, err := mail.ParseAddress(req.Email)
+	if err != nil || addr.Address != req.Email {
+		http.Error(w, "Invalid email address", http.StatusBadRequest)
+		return
+	}
+	email := addr.Address
+
+	nonce, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		http.Error(w, "Failed to create sign-in link", http.StatusInternalServerError)
+		return
+	}
+	ttl := config.Duration("MAGIC_LINK_TTL", 15*time.Minute)
+	token, err := models.CreateMagicLinkToken(h.db, email, nonce, ttl)
+	if err != nil {
+		http.Error(w, "Failed to create sign-in link", http.StatusInternalServerError)
+		return
+	}
+
+	link := config.String("MAGIC_LINK_URL", os.Getenv("BACKEND_URL")+magicLinkPath+"/callback")
+	link += "?token=" + url.QueryEscape(token)
+
+	err = h.mailer.Send(r.Context(), mailer.Message{
+		To:      email,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf("Hi,\n\nOpen this link in the browser you requested it from to sign in:\n\n%s\n\nThe link can be used once and expires in %s. If you didn't ask to sign in, ignore this email.\n",
+			link, ttl),
+	})
+	if err != nil {
+		log.Printf("failed to send magic link: %v", err)
+		http.Error(w, "Failed to send sign-in link", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     magicLinkNonceCookie,
+		Value:    nonce,
+		Path:     magicLinkPath,
+		HttpOnly: true,
+		Secure:   config.IsProduction(),
+		// Lax so the cookie is sent when the link is opened from a mail client
+		SameSite: http.SameSiteLaxMode,
+		Expires:  time.Now().Add(ttl),
+	})
+	h.audit(r, models.AuditMagicLinkSent, &models.User{Email: email}, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Check your email for a sign-in link"})
+}
+
+// MagicLinkCallback redeems an emailed sign-in link and starts a session,
+// creating a "magic" user the first time an address signs in
+func (h *AuthHandler) MagicLinkCallback(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		h.redirectAuthError(w, r, authErrorMagicLinkInvalid)
+		return
+	}
+
+	nonce, err := r.Cookie(magicLinkNonceCookie)
+	if err != nil || nonce.Value == "" {
+		h.audit(r, models.AuditLoginFailed, nil, map[string]string{"provider": "magic", "reason": "other_browser"})
+		h.redirectAuthError(w, r, authErrorMagicLinkBrowser)
+		return
+	}
+
+	user, err := models.ConsumeMagicLinkToken(h.db, token, nonce.Value)
+	if errors.Is(err, models.ErrMagicLinkInvalid) {
+		h.audit(r, models.AuditLoginFailed, nil, map[string]string{"provider": "magic", "reason": "invalid_link"})
+		h.redirectAuthError(w, r, authErrorMagicLinkInvalid)
+		return
+	} else if errors.Is(err, models.ErrAccountDeleted) {
+		h.audit(r, models.AuditLoginFailed, nil, map[string]string{"provider": "magic", "reason": "account_deleted"})
+		h.redirectAuthError(w, r, authErrorAccountDeleted)
+		return
+	} else if err != nil {
+		h.redirectAuthError(w, r, authErrorServer)
+		return
+	}
+	// Claiming an unverified account signed it out everywhere
+	h.forgetRevocationCutoff(user)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     magicLinkNonceCookie,
+		Value:    "",
+		Path:     magicLinkPath,
+		HttpOnly: true,
+		Secure:   config.IsProduction(),
+		SameSite: http.SameSiteLaxMode,
+		Expires:  time.Now().Add(-time.Hour),
+	})
+
+	// The second factor is still required; the login page completes the challenge
+	if user.MFAEnabled {
+		h.redirectMFAChallenge(w, r, user, "magic")
+		return
+	}
+
+	if _, err := h.startSession(w, r, user, "magic"); err != nil {
+		h.redirectAuthError(w, r, sessionErrorCode(err))
+		return
+	}
+
+	h.redirectWithParam(w, r, "", "auth", "success")
+}
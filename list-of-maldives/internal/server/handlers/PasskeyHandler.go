@@ -0,0 +1,283 @@
+// handlers/passkey_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/server/middleware"
+	"list-of-maldives/internal/server/models"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gorilla/mux"
+	"github.com/markbates/goth/gothic"
+)
+
+const (
+	// passkeySessionName holds WebAuthn ceremony state between begin and finish
+	passkeySessionName = "_webauthn_session"
+
+	passkeyRegistrationKey = "registration"
+	passkeyLoginKey        = "login"
+
+	// maxPasskeyResponse bounds the authenticator response read from the body
+	maxPasskeyResponse = 64 << 10
+
+	passkeyCeremonyTTL = 5 * time.Minute
+)
+
+// passkeyCeremony is the state kept between begin and finish. ID is the
+// models.PasskeyCeremony finish claims, because the cookie holding the
+// ceremony could otherwise be sent again.
+type passkeyCeremony struct {
+	ID      string               `json:"id"`
+	Session webauthn.SessionData `json:"session"`
+}
+
+// newPasskeys returns the WebAuthn relying party, or nil when it can't be configured
+func newPasskeys() *auth.Passkeys {
+	passkeys, err := auth.PasskeysFromEnv()
+	if err != nil {
+		log.Printf("passkeys disabled: %v", err)
+		return nil
+	}
+	return passkeys
+}
+
+// saveCeremony stores WebAuthn session data for the matching finish request
+func (h *AuthHandler) saveCeremony(w http.ResponseWriter, r *http.Request, key string, data *webauthn.SessionData) error {
+	id, err := models.CreatePasskeyCeremony(h.db, passkeyCeremonyTTL)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(passkeyCeremony{ID: id, Session: *data})
+	if err != nil {
+		return err
+	}
+	session, _ := gothic.Store.Get(r, passkeySessionName)
+	session.Values[key] = string(encoded)
+	session.Options.MaxAge = int(passkeyCeremonyTTL.Seconds())
+	return session.Save(r, w)
+}
+
+// takeCeremony returns the stored session data and marks its ceremony as
+// used, so a challenge is only answered once even if the cookie is replayed
+func (h *AuthHandler) takeCeremony(w http.ResponseWriter, r *http.Request, key string) (*webauthn.SessionData, bool) {
+	session, err := gothic.Store.Get(r, passkeySessionName)
+	if err != nil {
+		return nil, false
+	}
+	encoded, ok := session.Values[key].(string)
+	if !ok {
+		return nil, false
+	}
+	delete(session.Values, key)
+	session.Save(r, w)
+
+	var ceremony passkeyCeremony
+	if err := json.Unmarshal([]byte(encoded), &ceremony); err != nil || ceremony.ID == "" {
+		return nil, false
+	}
+	if claimed, err := models.ClaimPasskeyCeremony(h.db, ceremony.ID); err != nil || !claimed {
+		return nil, false
+	}
+	return &ceremony.Session, true
+}
+
+// BeginPasskeyRegistration returns credential creation options for the current user
+func (h *AuthHandler) BeginPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if h.passkeys == nil {
+		http.Error(w, "Passkeys are not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	passkeyUser, err := models.PasskeyUser(h.db, user)
+	if err != nil {
+		http.Error(w, "Failed to load passkeys", http.StatusInternalServerError)
+		return
+	}
+
+	options, session, err := h.passkeys.BeginRegistration(passkeyUser)
+	if err != nil {
+		http.Error(w, "Failed to start registration", http.StatusInternalServerError)
+		return
+	}
+	if err := h.saveCeremony(w, r, passkeyRegistrationKey, session); err != nil {
+		http.Error(w, "Failed to start registration", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(options)
+}
+
+// FinishPasskeyRegistration verifies the authenticator response and stores the
+// new passkey. The body is the PublicKeyCredential; ?name= labels the passkey.
+func (h *AuthHandler) FinishPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if h.passkeys == nil {
+		http.Error(w, "Passkeys are not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	session, ok := h.takeCeremony(w, r, passkeyRegistrationKey)
+	if !ok {
+		http.Error(w, "Start registration first", http.StatusBadRequest)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPasskeyResponse))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	passkeyUser, err := models.PasskeyUser(h.db, user)
+	if err != nil {
+		http.Error(w, "Failed to load passkeys", http.StatusInternalServerError)
+		return
+	}
+	cred, err := h.passkeys.FinishRegistration(passkeyUser, *session, body)
+	if err != nil {
+		http.Error(w, "Passkey registration failed", http.StatusBadRequest)
+		return
+	}
+
+	passkey, err := models.CreatePasskey(h.db, user.ID, r.URL.Query().Get("name"), cred)
+	if errors.Is(err, models.ErrPasskeyInUse) {
+		http.Error(w, "This passkey is already registered", http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to save passkey", http.StatusInternalServerError)
+		return
+	}
+	h.audit(r, models.AuditPasskeyAdded, user, map[string]string{"passkey_id": strconv.FormatUint(uint64(passkey.ID), 10)})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(passkey)
+}
+
+// BeginPasskeyLogin returns assertion options for a usernameless passkey login
+func (h *AuthHandler) BeginPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	if h.passkeys == nil {
+		http.Error(w, "Passkeys are not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	options, session, err := h.passkeys.BeginLogin()
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	if err := h.saveCeremony(w, r, passkeyLoginKey, session); err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(options)
+}
+
+// FinishPasskeyLogin verifies an assertion and signs the passkey's owner in
+func (h *AuthHandler) FinishPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	if h.passkeys == nil {
+		http.Error(w, "Passkeys are not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	session, ok := h.takeCeremony(w, r, passkeyLoginKey)
+	if !ok {
+		http.Error(w, "Start login first", http.StatusBadRequest)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPasskeyResponse))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	lookup := func(handle []byte) (*auth.PasskeyUser, error) {
+		if err := h.db.GormDB().Where("uuid = ?", string(handle)).First(&user).Error; err != nil {
+			return nil, err
+		}
+		return models.PasskeyUser(h.db, &user)
+	}
+
+	_, cred, err := h.passkeys.FinishLogin(*session, body, lookup)
+	if err != nil {
+		reason := "invalid_assertion"
+		if errors.Is(err, auth.ErrPasskeyCloned) {
+			log.Printf("passkey sign count regressed for user %s", user.UUID)
+			reason = "cloned_passkey"
+		}
+		var subject *models.User
+		if user.ID != 0 {
+			subject = &user
+		}
+		h.audit(r, models.AuditLoginFailed, subject, map[string]string{"provider": "passkey", "reason": reason})
+		http.Error(w, "Passkey login failed", http.StatusUnauthorized)
+		return
+	}
+	if err := models.RecordPasskeyUse(h.db, cred); err != nil {
+		http.Error(w, "Failed to update passkey", http.StatusInternalServerError)
+		return
+	}
+
+	// Same cookies and response as a password or OAuth login
+	response, err := h.startSession(w, r, &user, "passkey")
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListPasskeys returns the current user's passkeys
+func (h *AuthHandler) ListPasskeys(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.UserContextKey).(*models.User)
+
+	passkeys, err := models.ListPasskeys(h.db, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to load passkeys", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"passkeys": passkeys})
+}
+
+// DeletePasskey removes one of the current user's passkeys
+func (h *AuthHandler) DeletePasskey(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.UserContextKey).(*models.User)
+
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Passkey not found", http.StatusNotFound)
+		return
+	}
+
+	err = models.DeletePasskey(h.db, user, uint(id))
+	switch {
+	case errors.Is(err, models.ErrPasskeyNotFound):
+		http.Error(w, "Passkey not found", http.StatusNotFound)
+		return
+	case errors.Is(err, models.ErrLastLoginMethod):
+		http.Error(w, "Cannot remove the only way to sign in", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to delete passkey", http.StatusInternalServerError)
+		return
+	}
+	h.audit(r, models.AuditPasskeyRemoved, user, map[string]string{"passkey_id": mux.Vars(r)["id"]})
+
+	w.WriteHeader(http.StatusNoContent)
+}
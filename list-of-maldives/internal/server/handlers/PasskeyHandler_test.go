@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/auth/passkeytest"
+	"list-of-maldives/internal/server/middleware"
+	"list-of-maldives/internal/server/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/sessions"
+	"github.com/markbates/goth/gothic"
+)
+
+const passkeyTestOrigin = "http://localhost:5173"
+
+// newPasskeyTestHandler is newTestHandler with a relying party on localhost
+// and a cookie store for the begin/finish ceremony state
+func newPasskeyTestHandler(t *testing.T) (*AuthHandler, *models.User) {
+	t.Helper()
+	h, s, _ := newTestHandler(t)
+	passkeys, err := auth.NewPasskeys("localhost", "List of Maldives", []string{passkeyTestOrigin})
+	if err != nil {
+		t.Fatalf("failed to create relying party: %v", err)
+	}
+	h.passkeys = passkeys
+
+	store := gothic.Store
+	gothic.Store = sessions.NewCookieStore([]byte("passkey-test-session-key-32bytes"))
+	t.Cleanup(func() { gothic.Store = store })
+
+	return h, createUser(t, s, "passkey@example.com", "password123", true)
+}
+
+// ceremonyRequest calls handler with body and cookies, signed in as user
+// when it isn't nil
+func ceremonyRequest(handler http.HandlerFunc, target string, body []byte, cookies []*http.Cookie, user *models.User) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	for _, cookie := range cookies {
+		r.AddCookie(cookie)
+	}
+	if user != nil {
+		r = r.WithContext(context.WithValue(r.Context(), middleware.UserContextKey, user))
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, r)
+	return rec
+}
+
+// beginCeremony answers the challenge from a begin endpoint's options
+func beginCeremony(t *testing.T, rec *httptest.ResponseRecorder) (string, []*http.Cookie) {
+	t.Helper()
+	expectStatus(t, rec, http.StatusOK)
+	var options struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+		} `json:"publicKey"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&options); err != nil || options.PublicKey.Challenge == "" {
+		t.Fatalf("expected options with a challenge: %v", err)
+	}
+	return options.PublicKey.Challenge, rec.Result().Cookies()
+}
+
+func TestPasskeyRegistrationAndLoginEndpoints(t *testing.T) {
+	h, user := newPasskeyTestHandler(t)
+	authenticator := passkeytest.New(t)
+
+	challenge, cookies := beginCeremony(t, ceremonyRequest(h.BeginPasskeyRegistration, "/auth/webauthn/register/begin", nil, nil, user))
+	response := authenticator.Create(t, "localhost", challenge, passkeyTestOrigin, []byte(user.UUID))
+	rec := ceremonyRequest(h.FinishPasskeyRegistration, "/auth/webauthn/register/finish?name=Laptop", response, cookies, user)
+	expectStatus(t, rec, http.StatusCreated)
+
+	challenge, cookies = beginCeremony(t, ceremonyRequest(h.BeginPasskeyLogin, "/auth/webauthn/login/begin", nil, nil, nil))
+	assertion := authenticator.Get(t, "localhost", challenge, passkeyTestOrigin)
+	rec = ceremonyRequest(h.FinishPasskeyLogin, "/auth/webauthn/login/finish", assertion, cookies, nil)
+	expectStatus(t, rec, http.StatusOK)
+
+	var session AuthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&session); err != nil {
+		t.Fatalf("failed to decode login response: %v", err)
+	}
+	if session.User == nil || session.User.UUID != user.UUID {
+		t.Errorf("expected to be signed in as %s; got %+v", user.UUID, session.User)
+	}
+}
+
+func TestFinishPasskeyLoginRejectsReplayedCeremony(t *testing.T) {
+	h, user := newPasskeyTestHandler(t)
+	authenticator := passkeytest.New(t)
+
+	challenge, cookies := beginCeremony(t, ceremonyRequest(h.BeginPasskeyRegistration, "/auth/webauthn/register/begin", nil, nil, user))
+	rec := ceremonyRequest(h.FinishPasskeyRegistration, "/auth/webauthn/register/finish", authenticator.Create(t, "localhost", challenge, passkeyTestOrigin, []byte(user.UUID)), cookies, user)
+	expectStatus(t, rec, http.StatusCreated)
+
+	// The same ceremony cookie can't register a second time
+	rec = ceremonyRequest(h.FinishPasskeyRegistration, "/auth/webauthn/register/finish", authenticator.Create(t, "localhost", challenge, passkeyTestOrigin, []byte(user.UUID)), cookies, user)
+	expectStatus(t, rec, http.StatusBadRequest)
+
+	challenge, cookies = beginCeremony(t, ceremonyRequest(h.BeginPasskeyLogin, "/auth/webauthn/login/begin", nil, nil, nil))
+	rec = ceremonyRequest(h.FinishPasskeyLogin, "/auth/webauthn/login/finish", authenticator.Get(t, "localhost", challenge, passkeyTestOrigin), cookies, nil)
+	expectStatus(t, rec, http.StatusOK)
+
+	// Sending the begin cookie again with a fresh assertion for its
+	// challenge must not sign in a second time
+	rec = ceremonyRequest(h.FinishPasskeyLogin, "/auth/webauthn/login/finish", authenticator.Get(t, "localhost", challenge, passkeyTestOrigin), cookies, nil)
+	expectStatus(t, rec, http.StatusBadRequest)
+}
@@ -0,0 +1,129 @@
+// handlers/password_handler.go
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"list-of-maldives/internal/config"
+	"list-of-maldives/internal/mailer"
+	"list-of-maldives/internal/server/models"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const minPasswordLength = 8
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// validatePassword enforces the password policy for new passwords
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("Password must be at least %d characters", minPasswordLength)
+	}
+	if len(password) > 72 {
+		// bcrypt ignores everything past 72 bytes
+		return fmt.Errorf("Password must be at most 72 bytes")
+	}
+	return nil
+}
+
+// ForgotPassword emails a reset link if the address belongs to a password account.
+// The response is identical whether or not the account exists.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		http.Error(w, "Email is required", http.StatusBadRequest)
+		return
+	}
+
+	// Look up and send off the request path so timing doesn't reveal the account
+	go h.sendPasswordReset(req.Email)
+	h.audit(r, models.AuditPasswordResetSent, &models.User{Email: req.Email}, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "If an account exists for that email, a reset link has been sent",
+	})
+}
+
+func (h *AuthHandler) sendPasswordReset(email string) {
+	var user models.User
+	if err := h.db.GormDB().Where("email = ?", email).First(&user).Error; err != nil {
+		return
+	}
+	if user.Password == "" {
+		return
+	}
+	h.sendResetLink(user)
+}
+
+// sendResetLink emails user a single-use password reset link
+func (h *AuthHandler) sendResetLink(user models.User) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	ttl := config.Duration("PASSWORD_RESET_TTL", time.Hour)
+	token, err := models.CreatePasswordResetToken(h.db, user.ID, ttl)
+	if err != nil {
+		log.Printf("failed to create password reset token for user %s: %v", user.UUID, err)
+		return
+	}
+
+	link := config.String("PASSWORD_RESET_URL", os.Getenv("FRONTEND_URL")+"/reset-password")
+	link += "?token=" + url.QueryEscape(token)
+
+	err = h.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password for your account. Open this link to choose a new one:\n\n%s\n\nThe link can be used once and expires in %s. If it wasn't you, ignore this email.\n",
+			user.NickName, link, ttl),
+	})
+	if err != nil {
+		log.Printf("failed to send password reset email to user %s: %v", user.UUID, err)
+	}
+}
+
+// ResetPassword sets a new password using an emailed reset token and signs
+// the account out everywhere
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := validatePassword(req.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Sessions are revoked with the password change, so neither happens alone
+	user, err := models.ResetPassword(h.db, req.Token, req.Password)
+	if errors.Is(err, models.ErrResetTokenInvalid) {
+		http.Error(w, "Invalid or expired reset link", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	h.forgetRevocationCutoff(user)
+	clearAuthCookies(w)
+	h.clearLoginFailures(user.Email)
+	h.audit(r, models.AuditPasswordReset, user, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password has been reset, please sign in"})
+}
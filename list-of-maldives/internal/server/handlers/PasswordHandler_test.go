@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"list-of-maldives/internal/server/models"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestForgotAndResetPassword(t *testing.T) {
+	h, s, mail := newTestHandler(t)
+	user := createUser(t, s, "reset@example.com", "old password 123", true)
+
+	session, err := models.CreateSession(s, user.ID, "email", "127.0.0.1", "test", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	refresh, _, err := models.IssueRefreshToken(s, user.ID, session.FamilyID, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to issue refresh token: %v", err)
+	}
+
+	// Unknown and known addresses get the same answer
+	unknown := serve(h.ForgotPassword, http.MethodPost, "/auth/password/forgot", ForgotPasswordRequest{Email: "nobody@example.com"}, nil)
+	known := serve(h.ForgotPassword, http.MethodPost, "/auth/password/forgot", ForgotPasswordRequest{Email: user.Email}, nil)
+	expectStatus(t, unknown, http.StatusAccepted)
+	expectStatus(t, known, http.StatusAccepted)
+	if unknown.Body.String() != known.Body.String() {
+		t.Fatalf("expected identical responses; got %q and %q", unknown.Body.String(), known.Body.String())
+	}
+	token := tokenFromEmail(t, mail.last(t, user.Email))
+
+	rec := serve(h.ResetPassword, http.MethodPost, "/auth/password/reset", ResetPasswordRequest{Token: token, Password: "short"}, nil)
+	expectStatus(t, rec, http.StatusBadRequest)
+
+	rec = serve(h.ResetPassword, http.MethodPost, "/auth/password/reset", ResetPasswordRequest{Token: token, Password: "new password 456"}, nil)
+	expectStatus(t, rec, http.StatusOK)
+
+	stored := reload(t, s, user)
+	if !stored.CheckPassword("new password 456") || stored.CheckPassword("old password 123") {
+		t.Fatalf("expected the password to be replaced")
+	}
+	if err := models.CheckSession(s, session.UUID, user.ID, "127.0.0.1"); !errors.Is(err, models.ErrSessionRevoked) {
+		t.Fatalf("expected existing sessions to be revoked; got %v", err)
+	}
+	if _, _, err := models.RotateRefreshToken(s, refresh, time.Hour); !errors.Is(err, models.ErrRefreshTokenInvalid) {
+		t.Fatalf("expected existing refresh tokens to be revoked; got %v", err)
+	}
+	if before, err := models.NewRevocationStore(s).UserTokensRevokedBefore(user.UUID); err != nil || before.IsZero() {
+		t.Fatalf("expected existing access tokens to be revoked; got %v, %v", before, err)
+	}
+
+	// The link works once
+	rec = serve(h.ResetPassword, http.MethodPost, "/auth/password/reset", ResetPasswordRequest{Token: token, Password: "another password 789"}, nil)
+	expectStatus(t, rec, http.StatusBadRequest)
+}
@@ -0,0 +1,284 @@
+// handlers/profile_handler.go
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/config"
+	"list-of-maldives/internal/mailer"
+	"list-of-maldives/internal/server/middleware"
+	"list-of-maldives/internal/server/models"
+	"log"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const maxNicknameLength = 100
+
+// UpdateProfileRequest changes the fields that are set. A new email only
+// takes effect once confirmed, and needs CurrentPassword on password accounts.
+type UpdateProfileRequest struct {
+	Nickname        *string `json:"nickname"`
+	Email           *string `json:"email"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+// ChangePasswordRequest needs CurrentPassword unless the account has no
+// password yet, in which case it must have signed in recently
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token"`
+}
+
+// validateNickname trims nickname and checks it fits the column
+func validateNickname(nickname string) (string, error) {
+	nickname = strings.TrimSpace(nickname)
+	if nickname == "" {
+		return "", errors.New("Nickname can't be empty")
+	}
+	if utf8.RuneCountInString(nickname) > maxNicknameLength {
+		return "", fmt.Errorf("Nickname must be at most %d characters", maxNicknameLength)
+	}
+	for _, r := range nickname {
+		if unicode.IsControl(r) {
+			return "", errors.New("Nickname contains invalid characters")
+		}
+	}
+	return nickname, nil
+}
+
+// validateEmail accepts a bare address like jane@example.com
+func validateEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	addr, err := netmail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > 255 {
+		return "", errors.New("Invalid email address")
+	}
+	return email, nil
+}
+
+// confirmIdentity makes sensitive changes prove the account holder is at the
+// keyboard: with the current password, or for accounts without one, a
+// session started within REAUTH_MAX_AGE. It answers the request on failure.
+func (h *AuthHandler) confirmIdentity(w http.ResponseWriter, r *http.Request, user *models.User, password string) bool {
+	if user.Password == "" {
+		maxAge := config.Duration("REAUTH_MAX_AGE", 10*time.Minute)
+		session, err := models.FindSession(h.db, user.ID, currentSessionID(r))
+		if err != nil || time.Since(session.CreatedAt) > maxAge {
+			h.audit(r, models.AuditAccessDenied, user, map[string]string{"path": r.URL.Path, "reason": "reauthentication_required"})
+			http.Error(w, "Sign in again to continue", http.StatusForbidden)
+			return false
+		}
+		return true
+	}
+
+	if password == "" {
+		http.Error(w, "Current password is required", http.StatusBadRequest)
+		return false
+	}
+	// Guesses count towards the login lockout, so a stolen session can't be
+	// used to brute-force the password
+	if h.loginBlocked(w, r, user.Email) {
+		return false
+	}
+	if !user.CheckPassword(password) {
+		h.recordLoginFailure(r, user.Email, user)
+		http.Error(w, "Current password is incorrect", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// notifyUser emails user about a change to its account, in the background
+func (h *AuthHandler) notifyUser(to string, user models.User, subject, body string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		err := h.mailer.Send(ctx, mailer.Message{
+			To:      to,
+			Subject: subject,
+			Body:    fmt.Sprintf("Hi %s,\n\n%s\n", user.NickName, body),
+		})
+		if err != nil {
+			log.Printf("failed to send %q email to user %s: %v", subject, user.UUID, err)
+		}
+	}()
+}
+
+// sendEmailChangeLink mails the new address a link that confirms the change
+func (h *AuthHandler) sendEmailChangeLink(ctx context.Context, user *models.User, email string) error {
+	ttl := config.Duration("EMAIL_CHANGE_TTL", 24*time.Hour)
+	token, err := h.jwtService.GenerateActionToken(auth.PurposeChangeEmail, user.UUID, email, ttl)
+	if err != nil {
+		return err
+	}
+
+	link := config.String("EMAIL_CHANGE_URL", os.Getenv("FRONTEND_URL")+"/confirm-email")
+	link += "?token=" + url.QueryEscape(token)
+
+	return h.mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm that you want to sign in with this address from now on by opening this link:\n\n%s\n\nThe link expires in %s. If you didn't ask for this, ignore this email.\n",
+			user.NickName, link, ttl),
+	})
+}
+
+// UpdateProfile changes the current user's nickname and starts an email
+// change. The new address is kept in pending_email until confirmed.
+func (h *AuthHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.UserContextKey).(*models.User)
+
+	var req UpdateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var nickname, email string
+	var err error
+	if req.Nickname != nil {
+		if nickname, err = validateNickname(*req.Nickname); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Email != nil {
+		if email, err = validateEmail(*req.Email); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if email == user.Email {
+			email = ""
+		}
+	}
+
+	if email != "" {
+		if !h.confirmIdentity(w, r, user, req.CurrentPassword) {
+			return
+		}
+		taken, err := models.EmailTaken(h.db, email, user.ID)
+		if err != nil {
+			http.Error(w, "Failed to check email", http.StatusInternalServerError)
+			return
+		} else if taken {
+			http.Error(w, "Email is already in use", http.StatusConflict)
+			return
+		}
+	}
+
+	if nickname != "" && nickname != user.NickName {
+		if err := h.db.GormDB().Model(user).Update("nick_name", nickname).Error; err != nil {
+			http.Error(w, "Failed to update profile", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if email != "" {
+		if err := models.RequestEmailChange(h.db, user, email); err != nil {
+			http.Error(w, "Failed to update profile", http.StatusInternalServerError)
+			return
+		}
+		if err := h.sendEmailChangeLink(r.Context(), user, email); err != nil {
+			log.Printf("failed to send email change link to user %s: %v", user.UUID, err)
+			http.Error(w, "Failed to send the confirmation email", http.StatusInternalServerError)
+			return
+		}
+		h.audit(r, models.AuditEmailChangeSent, user, map[string]string{"new_email": email})
+		h.notifyUser(user.Email, *user, "Your email address is being changed",
+			fmt.Sprintf("Someone asked to change the email address of your account to %s. It changes once the link sent there is opened. If this wasn't you, change your password now.", email))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"user": user})
+}
+
+// ConfirmEmailChange switches the account to the address an email change
+// link was sent to
+func (h *AuthHandler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	var req ConfirmEmailChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := h.jwtService.ValidateActionToken(req.Token, auth.PurposeChangeEmail)
+	if err != nil {
+		http.Error(w, "Invalid or expired confirmation link", http.StatusBadRequest)
+		return
+	}
+
+	user, previous, err := models.ConfirmEmailChange(h.db, claims.UserID, claims.Email)
+	switch {
+	case errors.Is(err, models.ErrEmailChangeInvalid):
+		http.Error(w, "Invalid or expired confirmation link", http.StatusBadRequest)
+		return
+	case errors.Is(err, models.ErrEmailInUse):
+		http.Error(w, "Email is already in use", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to change email", http.StatusInternalServerError)
+		return
+	}
+
+	h.audit(r, models.AuditEmailChanged, user, map[string]string{"previous_email": previous})
+	h.notifyUser(previous, *user, "Your email address was changed",
+		fmt.Sprintf("The email address of your account was changed to %s. If this wasn't you, contact support.", user.Email))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Email changed",
+		"user":    user,
+	})
+}
+
+// ChangePassword sets a new password for the current user and signs out
+// its other devices
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.UserContextKey).(*models.User)
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := validatePassword(req.NewPassword); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !h.confirmIdentity(w, r, user, req.CurrentPassword) {
+		return
+	}
+
+	// Keep this device signed in if we know which one it is
+	kept, err := models.ChangePassword(h.db, user, req.NewPassword, currentSessionID(r))
+	if err != nil {
+		http.Error(w, "Failed to change password", http.StatusInternalServerError)
+		return
+	}
+	if !kept {
+		h.forgetRevocationCutoff(user)
+		clearAuthCookies(w)
+	}
+
+	h.audit(r, models.AuditPasswordChanged, user, nil)
+	h.notifyUser(user.Email, *user, "Your password was changed",
+		"The password of your account was just changed and your other devices were signed out. If this wasn't you, reset your password now.")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password changed"})
+}
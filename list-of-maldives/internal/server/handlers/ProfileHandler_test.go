@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/server/middleware"
+	"list-of-maldives/internal/server/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEmailChangeTakesEffectOnceConfirmed(t *testing.T) {
+	h, s, mail := newTestHandler(t)
+	user := createUser(t, s, "old@example.com", "password123", true)
+
+	newEmail := "new@example.com"
+	rec := serve(h.UpdateProfile, http.MethodPatch, "/profile", UpdateProfileRequest{Email: &newEmail, CurrentPassword: "password123"}, user)
+	expectStatus(t, rec, http.StatusOK)
+	if stored := reload(t, s, user); stored.Email != "old@example.com" {
+		t.Fatalf("expected the email to stay unchanged until confirmed; got %s", stored.Email)
+	}
+
+	token := tokenFromEmail(t, mail.last(t, newEmail))
+	rec = serve(h.ConfirmEmailChange, http.MethodPost, "/profile/email/confirm", ConfirmEmailChangeRequest{Token: token}, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if stored := reload(t, s, user); stored.Email != newEmail {
+		t.Errorf("expected the email to be %s; got %s", newEmail, stored.Email)
+	}
+	mail.last(t, "old@example.com")
+
+	// The link only works once
+	rec = serve(h.ConfirmEmailChange, http.MethodPost, "/profile/email/confirm", ConfirmEmailChangeRequest{Token: token}, nil)
+	expectStatus(t, rec, http.StatusBadRequest)
+}
+
+func TestUpdateProfileRequiresPasswordForEmailChange(t *testing.T) {
+	h, s, _ := newTestHandler(t)
+	user := createUser(t, s, "old@example.com", "password123", true)
+
+	newEmail := "new@example.com"
+	rec := serve(h.UpdateProfile, http.MethodPatch, "/profile", UpdateProfileRequest{Email: &newEmail, CurrentPassword: "wrong"}, user)
+	if rec.Code == http.StatusOK {
+		t.Fatalf("expected the email change to be refused without the password")
+	}
+	if stored := reload(t, s, user); stored.PendingEmail != "" {
+		t.Errorf("expected no pending email; got %s", stored.PendingEmail)
+	}
+}
+
+// changePassword calls ChangePassword signed in as user on session
+func changePassword(h *AuthHandler, user *models.User, session *models.Session, req ChangePasswordRequest) *httptest.ResponseRecorder {
+	return serve(func(w http.ResponseWriter, r *http.Request) {
+		if session != nil {
+			claims := &auth.Claims{UserID: user.UUID, Email: user.Email, SessionID: session.UUID}
+			r = r.WithContext(context.WithValue(r.Context(), middleware.ClaimsContextKey, claims))
+		}
+		h.ChangePassword(w, r)
+	}, http.MethodPost, "/profile/password", req, user)
+}
+
+func TestChangePasswordSignsOutOtherSessions(t *testing.T) {
+	h, s, _ := newTestHandler(t)
+	user := createUser(t, s, "user@example.com", "password123", true)
+	expires := time.Now().Add(time.Hour)
+	current, err := models.CreateSession(s, user.ID, "password", "127.0.0.1", "test", expires)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	other, err := models.CreateSession(s, user.ID, "password", "127.0.0.2", "test", expires)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	rec := changePassword(h, user, current, ChangePasswordRequest{CurrentPassword: "password123", NewPassword: "new-password456"})
+	expectStatus(t, rec, http.StatusOK)
+	if !reload(t, s, user).CheckPassword("new-password456") {
+		t.Errorf("expected the new password to be set")
+	}
+	if _, err := models.FindSession(s, user.ID, current.UUID); err != nil {
+		t.Errorf("expected this device to stay signed in; got %v", err)
+	}
+	if _, err := models.FindSession(s, user.ID, other.UUID); err != models.ErrSessionNotFound {
+		t.Errorf("expected the other device to be signed out; got %v", err)
+	}
+}
+
+func TestChangePasswordWithoutSessionSignsOutEverywhere(t *testing.T) {
+	h, s, _ := newTestHandler(t)
+	user := createUser(t, s, "user@example.com", "password123", true)
+	session, err := models.CreateSession(s, user.ID, "password", "127.0.0.1", "test", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	rec := changePassword(h, user, nil, ChangePasswordRequest{CurrentPassword: "password123", NewPassword: "new-password456"})
+	expectStatus(t, rec, http.StatusOK)
+	if !reload(t, s, user).CheckPassword("new-password456") {
+		t.Errorf("expected the new password to be set")
+	}
+	if _, err := models.FindSession(s, user.ID, session.UUID); err != models.ErrSessionNotFound {
+		t.Errorf("expected every session to be signed out; got %v", err)
+	}
+	if before, err := h.revocations.UserTokensRevokedBefore(user.UUID); err != nil || before.IsZero() {
+		t.Errorf("expected the user's access tokens to be revoked; got %v, %v", before, err)
+	}
+	if cookies := rec.Result().Cookies(); len(cookies) != 2 {
+		t.Errorf("expected the auth cookies to be cleared; got %d cookies", len(cookies))
+	}
+}
@@ -0,0 +1,112 @@
+// handlers/role_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/server/middleware"
+	"list-of-maldives/internal/server/models"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type GrantRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// userFromPath loads the user named by the {uuid} route variable, answering
+// 404 if there is none. withDeleted also finds soft-deleted users.
+func (h *AuthHandler) userFromPath(w http.ResponseWriter, r *http.Request, withDeleted bool) (*models.User, bool) {
+	user, err := models.FindUserByUUID(h.db, mux.Vars(r)["uuid"], withDeleted)
+	if errors.Is(err, models.ErrUserNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil, false
+	} else if err != nil {
+		http.Error(w, "Failed to load user", http.StatusInternalServerError)
+		return nil, false
+	}
+	return user, true
+}
+
+func (h *AuthHandler) writeUserRoles(w http.ResponseWriter, user *models.User, status int) {
+	roles, err := models.UserRoles(h.db, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to load roles", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"user_uuid": user.UUID, "roles": roles})
+}
+
+// ListRoles describes the roles that can be granted and what they allow
+func (h *AuthHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"roles": auth.Roles()})
+}
+
+// GetUserRoles lists the roles granted to a user
+func (h *AuthHandler) GetUserRoles(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.userFromPath(w, r, false)
+	if !ok {
+		return
+	}
+	h.writeUserRoles(w, user, http.StatusOK)
+}
+
+// GrantRole gives a user a role. It shows up in their access tokens from
+// the next refresh; routes behind RecheckRoles see it right away.
+func (h *AuthHandler) GrantRole(w http.ResponseWriter, r *http.Request) {
+	admin := r.Context().Value(middleware.UserContextKey).(*models.User)
+
+	var req GrantRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Role == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := h.userFromPath(w, r, false)
+	if !ok {
+		return
+	}
+
+	err := models.GrantRole(h.db, user.ID, req.Role, &admin.ID)
+	if errors.Is(err, models.ErrUnknownRole) {
+		http.Error(w, "Unknown role", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to grant role", http.StatusInternalServerError)
+		return
+	}
+	h.audit(r, models.AuditRoleGranted, user, map[string]string{"role": req.Role})
+
+	h.writeUserRoles(w, user, http.StatusOK)
+}
+
+// RevokeRole takes a role away from a user
+func (h *AuthHandler) RevokeRole(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.userFromPath(w, r, false)
+	if !ok {
+		return
+	}
+
+	role := mux.Vars(r)["role"]
+	err := models.RevokeRole(h.db, user.ID, role)
+	switch {
+	case errors.Is(err, models.ErrRoleNotFound):
+		http.Error(w, "User does not have this role", http.StatusNotFound)
+		return
+	case errors.Is(err, models.ErrLastAdmin):
+		http.Error(w, "Cannot revoke the last admin", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to revoke role", http.StatusInternalServerError)
+		return
+	}
+	h.audit(r, models.AuditRoleRevoked, user, map[string]string{"role": role})
+
+	w.WriteHeader(http.StatusNoContent)
+}
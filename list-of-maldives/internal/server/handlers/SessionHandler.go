@@ -0,0 +1,72 @@
+// handlers/session_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/server/middleware"
+	"list-of-maldives/internal/server/models"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// SessionResponse is a signed-in device, flagged when it made this request
+type SessionResponse struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// currentSessionID is the sid of the access token on r, if any
+func currentSessionID(r *http.Request) string {
+	if claims, ok := r.Context().Value(middleware.ClaimsContextKey).(*auth.Claims); ok {
+		return claims.SessionID
+	}
+	return ""
+}
+
+// ListSessions returns the devices the current user is signed in on
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.UserContextKey).(*models.User)
+
+	sessions, err := models.ListSessions(h.db, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to load sessions", http.StatusInternalServerError)
+		return
+	}
+
+	current := currentSessionID(r)
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{Session: session, Current: session.UUID == current})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"sessions": response})
+}
+
+// RevokeSession signs one of the current user's devices out. Its refresh
+// token stops working and its access tokens are rejected immediately.
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.UserContextKey).(*models.User)
+	id := mux.Vars(r)["id"]
+
+	err := models.RevokeSession(h.db, user.ID, id)
+	if errors.Is(err, models.ErrSessionNotFound) {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+
+	h.audit(r, models.AuditSessionRevoked, user, map[string]string{"session_id": id})
+
+	// Signing out this device also clears its cookies
+	if id == currentSessionID(r) {
+		clearAuthCookies(w)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/server/middleware"
+	"list-of-maldives/internal/server/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// onSession calls handler signed in as user on session, with id as the
+// {id} route variable
+func onSession(handler http.HandlerFunc, method string, user *models.User, session *models.Session, id string) *httptest.ResponseRecorder {
+	return serve(func(w http.ResponseWriter, r *http.Request) {
+		claims := &auth.Claims{UserID: user.UUID, Email: user.Email, SessionID: session.UUID}
+		r = r.WithContext(context.WithValue(r.Context(), middleware.ClaimsContextKey, claims))
+		handler(w, mux.SetURLVars(r, map[string]string{"id": id}))
+	}, method, "/auth/sessions/"+id, nil, user)
+}
+
+func TestListSessionsFlagsTheCurrentOne(t *testing.T) {
+	h, s, _ := newTestHandler(t)
+	user := createUser(t, s, "user@example.com", "password123", true)
+	other := createUser(t, s, "other@example.com", "password123", true)
+	expires := time.Now().Add(time.Hour)
+	current, err := models.CreateSession(s, user.ID, "password", "127.0.0.1", "test", expires)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if _, err := models.CreateSession(s, user.ID, "google", "127.0.0.2", "test", expires); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if _, err := models.CreateSession(s, other.ID, "password", "127.0.0.3", "test", expires); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	rec := onSession(h.ListSessions, http.MethodGet, user, current, "")
+	expectStatus(t, rec, http.StatusOK)
+	var body struct {
+		Sessions []struct {
+			ID      string `json:"id"`
+			Current bool   `json:"current"`
+		} `json:"sessions"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode sessions: %v", err)
+	}
+	if len(body.Sessions) != 2 {
+		t.Fatalf("expected the user's 2 sessions; got %+v", body.Sessions)
+	}
+	for _, session := range body.Sessions {
+		if session.Current != (session.ID == current.UUID) {
+			t.Errorf("expected only %s to be flagged current; got %+v", current.UUID, body.Sessions)
+		}
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	h, s, _ := newTestHandler(t)
+	user := createUser(t, s, "user@example.com", "password123", true)
+	other := createUser(t, s, "other@example.com", "password123", true)
+	expires := time.Now().Add(time.Hour)
+	current, err := models.CreateSession(s, user.ID, "password", "127.0.0.1", "test", expires)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	device, err := models.CreateSession(s, user.ID, "password", "127.0.0.2", "test", expires)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	foreign, err := models.CreateSession(s, other.ID, "password", "127.0.0.3", "test", expires)
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	// Someone else's device is out of reach
+	rec := onSession(h.RevokeSession, http.MethodDelete, user, current, foreign.UUID)
+	expectStatus(t, rec, http.StatusNotFound)
+	if _, err := models.FindSession(s, other.ID, foreign.UUID); err != nil {
+		t.Errorf("expected the other user's session to stay signed in; got %v", err)
+	}
+
+	// Signing out another device leaves this one's cookies alone
+	rec = onSession(h.RevokeSession, http.MethodDelete, user, current, device.UUID)
+	expectStatus(t, rec, http.StatusNoContent)
+	if _, err := models.FindSession(s, user.ID, device.UUID); err != models.ErrSessionNotFound {
+		t.Errorf("expected the device to be signed out; got %v", err)
+	}
+	if cookies := rec.Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("expected no cookies to be cleared; got %d", len(cookies))
+	}
+	rec = onSession(h.RevokeSession, http.MethodDelete, user, current, device.UUID)
+	expectStatus(t, rec, http.StatusNotFound)
+
+	// Signing out this device also clears its cookies
+	rec = onSession(h.RevokeSession, http.MethodDelete, user, current, current.UUID)
+	expectStatus(t, rec, http.StatusNoContent)
+	if _, err := models.FindSession(s, user.ID, current.UUID); err != models.ErrSessionNotFound {
+		t.Errorf("expected the current session to be signed out; got %v", err)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 2 {
+		t.Fatalf("expected both auth cookies to be cleared; got %d", len(cookies))
+	}
+	for _, cookie := range cookies {
+		if cookie.Value != "" || !cookie.Expires.Before(time.Now()) {
+			t.Errorf("expected %s to be cleared", cookie.Name)
+		}
+	}
+}
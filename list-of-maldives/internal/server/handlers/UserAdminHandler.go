@@ -0,0 +1,333 @@
+// handlers/user_admin_handler.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"list-of-maldives/internal/server/middleware"
+	"list-of-maldives/internal/server/models"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AdminUser is a user as support sees it, including soft deletion and the
+// reason for its status
+type AdminUser struct {
+	models.User
+	Status       string     `json:"status"`
+	StatusReason string     `json:"status_reason,omitempty"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+}
+
+// StatusRequest is the body of the account status actions. Until is only
+// used when suspending; leave it out to suspend until reactivated.
+type StatusRequest struct {
+	Reason string     `json:"reason"`
+	Until  *time.Time `json:"until"`
+}
+
+func newAdminUser(user models.User) AdminUser {
+	admin := AdminUser{User: user, Status: user.EffectiveStatus(), StatusReason: user.StatusReason}
+	if user.DeletedAt.Valid {
+		admin.DeletedAt = &user.DeletedAt.Time
+	}
+	return admin
+}
+
+// parseTimeParam accepts RFC 3339 timestamps or plain dates (2006-01-02)
+func parseTimeParam(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// userFilterFromQuery reads the ListUsers query parameters
+func userFilterFromQuery(r *http.Request) (models.UserFilter, error) {
+	query := r.URL.Query()
+	filter := models.UserFilter{
+		Provider: query.Get("provider"),
+		Status:   query.Get("status"),
+		Search:   query.Get("q"),
+		Cursor:   query.Get("cursor"),
+	}
+
+	if value := query.Get("verified"); value != "" {
+		verified, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, errors.New("verified must be true or false")
+		}
+		filter.Verified = &verified
+	}
+	for name, target := range map[string]*time.Time{"created_after": &filter.CreatedAfter, "created_before": &filter.CreatedBefore} {
+		if value := query.Get(name); value != "" {
+			t, err := parseTimeParam(value)
+			if err != nil {
+				return filter, errors.New(name + " must be a date or RFC 3339 timestamp")
+			}
+			*target = t
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return filter, errors.New("limit must be a positive number")
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+// ListUsers pages through users, newest first. Filter with ?provider=,
+// ?verified=, ?status= (status=deleted lists soft-deleted users),
+// ?created_after=, ?created_before=, search email and nickname with ?q=, and
+// pass the returned next_cursor as ?cursor= for the next page.
+func (h *AuthHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	filter, err := userFilterFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	users, next, err := models.ListUsers(h.db, filter)
+	if errors.Is(err, models.ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	} else if errors.Is(err, models.ErrInvalidStatus) {
+		http.Error(w, "Unknown status", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to load users", http.StatusInternalServerError)
+		return
+	}
+
+	list := make([]AdminUser, 0, len(users))
+	for _, user := range users {
+		list = append(list, newAdminUser(user))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"users":       list,
+		"next_cursor": next,
+	})
+}
+
+// GetUserDetails shows one user with its roles, linked logins, sessions and
+// status history
+func (h *AuthHandler) GetUserDetails(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.userFromPath(w, r, true)
+	if !ok {
+		return
+	}
+
+	identities, err := models.ListIdentities(h.db, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to load identities", http.StatusInternalServerError)
+		return
+	}
+	sessions, err := models.ListSessions(h.db, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to load sessions", http.StatusInternalServerError)
+		return
+	}
+	history, err := models.ListStatusChanges(h.db, user.ID)
+	if err != nil {
+		http.Error(w, "Failed to load status history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user":           newAdminUser(*user),
+		"identities":     identities,
+		"sessions":       sessions,
+		"status_history": history,
+		"has_password":   user.Password != "",
+	})
+}
+
+// notSelf refuses admin actions that would lock the acting admin out
+func notSelf(w http.ResponseWriter, r *http.Request, user *models.User) bool {
+	current := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if current.ID == user.ID {
+		http.Error(w, "Use the account settings to change your own account", http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+func writeAdminUser(w http.ResponseWriter, user *models.User) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"user": newAdminUser(*user)})
+}
+
+// setStatus applies an admin's status change to user, answering the request
+// if it fails
+func (h *AuthHandler) setStatus(w http.ResponseWriter, r *http.Request, user *models.User, status string, req StatusRequest) bool {
+	admin := r.Context().Value(middleware.UserContextKey).(*models.User)
+	from := user.EffectiveStatus()
+	err := models.SetAccountStatus(h.db, user, status, req.Reason, req.Until, admin)
+	switch {
+	case errors.Is(err, models.ErrStatusUnchanged):
+		http.Error(w, "User already has this status", http.StatusConflict)
+	case errors.Is(err, models.ErrInvalidStatus):
+		http.Error(w, "until must be in the future", http.StatusBadRequest)
+	case err != nil:
+		http.Error(w, "Failed to change account status", http.StatusInternalServerError)
+	default:
+		h.forgetRevocationCutoff(user)
+		h.audit(r, models.AuditStatusChanged, user, map[string]string{"from": from, "to": status, "reason": req.Reason})
+		return true
+	}
+	return false
+}
+
+// decodeStatusRequest reads an optional StatusRequest body
+func decodeStatusRequest(w http.ResponseWriter, r *http.Request) (StatusRequest, bool) {
+	var req StatusRequest
+	if r.ContentLength == 0 {
+		return req, true
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// SuspendUser blocks sign-in for a user, until req.Until if given, and signs
+// it out everywhere. A suspended user can be suspended again to change the
+// reason or end date.
+func (h *AuthHandler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeStatusRequest(w, r)
+	if !ok {
+		return
+	}
+	if req.Reason == "" {
+		http.Error(w, "A reason is required", http.StatusBadRequest)
+		return
+	}
+	user, ok := h.userFromPath(w, r, false)
+	if !ok || !notSelf(w, r, user) {
+		return
+	}
+
+	if !h.setStatus(w, r, user, models.StatusSuspended, req) {
+		return
+	}
+
+	writeAdminUser(w, user)
+}
+
+// goodStanding is the status a reactivated or restored user returns to
+func goodStanding(user *models.User) string {
+	if user.IsVerified {
+		return models.StatusActive
+	}
+	return models.StatusPendingVerification
+}
+
+// ReactivateUser lifts a suspension
+func (h *AuthHandler) ReactivateUser(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeStatusRequest(w, r)
+	if !ok {
+		return
+	}
+	user, ok := h.userFromPath(w, r, false)
+	if !ok {
+		return
+	}
+	if user.EffectiveStatus() != models.StatusSuspended {
+		http.Error(w, "User is not suspended", http.StatusConflict)
+		return
+	}
+	if !h.setStatus(w, r, user, goodStanding(user), req) {
+		return
+	}
+
+	writeAdminUser(w, user)
+}
+
+// ForceLogout signs a user out on every device
+func (h *AuthHandler) ForceLogout(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.userFromPath(w, r, false)
+	if !ok {
+		return
+	}
+
+	if err := h.revokeAllTokens(user); err != nil {
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+	h.audit(r, models.AuditForcedLogout, user, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ForcePasswordReset signs a user out, refuses its password until it is
+// reset, and emails it a reset link
+func (h *AuthHandler) ForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.userFromPath(w, r, false)
+	if !ok {
+		return
+	}
+	if user.Password == "" {
+		http.Error(w, "User has no password", http.StatusConflict)
+		return
+	}
+
+	if err := h.revokeAllTokens(user); err != nil {
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+	if err := models.RequirePasswordReset(h.db, user); err != nil {
+		http.Error(w, "Failed to require a password reset", http.StatusInternalServerError)
+		return
+	}
+	go h.sendResetLink(*user)
+	h.audit(r, models.AuditForcedReset, user, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Password reset link sent"})
+}
+
+// DeleteUser soft-deletes a user and signs it out everywhere; pass the reason
+// as ?reason=. RestoreUser undoes it.
+func (h *AuthHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.userFromPath(w, r, false)
+	if !ok || !notSelf(w, r, user) {
+		return
+	}
+
+	if !h.setStatus(w, r, user, models.StatusDeleted, StatusRequest{Reason: r.URL.Query().Get("reason")}) {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreUser brings back a soft-deleted user as active, or pending
+// verification if it never verified its email
+func (h *AuthHandler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeStatusRequest(w, r)
+	if !ok {
+		return
+	}
+	user, ok := h.userFromPath(w, r, true)
+	if !ok {
+		return
+	}
+	if !user.DeletedAt.Valid {
+		http.Error(w, "User is not deleted", http.StatusConflict)
+		return
+	}
+
+	if !h.setStatus(w, r, user, goodStanding(user), req) {
+		return
+	}
+
+	writeAdminUser(w, user)
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/server/middleware"
+	"list-of-maldives/internal/server/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// failingRevocations is a revocation store that is down
+type failingRevocations struct {
+	auth.RevocationStore
+}
+
+func (failingRevocations) RevokeUserTokens(string, time.Time) error {
+	return errors.New("revocation store unavailable")
+}
+
+// adminAction calls handler for the user in the {uuid} path variable,
+// signed in as admin
+func adminAction(handler http.HandlerFunc, method string, user, admin *models.User, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/admin/users/"+user.UUID, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r = mux.SetURLVars(r, map[string]string{"uuid": user.UUID})
+	r = r.WithContext(context.WithValue(r.Context(), middleware.UserContextKey, admin))
+	rec := httptest.NewRecorder()
+	handler(rec, r)
+	return rec
+}
+
+func TestSuspendUserSignsOut(t *testing.T) {
+	h, s, _ := newTestHandler(t)
+	admin := createUser(t, s, "admin@example.com", "password123", true)
+	user := createUser(t, s, "user@example.com", "password123", true)
+
+	rec := adminAction(h.SuspendUser, http.MethodPost, user, admin, `{"reason":"abuse"}`)
+	expectStatus(t, rec, http.StatusOK)
+	if status := reload(t, s, user).EffectiveStatus(); status != models.StatusSuspended {
+		t.Errorf("expected the user to be suspended; got %s", status)
+	}
+	before, err := h.revocations.UserTokensRevokedBefore(user.UUID)
+	if err != nil || before.IsZero() {
+		t.Errorf("expected the user's tokens to be revoked; got %v, %v", before, err)
+	}
+
+	// Admins can't suspend themselves
+	expectStatus(t, adminAction(h.SuspendUser, http.MethodPost, admin, admin, `{"reason":"oops"}`), http.StatusConflict)
+}
+
+func TestRefusedStatusChangesDoNotSignOut(t *testing.T) {
+	h, s, _ := newTestHandler(t)
+	admin := createUser(t, s, "admin@example.com", "password123", true)
+	user := createUser(t, s, "user@example.com", "password123", true)
+	session, err := models.CreateSession(s, user.ID, "password", "127.0.0.1", "test", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	signedIn := func() {
+		t.Helper()
+		if _, err := models.FindSession(s, user.ID, session.UUID); err != nil {
+			t.Errorf("expected the user to stay signed in; got %v", err)
+		}
+		if before, err := h.revocations.UserTokensRevokedBefore(user.UUID); err != nil || !before.IsZero() {
+			t.Errorf("expected the user's tokens to stay valid; got %v, %v", before, err)
+		}
+	}
+
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	expectStatus(t, adminAction(h.SuspendUser, http.MethodPost, user, admin, `{"reason":"abuse","until":"`+past+`"}`), http.StatusBadRequest)
+	signedIn()
+
+	// Nor does a delete that is refused because the status wouldn't change
+	if err := s.GormDB().Model(user).Update("status", models.StatusDeleted).Error; err != nil {
+		t.Fatalf("failed to mark user deleted: %v", err)
+	}
+	expectStatus(t, adminAction(h.DeleteUser, http.MethodDelete, user, admin, ""), http.StatusConflict)
+	signedIn()
+}
+
+func TestAdminActionsFailWhenSessionsCannotBeRevoked(t *testing.T) {
+	h, s, _ := newTestHandler(t)
+	h.revocations = failingRevocations{h.revocations}
+	admin := createUser(t, s, "admin@example.com", "password123", true)
+	user := createUser(t, s, "user@example.com", "password123", true)
+
+	expectStatus(t, adminAction(h.ForcePasswordReset, http.MethodPost, user, admin, ""), http.StatusInternalServerError)
+	if reload(t, s, user).MustResetPassword {
+		t.Errorf("expected no reset to be required while the user is still signed in")
+	}
+}
@@ -0,0 +1,132 @@
+// handlers/verification_handler.go
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/config"
+	"list-of-maldives/internal/mailer"
+	"list-of-maldives/internal/server/middleware"
+	"list-of-maldives/internal/server/models"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// sendVerificationEmail mails user a signed link to confirm their address
+func (h *AuthHandler) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	ttl := config.Duration("EMAIL_VERIFICATION_TTL", 24*time.Hour)
+	token, err := h.jwtService.GenerateActionToken(auth.PurposeVerifyEmail, user.UUID, user.Email, ttl)
+	if err != nil {
+		return err
+	}
+
+	link := config.String("EMAIL_VERIFICATION_URL", os.Getenv("FRONTEND_URL")+"/verify-email")
+	link += "?token=" + url.QueryEscape(token)
+
+	return h.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm your email address by opening this link:\n\n%s\n\nThe link expires in %s. If you didn't create an account, ignore this email.\n",
+			user.NickName, link, ttl),
+	})
+}
+
+// VerifyEmail marks the account verified when given a valid verification token
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := h.jwtService.ValidateActionToken(req.Token, auth.PurposeVerifyEmail)
+	if err != nil {
+		http.Error(w, "Invalid or expired verification link", http.StatusBadRequest)
+		return
+	}
+
+	db := h.db.GormDB()
+	var user models.User
+	if err := db.Where("uuid = ?", claims.UserID).First(&user).Error; err != nil {
+		http.Error(w, "Invalid or expired verification link", http.StatusBadRequest)
+		return
+	}
+
+	// A link sent to a previous address doesn't verify the current one
+	if user.Email != claims.Email {
+		http.Error(w, "Invalid or expired verification link", http.StatusBadRequest)
+		return
+	}
+
+	if !user.IsVerified {
+		if err := models.MarkVerified(db, &user); err != nil {
+			http.Error(w, "Failed to verify email", http.StatusInternalServerError)
+			return
+		}
+		h.audit(r, models.AuditEmailVerified, &user, nil)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Email verified",
+		"user":    &user,
+	})
+}
+
+// ResendVerification mails a new verification link to the current user,
+// at most once per EMAIL_VERIFICATION_RESEND_INTERVAL
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	user := r.Context().Value(middleware.UserContextKey).(*models.User)
+	if user.IsVerified {
+		http.Error(w, "Email is already verified", http.StatusConflict)
+		return
+	}
+
+	// The conditional update makes the throttle hold across API instances
+	interval := config.Duration("EMAIL_VERIFICATION_RESEND_INTERVAL", time.Minute)
+	now := time.Now()
+	result := h.db.GormDB().Model(&models.User{}).
+		Where("id = ? AND (verification_sent_at IS NULL OR verification_sent_at < ?)", user.ID, now.Add(-interval)).
+		Update("verification_sent_at", now)
+	if result.Error != nil {
+		http.Error(w, "Failed to send verification email", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		retryAfter := interval
+		if user.VerificationSentAt != nil {
+			retryAfter = time.Until(user.VerificationSentAt.Add(interval))
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+		http.Error(w, "Verification email was sent recently, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	if err := h.sendVerificationEmail(r.Context(), user); err != nil {
+		log.Printf("failed to send verification email to user %s: %v", user.UUID, err)
+		http.Error(w, "Failed to send verification email", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Verification email sent"})
+}
+
+// markVerificationSent records the initial send so resends are throttled from it
+func markVerificationSent(db *gorm.DB, user *models.User) {
+	now := time.Now()
+	user.VerificationSentAt = &now
+	db.Model(user).Update("verification_sent_at", now)
+}
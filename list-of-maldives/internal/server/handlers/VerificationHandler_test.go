@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/server/models"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRegisterAndVerifyEmail(t *testing.T) {
+	h, s, mail := newTestHandler(t)
+
+	rec := serve(h.Register, http.MethodPost, "/auth/register", RegisterRequest{
+		Email:    "new@example.com",
+		Password: "correct horse battery",
+		Nickname: "New",
+	}, nil)
+	expectStatus(t, rec, http.StatusOK)
+
+	var user models.User
+	if err := s.GormDB().Where("email = ?", "new@example.com").First(&user).Error; err != nil {
+		t.Fatalf("expected the user to be created: %v", err)
+	}
+	if user.IsVerified || user.Status != models.StatusPendingVerification {
+		t.Fatalf("expected a new account to be pending verification; got %t, %s", user.IsVerified, user.Status)
+	}
+
+	token := tokenFromEmail(t, mail.last(t, "new@example.com"))
+
+	rec = serve(h.VerifyEmail, http.MethodPost, "/auth/verify-email", VerifyEmailRequest{Token: "forged"}, nil)
+	expectStatus(t, rec, http.StatusBadRequest)
+
+	rec = serve(h.VerifyEmail, http.MethodPost, "/auth/verify-email", VerifyEmailRequest{Token: token}, nil)
+	expectStatus(t, rec, http.StatusOK)
+	stored := reload(t, s, &user)
+	if !stored.IsVerified || stored.Status != models.StatusActive {
+		t.Fatalf("expected the account to be verified and active; got %t, %s", stored.IsVerified, stored.Status)
+	}
+
+	changes, err := models.ListStatusChanges(s, user.ID)
+	if err != nil || len(changes) != 1 || changes[0].ToStatus != models.StatusActive {
+		t.Fatalf("expected the verification to be recorded; got %+v, %v", changes, err)
+	}
+}
+
+func TestVerifyEmailRejectsLinkForPreviousAddress(t *testing.T) {
+	h, s, _ := newTestHandler(t)
+	user := createUser(t, s, "old@example.com", "correct horse battery", false)
+
+	token, err := h.jwtService.GenerateActionToken(auth.PurposeVerifyEmail, user.UUID, user.Email, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	s.GormDB().Model(user).Update("email", "new@example.com")
+
+	rec := serve(h.VerifyEmail, http.MethodPost, "/auth/verify-email", VerifyEmailRequest{Token: token}, nil)
+	expectStatus(t, rec, http.StatusBadRequest)
+	if reload(t, s, user).IsVerified {
+		t.Fatalf("expected a link for the old address not to verify the new one")
+	}
+}
+
+func TestResendVerificationIsThrottled(t *testing.T) {
+	h, s, mail := newTestHandler(t)
+	user := createUser(t, s, "resend@example.com", "correct horse battery", false)
+
+	rec := serve(h.ResendVerification, http.MethodPost, "/auth/verify-email/resend", nil, user)
+	expectStatus(t, rec, http.StatusOK)
+	mail.last(t, "resend@example.com")
+
+	rec = serve(h.ResendVerification, http.MethodPost, "/auth/verify-email/resend", nil, reload(t, s, user))
+	expectStatus(t, rec, http.StatusTooManyRequests)
+	if rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected a Retry-After header")
+	}
+
+	verified := createUser(t, s, "done@example.com", "correct horse battery", true)
+	rec = serve(h.ResendVerification, http.MethodPost, "/auth/verify-email/resend", nil, verified)
+	expectStatus(t, rec, http.StatusConflict)
+}
+
+func TestRegisterRefusesDeletedAccountEmail(t *testing.T) {
+	h, s, _ := newTestHandler(t)
+	user := createUser(t, s, "deleted@example.com", "password123", true)
+	if err := models.DeleteOwnAccount(s, user, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("failed to delete account: %v", err)
+	}
+
+	// The email stays taken until the account is purged
+	rec := serve(h.Register, http.MethodPost, "/auth/register", RegisterRequest{
+		Email:    "deleted@example.com",
+		Password: "correct horse battery",
+	}, nil)
+	expectStatus(t, rec, http.StatusConflict)
+	var count int64
+	s.GormDB().Unscoped().Model(&models.User{}).Where("email = ?", "deleted@example.com").Count(&count)
+	if count != 1 {
+		t.Errorf("expected no second account with the email; got %d", count)
+	}
+}
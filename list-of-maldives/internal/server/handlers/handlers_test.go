@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/database"
+	"list-of-maldives/internal/database/dbtest"
+	"list-of-maldives/internal/mailer"
+	"list-of-maldives/internal/server/middleware"
+	"list-of-maldives/internal/server/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingMailer keeps sent messages instead of delivering them
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (m *recordingMailer) Send(_ context.Context, msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// last returns the latest message to to, waiting briefly for mail sent in
+// the background
+func (m *recordingMailer) last(t *testing.T, to string) mailer.Message {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		m.mu.Lock()
+		for i := len(m.sent) - 1; i >= 0; i-- {
+			if m.sent[i].To == to {
+				msg := m.sent[i]
+				m.mu.Unlock()
+				return msg
+			}
+		}
+		m.mu.Unlock()
+	}
+	t.Fatalf("expected an email to %s", to)
+	return mailer.Message{}
+}
+
+var linkTokenPattern = regexp.MustCompile(`token=([^\s&]+)`)
+
+// tokenFromEmail returns the token of the link in msg
+func tokenFromEmail(t *testing.T, msg mailer.Message) string {
+	t.Helper()
+	match := linkTokenPattern.FindStringSubmatch(msg.Body)
+	if match == nil {
+		t.Fatalf("expected a link with a token in %q", msg.Body)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatalf("invalid token in link: %v", err)
+	}
+	return token
+}
+
+// newTestHandler returns an AuthHandler on a throwaway database; see dbtest.Open
+func newTestHandler(t *testing.T) (*AuthHandler, database.Service, *recordingMailer) {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "test-secret")
+	s := dbtest.Open(t, models.Tables()...)
+	mail := &recordingMailer{}
+	return NewAuthHandler(s, auth.NewJWTService(), models.NewRevocationStore(s), mail), s, mail
+}
+
+// serve calls handler with body encoded as JSON, signed in as user when it
+// isn't nil
+func serve(handler http.HandlerFunc, method, target string, body interface{}, user *models.User) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	r := httptest.NewRequest(method, target, &buf)
+	r.Header.Set("Content-Type", "application/json")
+	if user != nil {
+		r = r.WithContext(context.WithValue(r.Context(), middleware.UserContextKey, user))
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, r)
+	return rec
+}
+
+// createUser stores a password account
+func createUser(t *testing.T, s database.Service, email, password string, verified bool) *models.User {
+	t.Helper()
+	user := models.User{Email: email, NickName: "Test", Password: password, IsVerified: verified}
+	if err := s.GormDB().Create(&user).Error; err != nil {
+		t.Fatalf("failed to create user %s: %v", email, err)
+	}
+	return &user
+}
+
+// reload reads user back from the database
+func reload(t *testing.T, s database.Service, user *models.User) *models.User {
+	t.Helper()
+	var stored models.User
+	if err := s.GormDB().Unscoped().First(&stored, user.ID).Error; err != nil {
+		t.Fatalf("failed to reload user %d: %v", user.ID, err)
+	}
+	return &stored
+}
+
+// expectStatus fails t unless rec answered with status
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("expected status %d; got %d: %s", status, rec.Code, strings.TrimSpace(rec.Body.String()))
+	}
+}
@@ -0,0 +1,96 @@
+// handlers/redirect.go
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/markbates/goth/gothic"
+)
+
+// returnToSessionName is a gothic-store session holding where to send the
+// browser after an OAuth flow
+const returnToSessionName = "_return_to"
+
+// Error codes sent to the frontend login page as ?error=<code>
+const (
+	authErrorInvalidReturnTo  = "invalid_return_to"
+	authErrorOAuthFailed      = "oauth_failed"
+	authErrorEmailInUse       = "email_in_use"
+	authErrorReauthRequired   = "reauth_required"
+	authErrorIdentityLinked   = "identity_linked"
+	authErrorProviderInUse    = "provider_in_use"
+	authErrorMagicLinkInvalid = "magic_link_invalid"
+	authErrorMagicLinkBrowser = "magic_link_other_browser"
+	authErrorAccountSuspended = "account_suspended"
+	authErrorAccountDeleted   = "account_deleted"
+	authErrorServer           = "server_error"
+)
+
+// rememberReturnTo stores a valid ?return_to= for the callback. It reports
+// false, after redirecting with an error, if the value isn't allowed.
+func (h *AuthHandler) rememberReturnTo(w http.ResponseWriter, r *http.Request) bool {
+	returnTo := r.URL.Query().Get("return_to")
+	session, _ := gothic.Store.New(r, returnToSessionName)
+	if returnTo == "" {
+		// Forget a deep link left over from an abandoned flow
+		session.Options.MaxAge = -1
+		session.Save(r, w)
+		return true
+	}
+	if _, ok := h.redirects.Resolve(returnTo); !ok {
+		h.redirectAuthError(w, r, authErrorInvalidReturnTo)
+		return false
+	}
+
+	session.Values["return_to"] = returnTo
+	session.Options.MaxAge = 10 * 60
+	if err := session.Save(r, w); err != nil {
+		log.Printf("failed to save return_to: %v", err)
+	}
+	return true
+}
+
+// takeReturnTo returns the stored return_to as an absolute URL, re-checked
+// against the allowlist, and clears it. It returns "" if there is none.
+func (h *AuthHandler) takeReturnTo(w http.ResponseWriter, r *http.Request) string {
+	session, err := gothic.Store.Get(r, returnToSessionName)
+	if err != nil {
+		return ""
+	}
+	returnTo, _ := session.Values["return_to"].(string)
+	if returnTo == "" {
+		return ""
+	}
+	session.Options.MaxAge = -1
+	session.Save(r, w)
+
+	target, ok := h.redirects.Resolve(returnTo)
+	if !ok {
+		return ""
+	}
+	return target
+}
+
+// redirectWithParam redirects to target (the frontend root if empty) with key=value added
+func (h *AuthHandler) redirectWithParam(w http.ResponseWriter, r *http.Request, target, key, value string) {
+	u := h.redirects.Base
+	if target != "" {
+		if parsed, err := url.Parse(target); err == nil {
+			u = parsed
+		}
+	}
+
+	next := *u
+	query := next.Query()
+	query.Set(key, value)
+	next.RawQuery = query.Encode()
+	http.Redirect(w, r, next.String(), http.StatusSeeOther)
+}
+
+// redirectAuthError sends the browser to the frontend login page with an error code
+func (h *AuthHandler) redirectAuthError(w http.ResponseWriter, r *http.Request, code string) {
+	login := h.redirects.Base.ResolveReference(&url.URL{Path: "/login"})
+	h.redirectWithParam(w, r, login.String(), "error", code)
+}
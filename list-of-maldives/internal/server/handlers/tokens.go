@@ -0,0 +1,189 @@
+// handlers/tokens.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/config"
+	"list-of-maldives/internal/server/middleware"
+	"list-of-maldives/internal/server/models"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	accessTokenCookie  = "auth_token"
+	refreshTokenCookie = "refresh_token"
+
+	// The refresh cookie is only sent to the endpoints that consume it
+	refreshTokenCookiePath = "/auth"
+)
+
+// startSession records a new device session for user, signed in with
+// provider, and issues an access token and a refresh-token family for it.
+// Like issueTokens it refuses accounts whose status blocks sign-in.
+func (h *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, user *models.User, provider string) (*AuthResponse, error) {
+	if err := user.CanSignIn(); err != nil {
+		h.audit(r, models.AuditLoginBlocked, user, map[string]string{"provider": provider, "reason": err.Error()})
+		return nil, err
+	}
+	ttl := h.jwtService.RefreshTTL()
+	session, err := models.CreateSession(h.db, user.ID, provider, middleware.ClientIP(r), r.UserAgent(), time.Now().Add(ttl))
+	if err != nil {
+		return nil, err
+	}
+	refresh, stored, err := models.IssueRefreshToken(h.db, user.ID, session.FamilyID, ttl)
+	if err != nil {
+		return nil, err
+	}
+	response, err := h.issueTokens(w, user, session.UUID, refresh, stored.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	h.audit(r, models.AuditLoginSucceeded, user, map[string]string{"provider": provider, "session_id": session.UUID})
+	return response, nil
+}
+
+// audit records an authentication event; see middleware.Audit
+func (h *AuthHandler) audit(r *http.Request, eventType models.AuditEventType, user *models.User, details map[string]string) {
+	middleware.Audit(h.db, r, eventType, user, details)
+}
+
+// issueTokens mints an access token for user's session and sets both auth cookies
+func (h *AuthHandler) issueTokens(w http.ResponseWriter, user *models.User, sessionID, refresh string, refreshExpires time.Time) (*AuthResponse, error) {
+	if err := user.CanSignIn(); err != nil {
+		return nil, err
+	}
+
+	// Listed admins get their role the first time they sign in verified
+	if err := models.BootstrapAdmin(h.db, user, config.List("ADMIN_EMAILS")); err != nil {
+		return nil, err
+	}
+	roles, err := models.UserRoles(h.db, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Right after a sign-out everywhere, tokens minted in the same second
+	// would be revoked along with the old ones; see auth.RevocationCutoff
+	cutoff, err := h.revocations.UserTokensRevokedBefore(user.UUID)
+	if err != nil {
+		return nil, err
+	}
+	if wait := time.Until(cutoff); wait > 0 {
+		time.Sleep(min(wait, time.Second))
+	}
+
+	token, err := h.jwtService.GenerateSessionToken(user.UUID, user.Email, sessionID, roles)
+	if err != nil {
+		return nil, err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     accessTokenCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   config.IsProduction(),
+		SameSite: http.SameSiteLaxMode,
+		Expires:  time.Now().Add(h.jwtService.AccessTTL()),
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshTokenCookie,
+		Value:    refresh,
+		Path:     refreshTokenCookiePath,
+		HttpOnly: true,
+		Secure:   config.IsProduction(),
+		SameSite: http.SameSiteStrictMode,
+		Expires:  refreshExpires,
+	})
+
+	return &AuthResponse{
+		Token:        token,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(h.jwtService.AccessTTL().Seconds()),
+		User:         user,
+	}, nil
+}
+
+// writeSessionError answers a failed startSession or issueTokens
+func writeSessionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrAccountSuspended):
+		http.Error(w, "This account has been suspended", http.StatusForbidden)
+	case errors.Is(err, models.ErrAccountDeleted):
+		http.Error(w, "This account has been deleted", http.StatusForbidden)
+	default:
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+	}
+}
+
+// sessionErrorCode is writeSessionError for flows that redirect to the frontend
+func sessionErrorCode(err error) string {
+	switch {
+	case errors.Is(err, models.ErrAccountSuspended):
+		return authErrorAccountSuspended
+	case errors.Is(err, models.ErrAccountDeleted):
+		return authErrorAccountDeleted
+	}
+	return authErrorServer
+}
+
+// revokeAllTokens signs user out everywhere: refresh tokens stop rotating and
+// access tokens issued so far are denylisted
+func (h *AuthHandler) revokeAllTokens(user *models.User) error {
+	if err := models.RevokeUserRefreshTokens(h.db, user.ID); err != nil {
+		return err
+	}
+	return h.revocations.RevokeUserTokens(user.UUID, time.Now())
+}
+
+// forgetRevocationCutoff makes the cached revocation store re-read user's
+// cutoff after a models function signed them out everywhere in its own
+// transaction
+func (h *AuthHandler) forgetRevocationCutoff(user *models.User) {
+	if cache, ok := h.revocations.(*auth.CachedRevocationStore); ok {
+		cache.ForgetUser(user.UUID)
+	}
+}
+
+// clearAuthCookies expires the access and refresh cookies in the browser,
+// with the attributes issueTokens set them with
+func clearAuthCookies(w http.ResponseWriter) {
+	for _, cookie := range []struct {
+		name     string
+		path     string
+		sameSite http.SameSite
+	}{
+		{accessTokenCookie, "/", http.SameSiteLaxMode},
+		{refreshTokenCookie, refreshTokenCookiePath, http.SameSiteStrictMode},
+	} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     cookie.name,
+			Value:    "",
+			Path:     cookie.path,
+			HttpOnly: true,
+			Secure:   config.IsProduction(),
+			SameSite: cookie.sameSite,
+			Expires:  time.Now().Add(-time.Hour),
+		})
+	}
+}
+
+// refreshTokenFromRequest reads the refresh token from its cookie or, for
+// non-browser clients, from a JSON body {"refresh_token": "..."}
+func refreshTokenFromRequest(r *http.Request) string {
+	if cookie, err := r.Cookie(refreshTokenCookie); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		return ""
+	}
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return ""
+	}
+	return req.RefreshToken
+}
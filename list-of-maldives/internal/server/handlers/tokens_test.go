@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClearAuthCookiesMatchIssuedAttributes(t *testing.T) {
+	issued := map[string]http.SameSite{
+		accessTokenCookie:  http.SameSiteLaxMode,
+		refreshTokenCookie: http.SameSiteStrictMode,
+	}
+	paths := map[string]string{
+		accessTokenCookie:  "/",
+		refreshTokenCookie: refreshTokenCookiePath,
+	}
+
+	rec := httptest.NewRecorder()
+	clearAuthCookies(rec)
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != len(issued) {
+		t.Fatalf("expected %d cookies; got %d", len(issued), len(cookies))
+	}
+	for _, cookie := range cookies {
+		if cookie.SameSite != issued[cookie.Name] {
+			t.Errorf("expected %s to be cleared with SameSite %v; got %v", cookie.Name, issued[cookie.Name], cookie.SameSite)
+		}
+		if cookie.Path != paths[cookie.Name] {
+			t.Errorf("expected %s to be cleared on path %s; got %s", cookie.Name, paths[cookie.Name], cookie.Path)
+		}
+		if !cookie.Expires.Before(time.Now()) || !cookie.HttpOnly {
+			t.Errorf("expected %s to be expired and HttpOnly", cookie.Name)
+		}
+	}
+}
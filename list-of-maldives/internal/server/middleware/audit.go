@@ -0,0 +1,54 @@
+// middleware/audit.go
+package middleware
+
+import (
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/database"
+	"list-of-maldives/internal/server/models"
+	"log"
+	"net/http"
+)
+
+// auditContextKey holds the database AuthMiddleware gives middleware that
+// records audit events without holding one, like RequirePermission
+const auditContextKey contextKey = "audit"
+
+// Audit appends an event about user to the audit log, with the client
+// details of r. user defaults to the signed-in user and may carry only an
+// Email for attempts on unknown accounts; a signed-in user acting on someone
+// else is recorded as the actor.
+// Failures are logged, never returned, so auditing can't break sign-in.
+func Audit(db database.Service, r *http.Request, eventType models.AuditEventType, user *models.User, details map[string]string) {
+	event := models.AuditEvent{
+		Type:      eventType,
+		IP:        ClientIP(r),
+		UserAgent: r.UserAgent(),
+		Details:   details,
+	}
+	// Without a subject the event is about the signed-in user
+	current, _ := r.Context().Value(UserContextKey).(*models.User)
+	if user == nil {
+		user = current
+	}
+	if user != nil {
+		event.UserUUID = user.UUID
+		event.Email = user.Email
+		if current != nil && current.UUID != user.UUID {
+			event.ActorUUID = current.UUID
+		}
+	}
+	if claims, ok := r.Context().Value(ClaimsContextKey).(*auth.Claims); ok {
+		event.SessionID = claims.SessionID
+	}
+
+	if err := models.RecordAuditEvent(db, &event); err != nil {
+		log.Printf("failed to record %s audit event: %v", eventType, err)
+	}
+}
+
+// auditRequest is Audit for middleware that runs after AuthMiddleware
+func auditRequest(r *http.Request, eventType models.AuditEventType, user *models.User, details map[string]string) {
+	if db, ok := r.Context().Value(auditContextKey).(database.Service); ok {
+		Audit(db, r, eventType, user, details)
+	}
+}
@@ -3,44 +3,142 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/config"
 	"list-of-maldives/internal/database"
 	"list-of-maldives/internal/server/models"
+	"log"
 	"net/http"
+	"strings"
 )
 
 type contextKey string
 
-const UserContextKey contextKey = "user"
+const (
+	UserContextKey        contextKey = "user"
+	ClaimsContextKey      contextKey = "claims"
+	TokenSourceContextKey contextKey = "token_source"
+	authErrorContextKey   contextKey = "auth_error"
+)
+
+// TokenSource is where AuthMiddleware looks for an access token
+type TokenSource string
+
+const (
+	TokenSourceHeader TokenSource = "header"
+	TokenSourceCookie TokenSource = "cookie"
+)
+
+// TokenSourcesFromEnv reads the lookup order from AUTH_TOKEN_SOURCES
+// ("header,cookie" by default). The first source carrying a token wins.
+func TokenSourcesFromEnv() []TokenSource {
+	var sources []TokenSource
+	for _, name := range config.List("AUTH_TOKEN_SOURCES") {
+		switch source := TokenSource(strings.ToLower(name)); source {
+		case TokenSourceHeader, TokenSourceCookie:
+			sources = append(sources, source)
+		default:
+			log.Printf("ignoring unknown AUTH_TOKEN_SOURCES entry %q", name)
+		}
+	}
+	if len(sources) == 0 {
+		return []TokenSource{TokenSourceHeader, TokenSourceCookie}
+	}
+	return sources
+}
 
-// AuthMiddleware validates JWT token and sets user in context
-func AuthMiddleware(jwtService *auth.JWTService, db database.Service) func(http.Handler) http.Handler {
+// tokenFromRequest returns the first token found in sources, in order
+func tokenFromRequest(r *http.Request, sources []TokenSource) (string, TokenSource) {
+	for _, source := range sources {
+		switch source {
+		case TokenSourceHeader:
+			scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+			if ok && strings.EqualFold(scheme, "Bearer") && strings.TrimSpace(token) != "" {
+				return strings.TrimSpace(token), source
+			}
+		case TokenSourceCookie:
+			if cookie, err := r.Cookie("auth_token"); err == nil && cookie.Value != "" {
+				return cookie.Value, source
+			}
+		}
+	}
+	return "", ""
+}
+
+// AuthMiddleware validates JWT token, rejects revoked tokens and sets user in context
+func AuthMiddleware(jwtService *auth.JWTService, db database.Service, revocations auth.RevocationStore, sources []TokenSource) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Get token from cookie
-			cookie, err := r.Cookie("auth_token")
-			if err != nil {
+			// Get token from the configured sources
+			token, source := tokenFromRequest(r, sources)
+			if token == "" {
 				next.ServeHTTP(w, r)
 				return
 			}
 
+			// A token was presented but can't be used; RequireAuth reports why
+			reject := func(reason string) {
+				ctx := context.WithValue(r.Context(), authErrorContextKey, reason)
+				next.ServeHTTP(w, r.WithContext(ctx))
+			}
+
 			// Validate token
-			claims, err := jwtService.ValidateToken(cookie.Value)
+			claims, err := jwtService.ValidateToken(token)
 			if err != nil {
+				reject("The access token is invalid or expired")
+				return
+			}
+
+			// Reject tokens revoked by logout, password change or account deletion
+			revoked, err := auth.IsRevoked(revocations, claims)
+			if err != nil {
+				log.Printf("revocation check failed: %v", err)
 				next.ServeHTTP(w, r)
 				return
 			}
+			if revoked {
+				Audit(db, r, models.AuditTokenRejected, &models.User{UUID: claims.UserID, Email: claims.Email}, map[string]string{"reason": "revoked"})
+				reject("The access token has been revoked")
+				return
+			}
 
 			// Find user
 			var user models.User
 			gormDB := db.GormDB()
 			if err := gormDB.Where("uuid = ?", claims.UserID).First(&user).Error; err != nil {
-				next.ServeHTTP(w, r)
+				reject("The access token does not belong to an active user")
+				return
+			}
+			// Suspending an account cuts off the tokens it already holds
+			if err := user.CanSignIn(); errors.Is(err, models.ErrAccountSuspended) {
+				Audit(db, r, models.AuditTokenRejected, &user, map[string]string{"reason": "account_suspended"})
+				reject("The account has been suspended")
 				return
+			} else if err != nil {
+				reject("The account has been deleted")
+				return
+			}
+
+			// Tokens of a device that was signed out stop working right away
+			if claims.SessionID != "" {
+				if err := models.CheckSession(db, claims.SessionID, user.ID, ClientIP(r)); errors.Is(err, models.ErrSessionRevoked) {
+					Audit(db, r, models.AuditTokenRejected, &user, map[string]string{"reason": "session_revoked", "session_id": claims.SessionID})
+					reject("The session has been signed out")
+					return
+				} else if err != nil {
+					log.Printf("session check failed: %v", err)
+					next.ServeHTTP(w, r)
+					return
+				}
 			}
 
 			// Add user to context
 			ctx := context.WithValue(r.Context(), UserContextKey, &user)
+			ctx = context.WithValue(ctx, ClaimsContextKey, claims)
+			ctx = context.WithValue(ctx, TokenSourceContextKey, source)
+			ctx = context.WithValue(ctx, auditContextKey, db)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -51,9 +149,27 @@ func RequireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := r.Context().Value(UserContextKey)
 		if user == nil {
+			// RFC 6750: only name an error when a token was actually presented
+			challenge := `Bearer realm="list-of-maldives"`
+			if reason, ok := r.Context().Value(authErrorContextKey).(string); ok {
+				challenge += fmt.Sprintf(`, error="invalid_token", error_description=%q`, reason)
+			}
+			w.Header().Set("WWW-Authenticate", challenge)
 			http.Error(w, "Authentication required", http.StatusUnauthorized)
 			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+// RequireVerified is RequireAuth for routes that also need a verified email
+func RequireVerified(next http.Handler) http.Handler {
+	return RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := r.Context().Value(UserContextKey).(*models.User)
+		if !user.IsVerified {
+			http.Error(w, "Email verification required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"context"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/database/dbtest"
+	"list-of-maldives/internal/server/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenFromRequest(t *testing.T) {
+	both := func() *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer header-token")
+		r.AddCookie(&http.Cookie{Name: "auth_token", Value: "cookie-token"})
+		return r
+	}
+
+	cases := []struct {
+		name    string
+		r       *http.Request
+		sources []TokenSource
+		token   string
+		source  TokenSource
+	}{
+		{"header first", both(), []TokenSource{TokenSourceHeader, TokenSourceCookie}, "header-token", TokenSourceHeader},
+		{"cookie first", both(), []TokenSource{TokenSourceCookie, TokenSourceHeader}, "cookie-token", TokenSourceCookie},
+		{"header only", both(), []TokenSource{TokenSourceHeader}, "header-token", TokenSourceHeader},
+		{"falls back", func() *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.AddCookie(&http.Cookie{Name: "auth_token", Value: "cookie-token"})
+			return r
+		}(), []TokenSource{TokenSourceHeader, TokenSourceCookie}, "cookie-token", TokenSourceCookie},
+		{"lowercase scheme", func() *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Authorization", "bearer header-token")
+			return r
+		}(), []TokenSource{TokenSourceHeader}, "header-token", TokenSourceHeader},
+		{"other scheme", func() *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+			return r
+		}(), []TokenSource{TokenSourceHeader}, "", ""},
+		{"empty bearer", func() *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Authorization", "Bearer  ")
+			return r
+		}(), []TokenSource{TokenSourceHeader}, "", ""},
+	}
+	for _, c := range cases {
+		token, source := tokenFromRequest(c.r, c.sources)
+		if token != c.token || source != c.source {
+			t.Errorf("%s: expected (%q, %q); got (%q, %q)", c.name, c.token, c.source, token, source)
+		}
+	}
+}
+
+func TestRequireAuthChallenge(t *testing.T) {
+	handler := RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatalf("expected the request to be rejected")
+	}))
+
+	// Without a token the challenge names no error
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/me", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401; got %d", rec.Code)
+	}
+	if got := rec.Header().Get("WWW-Authenticate"); got != `Bearer realm="list-of-maldives"` {
+		t.Fatalf("unexpected challenge %q", got)
+	}
+
+	// A rejected token is reported as invalid_token
+	r := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+	r = r.WithContext(context.WithValue(r.Context(), authErrorContextKey, "The access token has been revoked"))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	got := rec.Header().Get("WWW-Authenticate")
+	if !strings.Contains(got, `error="invalid_token"`) || !strings.Contains(got, `error_description="The access token has been revoked"`) {
+		t.Fatalf("unexpected challenge %q", got)
+	}
+}
+
+func TestAuthMiddlewareEnforcesAccountStatus(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	s := dbtest.Open(t, models.Tables()...)
+	jwtService := auth.NewJWTService()
+	handler := AuthMiddleware(jwtService, s, models.NewRevocationStore(s), []TokenSource{TokenSourceHeader})(RequireAuth(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})))
+
+	user := models.User{Email: "user@example.com", Password: "password123", IsVerified: true}
+	if err := s.GormDB().Create(&user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	token, err := jwtService.GenerateToken(user.UUID, user.Email)
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	call := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	if rec := call(); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected an active account's token to work; got %d", rec.Code)
+	}
+
+	// Tokens issued before a suspension stop working, and work again after it
+	until := time.Now().Add(time.Hour)
+	if err := models.SetAccountStatus(s, &user, models.StatusSuspended, "abuse", &until, nil); err != nil {
+		t.Fatalf("failed to suspend user: %v", err)
+	}
+	rec := call()
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Header().Get("WWW-Authenticate"), "suspended") {
+		t.Fatalf("expected a suspended account's token to be rejected; got %d %q", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+	if err := s.GormDB().Model(&user).Update("suspended_until", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatalf("failed to end suspension: %v", err)
+	}
+	if rec := call(); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected the token to work once the suspension ran out; got %d", rec.Code)
+	}
+
+	if err := models.SetAccountStatus(s, &user, models.StatusDeleted, "", nil, nil); err != nil {
+		t.Fatalf("failed to delete user: %v", err)
+	}
+	if rec := call(); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a deleted account's token to be rejected; got %d", rec.Code)
+	}
+}
+
+func TestAuthMiddlewareChecksSession(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	s := dbtest.Open(t, models.Tables()...)
+	jwtService := auth.NewJWTService()
+	handler := AuthMiddleware(jwtService, s, models.NewRevocationStore(s), []TokenSource{TokenSourceHeader})(RequireAuth(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})))
+
+	user := models.User{Email: "user@example.com", Password: "password123", IsVerified: true}
+	if err := s.GormDB().Create(&user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	session, err := models.CreateSession(s, user.ID, "email", "127.0.0.1", "test", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	token, err := jwtService.GenerateSessionToken(user.UUID, user.Email, session.UUID, nil)
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	call := func() int {
+		r := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	if code := call(); code != http.StatusNoContent {
+		t.Fatalf("expected the session's token to work; got %d", code)
+	}
+	if err := models.RevokeSession(s, user.ID, session.UUID); err != nil {
+		t.Fatalf("failed to revoke session: %v", err)
+	}
+	if code := call(); code != http.StatusUnauthorized {
+		t.Fatalf("expected a signed-out session's token to be rejected; got %d", code)
+	}
+
+	// A session that can't be checked doesn't count as signed in either
+	if err := s.GormDB().Migrator().DropTable(&models.Session{}); err != nil {
+		t.Fatalf("failed to drop sessions: %v", err)
+	}
+	if code := call(); code != http.StatusUnauthorized {
+		t.Errorf("expected the request to be rejected when the session check fails; got %d", code)
+	}
+}
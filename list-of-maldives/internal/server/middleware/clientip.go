@@ -0,0 +1,53 @@
+// middleware/clientip.go
+package middleware
+
+import (
+	"list-of-maldives/internal/config"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// trustedProxyHops is how many reverse proxies in front of the server append
+// to X-Forwarded-For; see SetTrustedProxyHops
+var trustedProxyHops int
+
+// SetTrustedProxyHops makes ClientIP read the address that the outermost of
+// hops proxies appended to X-Forwarded-For, counting from the right. Entries
+// further left were sent by the client and can be forged. 0 ignores the header.
+func SetTrustedProxyHops(hops int) {
+	trustedProxyHops = max(hops, 0)
+}
+
+// TrustedProxyHopsFromEnv reads TRUSTED_PROXY_HOPS. Without it,
+// TRUST_PROXY_HEADERS=true means a single proxy.
+func TrustedProxyHopsFromEnv() int {
+	hops := 0
+	if config.Bool("TRUST_PROXY_HEADERS", false) {
+		hops = 1
+	}
+	return config.Int("TRUSTED_PROXY_HOPS", hops)
+}
+
+// ClientIP returns the address of the client making r. X-Forwarded-For is
+// only honoured when SetTrustedProxyHops configured proxies in front of us.
+func ClientIP(r *http.Request) string {
+	if hops := trustedProxyHops; hops > 0 {
+		var entries []string
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			entries = append(entries, strings.Split(header, ",")...)
+		}
+		// Fewer entries means the request didn't come through every proxy
+		if len(entries) >= hops {
+			if ip := net.ParseIP(strings.TrimSpace(entries[len(entries)-hops])); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
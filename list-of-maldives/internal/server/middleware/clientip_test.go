@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	t.Cleanup(func() { SetTrustedProxyHops(0) })
+
+	cases := []struct {
+		name      string
+		hops      int
+		forwarded []string
+		ip        string
+	}{
+		{"header ignored without proxies", 0, []string{"203.0.113.7"}, "192.0.2.1"},
+		{"one proxy", 1, []string{"203.0.113.7"}, "203.0.113.7"},
+		{"forged entries on the left", 1, []string{"10.0.0.1, 198.51.100.9, 203.0.113.7"}, "203.0.113.7"},
+		{"two proxies", 2, []string{"10.0.0.1, 203.0.113.7, 172.16.0.2"}, "203.0.113.7"},
+		{"split across headers", 2, []string{"10.0.0.1, 203.0.113.7", "172.16.0.2"}, "203.0.113.7"},
+		{"bypassed proxy", 2, []string{"203.0.113.7"}, "192.0.2.1"},
+		{"not an address", 1, []string{"unknown"}, "192.0.2.1"},
+	}
+	for _, c := range cases {
+		SetTrustedProxyHops(c.hops)
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		for _, value := range c.forwarded {
+			r.Header.Add("X-Forwarded-For", value)
+		}
+		if ip := ClientIP(r); ip != c.ip {
+			t.Errorf("%s: expected %s; got %s", c.name, c.ip, ip)
+		}
+	}
+}
+
+func TestTrustedProxyHopsFromEnv(t *testing.T) {
+	t.Setenv("TRUST_PROXY_HEADERS", "true")
+	if hops := TrustedProxyHopsFromEnv(); hops != 1 {
+		t.Errorf("expected TRUST_PROXY_HEADERS to mean one proxy; got %d", hops)
+	}
+	t.Setenv("TRUSTED_PROXY_HOPS", "3")
+	if hops := TrustedProxyHopsFromEnv(); hops != 3 {
+		t.Errorf("expected TRUSTED_PROXY_HOPS to win; got %d", hops)
+	}
+}
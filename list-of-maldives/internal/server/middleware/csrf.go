@@ -0,0 +1,150 @@
+// middleware/csrf.go
+package middleware
+
+import (
+	"crypto/subtle"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/config"
+	"list-of-maldives/internal/cors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	// CSRFCookieName is readable by the frontend, which echoes it in CSRFHeaderName
+	CSRFCookieName = "csrf_token"
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// CSRF protects cookie-authenticated, state-changing requests with signed
+// double-submit tokens and an Origin/Referer check
+type CSRF struct {
+	signer  *auth.CSRFSigner
+	trusted []string
+}
+
+func NewCSRF(signer *auth.CSRFSigner, trustedOrigins []string) *CSRF {
+	return &CSRF{signer: signer, trusted: trustedOrigins}
+}
+
+// CSRFFromEnv signs with CSRF_SECRET and trusts CSRF_TRUSTED_ORIGINS, which
+// defaults to the CORS allowed origins plus the BACKEND_URL origin
+func CSRFFromEnv() (*CSRF, error) {
+	if os.Getenv("CSRF_SECRET") == "" {
+		log.Printf("CSRF_SECRET is not set, CSRF tokens won't survive a restart")
+	}
+	signer, err := auth.NewCSRFSigner(os.Getenv("CSRF_SECRET"))
+	if err != nil {
+		return nil, err
+	}
+
+	trusted := config.List("CSRF_TRUSTED_ORIGINS")
+	if len(trusted) == 0 {
+		trusted = cors.DefaultPolicyFromEnv().AllowedOrigins
+		if origin := originOf(os.Getenv("BACKEND_URL")); origin != "" {
+			trusted = append(trusted, origin)
+		}
+	}
+	return NewCSRF(signer, trusted), nil
+}
+
+// originOf returns scheme://host[:port] of rawURL, or "" if it has none
+func originOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// csrfBinding names the session a CSRF token belongs to: the signed-in
+// device (sid), or the user for tokens without one, and "" when signed out
+func csrfBinding(r *http.Request) string {
+	claims, ok := r.Context().Value(ClaimsContextKey).(*auth.Claims)
+	if !ok {
+		return ""
+	}
+	if claims.SessionID != "" {
+		return "sid:" + claims.SessionID
+	}
+	return "user:" + claims.UserID
+}
+
+// Token returns the request's CSRF token if it is still valid for the
+// current session, or sets a new one
+func (c *CSRF) Token(w http.ResponseWriter, r *http.Request) (string, error) {
+	binding := csrfBinding(r)
+	if cookie, err := r.Cookie(CSRFCookieName); err == nil && c.signer.Valid(cookie.Value, binding) {
+		return cookie.Value, nil
+	}
+
+	token, err := c.signer.Generate(binding)
+	if err != nil {
+		return "", err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: false,
+		Secure:   config.IsProduction(),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return token, nil
+}
+
+// originAllowed checks Origin, or Referer when a browser omitted Origin.
+// Requests with neither come from non-browser clients.
+func (c *CSRF) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		if referer := r.Header.Get("Referer"); referer != "" {
+			origin = originOf(referer)
+		} else {
+			return true
+		}
+	}
+	return cors.MatchOrigin(c.trusted, origin)
+}
+
+// Protect rejects unsafe requests from untrusted origins, and cookie-carrying
+// ones without a matching X-CSRF-Token header issued to the same session.
+// Requests authenticated with a Bearer header are exempt. Must run after
+// AuthMiddleware.
+func (c *CSRF) Protect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !c.originAllowed(r) {
+			http.Error(w, "Cross-origin request refused", http.StatusForbidden)
+			return
+		}
+
+		// Bearer tokens aren't sent automatically, so they can't be forged
+		if source, _ := r.Context().Value(TokenSourceContextKey).(TokenSource); source == TokenSourceHeader {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Nothing ambient to abuse without cookies
+		if len(r.Cookies()) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cookie, err := r.Cookie(CSRFCookieName)
+		header := r.Header.Get(CSRFHeaderName)
+		if err != nil || header == "" || !c.signer.Valid(cookie.Value, csrfBinding(r)) ||
+			subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) != 1 {
+			http.Error(w, "Missing or invalid CSRF token", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
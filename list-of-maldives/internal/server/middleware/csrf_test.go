@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"context"
+	"list-of-maldives/internal/auth"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRFProtectBindsTokenToSession(t *testing.T) {
+	signer, _ := auth.NewCSRFSigner("secret")
+	c := NewCSRF(signer, []string{"https://app.example.com"})
+	protected := c.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	withSession := func(r *http.Request, sid string) *http.Request {
+		claims := &auth.Claims{UserID: "user-1", SessionID: sid}
+		ctx := context.WithValue(r.Context(), ClaimsContextKey, claims)
+		ctx = context.WithValue(ctx, TokenSourceContextKey, TokenSourceCookie)
+		return r.WithContext(ctx)
+	}
+
+	// Fetch a token the way GET /auth/csrf does, signed in as session-1
+	rec := httptest.NewRecorder()
+	token, err := c.Token(rec, withSession(httptest.NewRequest(http.MethodGet, "/auth/csrf", nil), "session-1"))
+	if err != nil {
+		t.Fatalf("error issuing token. Err: %v", err)
+	}
+	anonymous, _ := signer.Generate("")
+
+	post := func(token, sid string) int {
+		r := httptest.NewRequest(http.MethodPost, "/auth/me", nil)
+		r.Header.Set("Origin", "https://app.example.com")
+		r.AddCookie(&http.Cookie{Name: "auth_token", Value: "access"})
+		if token != "" {
+			r.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: token})
+			r.Header.Set(CSRFHeaderName, token)
+		}
+		if sid != "" {
+			r = withSession(r, sid)
+		}
+		rec := httptest.NewRecorder()
+		protected.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	cases := []struct {
+		name   string
+		token  string
+		sid    string
+		status int
+	}{
+		{"own session", token, "session-1", http.StatusNoContent},
+		{"missing token", "", "session-1", http.StatusForbidden},
+		{"another session", token, "session-2", http.StatusForbidden},
+		{"planted signed-out token", anonymous, "session-1", http.StatusForbidden},
+		{"signed out", anonymous, "", http.StatusNoContent},
+	}
+	for _, tc := range cases {
+		if status := post(tc.token, tc.sid); status != tc.status {
+			t.Errorf("%s: expected %d; got %d", tc.name, tc.status, status)
+		}
+	}
+
+	// A token from another session is replaced rather than handed back
+	r := withSession(httptest.NewRequest(http.MethodGet, "/auth/csrf", nil), "session-2")
+	r.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: token})
+	if reissued, _ := c.Token(httptest.NewRecorder(), r); reissued == token {
+		t.Errorf("expected a new token for another session")
+	}
+}
+
+func TestCSRFProtectRefusesUntrustedOrigin(t *testing.T) {
+	signer, _ := auth.NewCSRFSigner("secret")
+	c := NewCSRF(signer, []string{"https://app.example.com"})
+	protected := c.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/auth/me", nil)
+	r.Header.Set("Origin", "https://evil.example.com")
+	r.Header.Set("Authorization", "Bearer token")
+	r = r.WithContext(context.WithValue(r.Context(), TokenSourceContextKey, TokenSourceHeader))
+	rec := httptest.NewRecorder()
+	protected.ServeHTTP(rec, r)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for an untrusted origin; got %d", rec.Code)
+	}
+}
@@ -0,0 +1,76 @@
+// middleware/ratelimit.go
+package middleware
+
+import (
+	"fmt"
+	"list-of-maldives/internal/ratelimit"
+	"list-of-maldives/internal/server/models"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// defaultRateLimits protect the unauthenticated auth endpoints when
+// RATE_LIMITS isn't set
+const defaultRateLimits = "/auth 60/1m; /auth/login 10/1m; /auth/register 5/1h; " +
+	"/auth/magic-link 5/15m; /auth/password/forgot 5/15m; /auth/mfa/verify 10/5m"
+
+// RateLimitRulesFromEnv reads the rules from RATE_LIMITS, see ratelimit.ParseRules
+func RateLimitRulesFromEnv() ([]ratelimit.Rule, error) {
+	spec, ok := os.LookupEnv("RATE_LIMITS")
+	if !ok {
+		spec = defaultRateLimits
+	}
+	return ratelimit.ParseRules(spec)
+}
+
+// rateLimitKey names the bucket a request counts against. User limits fall
+// back to the client IP for anonymous requests.
+func rateLimitKey(r *http.Request, rule ratelimit.Rule) string {
+	if rule.By == "user" {
+		if user, ok := r.Context().Value(UserContextKey).(*models.User); ok {
+			return rule.Prefix + "|user:" + user.UUID
+		}
+	}
+	return rule.Prefix + "|ip:" + ClientIP(r)
+}
+
+func ceilSeconds(d float64) string {
+	return strconv.Itoa(int(math.Ceil(d)))
+}
+
+// RateLimit limits requests by the longest matching rule and sets the
+// RateLimit-* headers. Must run after AuthMiddleware for "by=user" rules.
+// If the store fails, requests are let through.
+func RateLimit(store ratelimit.Store, rules []ratelimit.Rule) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rule, ok := ratelimit.Match(rules, r.URL.Path)
+			if !ok || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			result, err := store.Take(r.Context(), rateLimitKey(r, rule), rule.Limit)
+			if err != nil {
+				log.Printf("rate limit check failed: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", rule.Limit.Requests, int(rule.Limit.Period.Seconds())))
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", ceilSeconds(result.Reset.Seconds()))
+
+			if !result.Allowed {
+				w.Header().Set("Retry-After", ceilSeconds(result.RetryAfter.Seconds()))
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
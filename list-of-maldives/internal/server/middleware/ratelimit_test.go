@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"context"
+	"list-of-maldives/internal/ratelimit"
+	"list-of-maldives/internal/server/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimitKey(t *testing.T) {
+	byIP := ratelimit.Rule{Prefix: "/api", By: "ip"}
+	byUser := ratelimit.Rule{Prefix: "/api", By: "user"}
+
+	request := func(ctx map[contextKey]interface{}) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/api/things", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		for key, value := range ctx {
+			r = r.WithContext(context.WithValue(r.Context(), key, value))
+		}
+		return r
+	}
+
+	cases := []struct {
+		name string
+		rule ratelimit.Rule
+		r    *http.Request
+		key  string
+	}{
+		{"ip", byIP, request(map[contextKey]interface{}{UserContextKey: &models.User{UUID: "user-1"}}), "/api|ip:192.0.2.1"},
+		{"anonymous user", byUser, request(nil), "/api|ip:192.0.2.1"},
+		{"signed-in user", byUser, request(map[contextKey]interface{}{UserContextKey: &models.User{UUID: "user-1"}}), "/api|user:user-1"},
+	}
+	for _, c := range cases {
+		if key := rateLimitKey(c.r, c.rule); key != c.key {
+			t.Errorf("%s: expected %s; got %s", c.name, c.key, key)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// middleware/roles.go
+package middleware
+
+import (
+	"context"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/database"
+	"list-of-maldives/internal/server/models"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// RolesContextKey holds roles reloaded by RecheckRoles
+const RolesContextKey contextKey = "roles"
+
+// rolesFromRequest returns the roles RecheckRoles loaded, or else the
+// roles in the access token
+func rolesFromRequest(r *http.Request) []string {
+	if roles, ok := r.Context().Value(RolesContextKey).([]string); ok {
+		return roles
+	}
+	if claims, ok := r.Context().Value(ClaimsContextKey).(*auth.Claims); ok {
+		return claims.Roles
+	}
+	return nil
+}
+
+// RecheckRoles reloads the user's roles from the database, for sensitive
+// routes where a revoked role must stop working before the token expires.
+// Use it ahead of RequireRole or RequirePermission.
+func RecheckRoles(db database.Service) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := r.Context().Value(UserContextKey).(*models.User)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			roles, err := models.UserRoles(db, user.ID)
+			if err != nil {
+				log.Printf("role check failed: %v", err)
+				http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+				return
+			}
+			ctx := context.WithValue(r.Context(), RolesContextKey, roles)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireRole is RequireAuth for routes that need any of roles
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !auth.HasRole(rolesFromRequest(r), roles...) {
+				auditRequest(r, models.AuditAccessDenied, nil, map[string]string{"path": r.URL.Path, "required_role": strings.Join(roles, ",")})
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+// RequirePermission is RequireAuth for routes that need all of permissions
+func RequirePermission(permissions ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			roles := rolesFromRequest(r)
+			for _, permission := range permissions {
+				if !auth.HasPermission(roles, permission) {
+					auditRequest(r, models.AuditAccessDenied, nil, map[string]string{"path": r.URL.Path, "permission": permission})
+					http.Error(w, "Forbidden", http.StatusForbidden)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+// RequireAdmin is RequireRole(auth.RoleAdmin)
+func RequireAdmin(next http.Handler) http.Handler {
+	return RequireRole(auth.RoleAdmin)(next)
+}
@@ -0,0 +1,138 @@
+// models/account_deletion.go
+package models
+
+import (
+	"context"
+	"list-of-maldives/internal/database"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserExport is everything stored about a user, for data access requests
+type UserExport struct {
+	ExportedAt    time.Time             `json:"exported_at"`
+	Profile       User                  `json:"profile"`
+	StatusReason  string                `json:"status_reason,omitempty"`
+	Roles         []UserRole            `json:"roles"`
+	Identities    []Identity            `json:"identities"`
+	Sessions      []Session             `json:"sessions"`
+	Passkeys      []Passkey             `json:"passkeys"`
+	StatusHistory []AccountStatusChange `json:"status_history"`
+	AuditEvents   []AuditEvent          `json:"audit_events"`
+}
+
+// ExportUserData collects the rows that belong to user
+func ExportUserData(s database.Service, user *User) (*UserExport, error) {
+	db := s.GormDB()
+	export := UserExport{ExportedAt: time.Now()}
+	if err := db.First(&export.Profile, user.ID).Error; err != nil {
+		return nil, err
+	}
+	export.StatusReason = export.Profile.StatusReason
+
+	for _, query := range []struct {
+		dest  interface{}
+		order string
+	}{
+		{&export.Roles, "role"},
+		{&export.Identities, "created_at"},
+		{&export.Sessions, "created_at"},
+		{&export.Passkeys, "created_at"},
+		{&export.StatusHistory, "id"},
+	} {
+		if err := db.Where("user_id = ?", user.ID).Order(query.order).Find(query.dest).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := db.Where("user_uuid = ?", user.UUID).Order("id").Find(&export.AuditEvents).Error; err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// DeleteOwnAccount soft-deletes an account at its owner's request and
+// schedules it to be purged at purgeAt. Until then support can restore it;
+// the email stays taken.
+func DeleteOwnAccount(s database.Service, user *User, purgeAt time.Time) error {
+	// Set before deleting, so a failed delete leaves nothing to purge
+	if err := s.GormDB().Model(user).Update("purge_at", purgeAt).Error; err != nil {
+		return err
+	}
+	user.PurgeAt = &purgeAt
+	return SetAccountStatus(s, user, StatusDeleted, "deleted by the account owner", nil, user)
+}
+
+// purgeUser erases a deleted account and every row that refers to it
+func purgeUser(tx *gorm.DB, user *User) error {
+	for _, model := range []interface{}{
+		&Identity{}, &RefreshToken{}, &Session{}, &UserRole{}, &Passkey{},
+		&RecoveryCode{}, &PasswordResetToken{}, &AccountStatusChange{},
+	} {
+		if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Where("email = ?", user.Email).Delete(&MagicLinkToken{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("key = ?", AccountThrottleKey(user.Email)).Delete(&LoginThrottle{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_uuid = ?", user.UUID).Delete(&UserRevocation{}).Error; err != nil {
+		return err
+	}
+	// Erasure is the one exception to the audit log being append-only
+	if err := tx.Where("user_uuid = ? OR email = ?", user.UUID, user.Email).Delete(&AuditEvent{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Delete(user).Error
+}
+
+// PurgeDeletedUsers erases accounts whose grace period ended and returns how
+// many were purged. Instances running it at once skip each other's rows.
+func PurgeDeletedUsers(s database.Service, now time.Time) (int, error) {
+	purged := 0
+	for {
+		done := false
+		err := s.GormDB().Transaction(func(tx *gorm.DB) error {
+			var user User
+			err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+				Where("deleted_at IS NOT NULL AND purge_at <= ?", now).
+				Order("id").First(&user).Error
+			if err == gorm.ErrRecordNotFound {
+				done = true
+				return nil
+			} else if err != nil {
+				return err
+			}
+			return purgeUser(tx, &user)
+		})
+		if err != nil || done {
+			return purged, err
+		}
+		purged++
+	}
+}
+
+// RunAccountPurge calls PurgeDeletedUsers every interval until ctx is done
+func RunAccountPurge(ctx context.Context, s database.Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := PurgeDeletedUsers(s, time.Now())
+		if err != nil {
+			log.Printf("account purge failed: %v", err)
+		} else if n > 0 {
+			log.Printf("purged %d deleted accounts", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"encoding/json"
+	"list-of-maldives/internal/auth"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportUserDataContainsOnlyTheUsersRows(t *testing.T) {
+	s := testDB(t)
+	user := createUser(t, s, "user@example.com", "password123", true)
+	other := createUser(t, s, "other@example.com", "password123", true)
+
+	for _, u := range []*User{user, other} {
+		if err := GrantRole(s, u.ID, auth.RoleAdmin, nil); err != nil {
+			t.Fatalf("failed to grant role: %v", err)
+		}
+		if err := s.GormDB().Create(&Identity{UserID: u.ID, Provider: "google", Subject: u.UUID, Email: u.Email}).Error; err != nil {
+			t.Fatalf("failed to create identity: %v", err)
+		}
+		if _, err := CreateSession(s, u.ID, "password", "127.0.0.1", "test", time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+		if err := RecordAuditEvent(s, &AuditEvent{Type: AuditLoginSucceeded, UserUUID: u.UUID, Email: u.Email}); err != nil {
+			t.Fatalf("failed to record audit event: %v", err)
+		}
+	}
+	if err := SetAccountStatus(s, user, StatusSuspended, "abuse", nil, nil); err != nil {
+		t.Fatalf("failed to suspend user: %v", err)
+	}
+
+	export, err := ExportUserData(s, user)
+	if err != nil {
+		t.Fatalf("ExportUserData failed: %v", err)
+	}
+	if export.Profile.UUID != user.UUID || export.StatusReason != "abuse" {
+		t.Errorf("expected the profile of %s with its status reason; got %s, %q", user.UUID, export.Profile.UUID, export.StatusReason)
+	}
+	if len(export.Roles) != 1 || export.Roles[0].Role != auth.RoleAdmin {
+		t.Errorf("expected the admin role; got %+v", export.Roles)
+	}
+	if len(export.Identities) != 1 || export.Identities[0].Email != user.Email {
+		t.Errorf("expected the user's identity; got %+v", export.Identities)
+	}
+	if len(export.Sessions) != 1 || export.Sessions[0].UserID != user.ID {
+		t.Errorf("expected the user's session; got %+v", export.Sessions)
+	}
+	if len(export.StatusHistory) != 1 || export.StatusHistory[0].ToStatus != StatusSuspended {
+		t.Errorf("expected the suspension in the status history; got %+v", export.StatusHistory)
+	}
+	if len(export.AuditEvents) != 1 || export.AuditEvents[0].UserUUID != user.UUID {
+		t.Errorf("expected the user's audit event; got %+v", export.AuditEvents)
+	}
+
+	// Secrets stay out of the file the user downloads
+	encoded, err := json.Marshal(export)
+	if err != nil {
+		t.Fatalf("failed to encode export: %v", err)
+	}
+	var stored User
+	if err := s.GormDB().First(&stored, user.ID).Error; err != nil {
+		t.Fatalf("failed to reload user: %v", err)
+	}
+	if strings.Contains(string(encoded), stored.Password) {
+		t.Errorf("expected the password hash to be left out of the export")
+	}
+}
+
+func TestPurgeDeletedUsersErasesExpiredAccounts(t *testing.T) {
+	s := testDB(t)
+	expired := createUser(t, s, "expired@example.com", "password123", true)
+	pending := createUser(t, s, "pending@example.com", "password123", true)
+	kept := createUser(t, s, "kept@example.com", "password123", true)
+
+	now := time.Now()
+	for _, u := range []*User{expired, pending, kept} {
+		if _, err := CreateSession(s, u.ID, "password", "127.0.0.1", "test", now.Add(time.Hour)); err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+		if err := RecordAuditEvent(s, &AuditEvent{Type: AuditLoginSucceeded, UserUUID: u.UUID, Email: u.Email}); err != nil {
+			t.Fatalf("failed to record audit event: %v", err)
+		}
+	}
+	if err := DeleteOwnAccount(s, expired, now.Add(-time.Minute)); err != nil {
+		t.Fatalf("failed to delete account: %v", err)
+	}
+	if err := DeleteOwnAccount(s, pending, now.Add(time.Hour)); err != nil {
+		t.Fatalf("failed to delete account: %v", err)
+	}
+
+	purged, err := PurgeDeletedUsers(s, now)
+	if err != nil {
+		t.Fatalf("PurgeDeletedUsers failed: %v", err)
+	}
+	if purged != 1 {
+		t.Fatalf("expected 1 account to be purged; got %d", purged)
+	}
+
+	var count int64
+	s.GormDB().Unscoped().Model(&User{}).Where("id = ?", expired.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("expected the expired account to be erased")
+	}
+	for _, model := range []interface{}{&Session{}, &AccountStatusChange{}} {
+		s.GormDB().Model(model).Where("user_id = ?", expired.ID).Count(&count)
+		if count != 0 {
+			t.Errorf("expected the %T rows of the expired account to be erased; got %d", model, count)
+		}
+	}
+	s.GormDB().Model(&AuditEvent{}).Where("user_uuid = ?", expired.UUID).Count(&count)
+	if count != 0 {
+		t.Errorf("expected the audit events of the expired account to be erased; got %d", count)
+	}
+
+	// Accounts still in their grace period, and live ones, are left alone
+	for _, u := range []*User{pending, kept} {
+		s.GormDB().Unscoped().Model(&User{}).Where("id = ?", u.ID).Count(&count)
+		if count != 1 {
+			t.Errorf("expected %s to be kept", u.Email)
+		}
+		s.GormDB().Model(&Session{}).Where("user_id = ?", u.ID).Count(&count)
+		if count != 1 {
+			t.Errorf("expected the session of %s to be kept; got %d", u.Email, count)
+		}
+	}
+
+	// A second run has nothing left to do
+	if purged, err := PurgeDeletedUsers(s, now); err != nil || purged != 0 {
+		t.Errorf("expected nothing to purge; got %d, %v", purged, err)
+	}
+}
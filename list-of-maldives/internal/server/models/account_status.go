@@ -0,0 +1,176 @@
+// models/account_status.go
+package models
+
+import (
+	"errors"
+	"list-of-maldives/internal/database"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Account statuses. Pending-verification accounts can sign in but are kept out
+// of RequireVerified routes; suspended (until SuspendedUntil, if set) and
+// deleted accounts can't sign in at all.
+const (
+	StatusActive              = "active"
+	StatusPendingVerification = "pending_verification"
+	StatusSuspended           = "suspended"
+	StatusDeleted             = "deleted"
+)
+
+var (
+	ErrAccountSuspended = errors.New("account is suspended")
+	ErrAccountDeleted   = errors.New("account is deleted")
+	ErrInvalidStatus    = errors.New("invalid account status")
+	ErrStatusUnchanged  = errors.New("account already has this status")
+)
+
+// AccountStatusChange records who moved an account to another status and why
+type AccountStatusChange struct {
+	ID         uint       `gorm:"primaryKey" json:"-"`
+	UserID     uint       `gorm:"index;not null" json:"-"`
+	FromStatus string     `gorm:"size:30" json:"from"`
+	ToStatus   string     `gorm:"size:30;not null" json:"to"`
+	Reason     string     `gorm:"size:500" json:"reason,omitempty"`
+	Until      *time.Time `json:"until,omitempty"`
+	// ChangedBy is nil for changes the system made, e.g. email verification.
+	// The email is kept as it was, so the record survives the admin's account.
+	ChangedBy      *uint     `json:"-"`
+	ChangedByEmail string    `gorm:"size:255" json:"changed_by,omitempty"`
+	CreatedAt      time.Time `json:"changed_at"`
+}
+
+// statusAfterVerification is where an account in good standing belongs
+func statusAfterVerification(verified bool) string {
+	if verified {
+		return StatusActive
+	}
+	return StatusPendingVerification
+}
+
+// EffectiveStatus is Status with an expired suspension lifted
+func (u *User) EffectiveStatus() string {
+	if u.Status == "" || (u.Status == StatusSuspended && u.SuspendedUntil != nil && !time.Now().Before(*u.SuspendedUntil)) {
+		return statusAfterVerification(u.IsVerified)
+	}
+	return u.Status
+}
+
+// CanSignIn returns ErrAccountSuspended or ErrAccountDeleted for accounts
+// that must not get tokens, nil otherwise
+func (u *User) CanSignIn() error {
+	if u.DeletedAt.Valid {
+		return ErrAccountDeleted
+	}
+	switch u.EffectiveStatus() {
+	case StatusSuspended:
+		return ErrAccountSuspended
+	case StatusDeleted:
+		return ErrAccountDeleted
+	}
+	return nil
+}
+
+// SetAccountStatus moves user to status and records the change. until only
+// applies to suspensions and must be in the future; nil suspends indefinitely.
+// Deleting soft-deletes the row, and any other status restores it and
+// cancels a scheduled purge. Suspending or deleting also signs the user out
+// everywhere, in the same transaction.
+func SetAccountStatus(s database.Service, user *User, status, reason string, until *time.Time, changedBy *User) error {
+	switch status {
+	case StatusActive, StatusPendingVerification, StatusDeleted:
+		until = nil
+	case StatusSuspended:
+		if until != nil && !until.After(time.Now()) {
+			return ErrInvalidStatus
+		}
+	default:
+		return ErrInvalidStatus
+	}
+
+	return s.GormDB().Transaction(func(tx *gorm.DB) error {
+		// Lock the row so concurrent changes are recorded in order
+		var current User
+		if err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, user.ID).Error; err != nil {
+			return err
+		}
+		from := current.EffectiveStatus()
+		if current.DeletedAt.Valid {
+			from = StatusDeleted
+		}
+		if from == status && status != StatusSuspended {
+			return ErrStatusUnchanged
+		}
+
+		var deletedAt gorm.DeletedAt
+		if status == StatusDeleted {
+			deletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+		}
+		updates := map[string]interface{}{
+			"status":          status,
+			"status_reason":   reason,
+			"suspended_until": until,
+			"deleted_at":      deletedAt,
+		}
+		if status != StatusDeleted {
+			updates["purge_at"] = nil
+		}
+		if err := tx.Unscoped().Model(&current).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		change := AccountStatusChange{UserID: user.ID, FromStatus: from, ToStatus: status, Reason: reason, Until: until}
+		if changedBy != nil {
+			change.ChangedBy = &changedBy.ID
+			change.ChangedByEmail = changedBy.Email
+		}
+		if err := tx.Create(&change).Error; err != nil {
+			return err
+		}
+		if status == StatusSuspended || status == StatusDeleted {
+			if err := signOutEverywhere(tx, &current); err != nil {
+				return err
+			}
+		}
+
+		user.Status, user.StatusReason, user.SuspendedUntil = status, reason, until
+		user.DeletedAt = deletedAt
+		if status != StatusDeleted {
+			user.PurgeAt = nil
+		}
+		return nil
+	})
+}
+
+// MarkVerified records that user confirmed its email, activating an account
+// that was pending verification. It runs on tx so callers can use it inside
+// their own transactions.
+func MarkVerified(tx *gorm.DB, user *User) error {
+	if err := tx.Model(user).Update("is_verified", true).Error; err != nil {
+		return err
+	}
+	user.IsVerified = true
+
+	if user.Status != StatusPendingVerification {
+		return nil
+	}
+	if err := tx.Model(user).Update("status", StatusActive).Error; err != nil {
+		return err
+	}
+	user.Status = StatusActive
+	return tx.Create(&AccountStatusChange{
+		UserID:     user.ID,
+		FromStatus: StatusPendingVerification,
+		ToStatus:   StatusActive,
+		Reason:     "email verified",
+	}).Error
+}
+
+// ListStatusChanges returns a user's status history, newest first
+func ListStatusChanges(s database.Service, userID uint) ([]AccountStatusChange, error) {
+	var changes []AccountStatusChange
+	err := s.GormDB().Where("user_id = ?", userID).Order("id DESC").Find(&changes).Error
+	return changes, err
+}
@@ -0,0 +1,131 @@
+// models/audit.go
+package models
+
+import (
+	"list-of-maldives/internal/database"
+	"time"
+)
+
+// AuditEventType names what happened in an AuditEvent
+type AuditEventType string
+
+// Audit event types, grouped by the area that records them
+const (
+	AuditRegistered        AuditEventType = "user.registered"
+	AuditLoginSucceeded    AuditEventType = "login.succeeded"
+	AuditLoginFailed       AuditEventType = "login.failed"
+	AuditLoginBlocked      AuditEventType = "login.blocked"
+	AuditLogout            AuditEventType = "logout"
+	AuditTokenRefreshed    AuditEventType = "token.refreshed"
+	AuditTokenReused       AuditEventType = "token.reused"
+	AuditTokenRejected     AuditEventType = "token.rejected"
+	AuditAccessDenied      AuditEventType = "access.denied"
+	AuditOAuthFailed       AuditEventType = "oauth.failed"
+	AuditIdentityLinked    AuditEventType = "identity.linked"
+	AuditIdentityUnlinked  AuditEventType = "identity.unlinked"
+	AuditMFAChallenged     AuditEventType = "mfa.challenged"
+	AuditMFAFailed         AuditEventType = "mfa.failed"
+	AuditMFAEnabled        AuditEventType = "mfa.enabled"
+	AuditPasskeyAdded      AuditEventType = "passkey.added"
+	AuditPasskeyRemoved    AuditEventType = "passkey.removed"
+	AuditMagicLinkSent     AuditEventType = "magic_link.requested"
+	AuditEmailVerified     AuditEventType = "email.verified"
+	AuditEmailChangeSent   AuditEventType = "email.change_requested"
+	AuditEmailChanged      AuditEventType = "email.changed"
+	AuditPasswordResetSent AuditEventType = "password.reset_requested"
+	AuditPasswordReset     AuditEventType = "password.reset"
+	AuditPasswordChanged   AuditEventType = "password.changed"
+	AuditAccountUnlocked   AuditEventType = "account.unlocked"
+	AuditSessionRevoked    AuditEventType = "session.revoked"
+	AuditAccountDeleted    AuditEventType = "account.deleted"
+	AuditAccountExported   AuditEventType = "account.exported"
+	AuditStatusChanged     AuditEventType = "account.status_changed"
+	AuditForcedLogout      AuditEventType = "account.forced_logout"
+	AuditForcedReset       AuditEventType = "account.forced_password_reset"
+	AuditRoleGranted       AuditEventType = "role.granted"
+	AuditRoleRevoked       AuditEventType = "role.revoked"
+	AuditThrottleCleared   AuditEventType = "login_throttle.cleared"
+)
+
+// AuditEvent is one entry of the append-only security log. UserUUID is the
+// account the event is about and ActorUUID whoever caused it, when that was
+// someone else (an admin). Email is kept for attempts on unknown accounts.
+type AuditEvent struct {
+	ID        uint              `gorm:"primaryKey" json:"id"`
+	Type      AuditEventType    `gorm:"size:50;index;not null" json:"type"`
+	UserUUID  string            `gorm:"size:36;index" json:"user_uuid,omitempty"`
+	ActorUUID string            `gorm:"size:36" json:"actor_uuid,omitempty"`
+	Email     string            `gorm:"size:255;index" json:"email,omitempty"`
+	IP        string            `gorm:"size:45;index" json:"ip"`
+	UserAgent string            `gorm:"size:512" json:"user_agent"`
+	SessionID string            `gorm:"size:36" json:"session_id,omitempty"`
+	Details   map[string]string `gorm:"serializer:json;type:jsonb" json:"details,omitempty"`
+	CreatedAt time.Time         `gorm:"index" json:"created_at"`
+}
+
+// RecordAuditEvent appends event to the log. There is deliberately no way
+// to change or delete events, except purging an erased account's own events.
+func RecordAuditEvent(s database.Service, event *AuditEvent) error {
+	event.UserAgent = truncate(event.UserAgent, 512)
+	return s.GormDB().Create(event).Error
+}
+
+// AuditFilter narrows ListAuditEvents. Zero values don't filter.
+type AuditFilter struct {
+	Types    []AuditEventType
+	UserUUID string
+	Email    string
+	IP       string
+	Since    time.Time
+	Until    time.Time
+
+	Limit  int
+	Cursor string
+}
+
+// ListAuditEvents returns one page of events, newest first, and the cursor
+// of the next page ("" on the last page)
+func ListAuditEvents(s database.Service, f AuditFilter) ([]AuditEvent, string, error) {
+	if f.Limit <= 0 || f.Limit > 200 {
+		f.Limit = 50
+	}
+
+	query := s.GormDB().Model(&AuditEvent{})
+	if len(f.Types) > 0 {
+		query = query.Where("type IN ?", f.Types)
+	}
+	if f.UserUUID != "" {
+		query = query.Where("user_uuid = ?", f.UserUUID)
+	}
+	if f.Email != "" {
+		query = query.Where("email = ?", f.Email)
+	}
+	if f.IP != "" {
+		query = query.Where("ip = ?", f.IP)
+	}
+	if !f.Since.IsZero() {
+		query = query.Where("created_at >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		query = query.Where("created_at < ?", f.Until)
+	}
+	if f.Cursor != "" {
+		before, err := decodeCursor(f.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query = query.Where("id < ?", before)
+	}
+
+	var events []AuditEvent
+	if err := query.Order("id DESC").Limit(f.Limit + 1).Find(&events).Error; err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(events) > f.Limit {
+		events = events[:f.Limit]
+		next = encodeCursor(events[len(events)-1].ID)
+	}
+	return events, next, nil
+}
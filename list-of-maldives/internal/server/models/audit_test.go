@@ -0,0 +1,104 @@
+package models
+
+import (
+	"errors"
+	"list-of-maldives/internal/database"
+	"testing"
+	"time"
+)
+
+// recordAuditEvents appends events to the log in order
+func recordAuditEvents(t *testing.T, s database.Service, events ...AuditEvent) []AuditEvent {
+	t.Helper()
+	for i := range events {
+		if err := RecordAuditEvent(s, &events[i]); err != nil {
+			t.Fatalf("failed to record audit event: %v", err)
+		}
+	}
+	return events
+}
+
+func TestListAuditEventsFilters(t *testing.T) {
+	s := testDB(t)
+	now := time.Now()
+	events := recordAuditEvents(t, s,
+		AuditEvent{Type: AuditLoginFailed, Email: "a@example.com", IP: "10.0.0.1", CreatedAt: now.Add(-48 * time.Hour)},
+		AuditEvent{Type: AuditLoginSucceeded, UserUUID: "user-a", Email: "a@example.com", IP: "10.0.0.1", CreatedAt: now.Add(-time.Hour)},
+		AuditEvent{Type: AuditLoginFailed, Email: "b@example.com", IP: "10.0.0.2", CreatedAt: now.Add(-time.Hour)},
+		AuditEvent{Type: AuditPasswordChanged, UserUUID: "user-a", IP: "10.0.0.2", CreatedAt: now},
+	)
+
+	for _, test := range []struct {
+		name   string
+		filter AuditFilter
+		want   []int
+	}{
+		{"none", AuditFilter{}, []int{3, 2, 1, 0}},
+		{"types", AuditFilter{Types: []AuditEventType{AuditLoginFailed, AuditPasswordChanged}}, []int{3, 2, 0}},
+		{"user", AuditFilter{UserUUID: "user-a"}, []int{3, 1}},
+		{"email", AuditFilter{Email: "a@example.com"}, []int{1, 0}},
+		{"ip", AuditFilter{IP: "10.0.0.2"}, []int{3, 2}},
+		{"since", AuditFilter{Since: now.Add(-2 * time.Hour)}, []int{3, 2, 1}},
+		{"until", AuditFilter{Until: now.Add(-time.Minute)}, []int{2, 1, 0}},
+		{"combined", AuditFilter{Types: []AuditEventType{AuditLoginFailed}, IP: "10.0.0.1", Since: now.Add(-72 * time.Hour)}, []int{0}},
+	} {
+		got, next, err := ListAuditEvents(s, test.filter)
+		if err != nil {
+			t.Fatalf("%s: ListAuditEvents failed: %v", test.name, err)
+		}
+		if next != "" {
+			t.Errorf("%s: expected a single page; got cursor %q", test.name, next)
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: expected %d events; got %d", test.name, len(test.want), len(got))
+			continue
+		}
+		for i, index := range test.want {
+			if got[i].ID != events[index].ID {
+				t.Errorf("%s: expected event %d at position %d; got %d", test.name, events[index].ID, i, got[i].ID)
+			}
+		}
+	}
+}
+
+func TestListAuditEventsCursorPaging(t *testing.T) {
+	s := testDB(t)
+	var events []AuditEvent
+	for i := 0; i < 5; i++ {
+		events = append(events, AuditEvent{Type: AuditLoginSucceeded, UserUUID: "user-a"})
+	}
+	events = recordAuditEvents(t, s, events...)
+
+	var seen []uint
+	cursor := ""
+	for page := 0; ; page++ {
+		got, next, err := ListAuditEvents(s, AuditFilter{UserUUID: "user-a", Limit: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("ListAuditEvents failed: %v", err)
+		}
+		for _, event := range got {
+			seen = append(seen, event.ID)
+		}
+		if next == "" {
+			break
+		}
+		if page > 5 {
+			t.Fatalf("paging did not end")
+		}
+		cursor = next
+	}
+
+	// Newest first, each event exactly once
+	if len(seen) != len(events) {
+		t.Fatalf("expected %d events; got %d", len(events), len(seen))
+	}
+	for i, id := range seen {
+		if want := events[len(events)-1-i].ID; id != want {
+			t.Errorf("expected event %d at position %d; got %d", want, i, id)
+		}
+	}
+
+	if _, _, err := ListAuditEvents(s, AuditFilter{Cursor: "not-a-cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor; got %v", err)
+	}
+}
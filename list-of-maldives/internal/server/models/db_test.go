@@ -0,0 +1,23 @@
+package models
+
+import (
+	"list-of-maldives/internal/database"
+	"list-of-maldives/internal/database/dbtest"
+	"testing"
+)
+
+// testDB returns a migrated database; see dbtest.Open
+func testDB(t *testing.T) database.Service {
+	t.Helper()
+	return dbtest.Open(t, Tables()...)
+}
+
+// createUser stores a user with email and password, verified or not
+func createUser(t *testing.T, s database.Service, email, password string, verified bool) *User {
+	t.Helper()
+	user := User{Email: email, NickName: "Test", Password: password, IsVerified: verified}
+	if err := s.GormDB().Create(&user).Error; err != nil {
+		t.Fatalf("failed to create user %s: %v", email, err)
+	}
+	return &user
+}
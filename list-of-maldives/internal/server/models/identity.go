@@ -0,0 +1,108 @@
+// models/identity.go
+package models
+
+import (
+	"errors"
+	"list-of-maldives/internal/database"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEmailInUse           = errors.New("an account with this email already exists")
+	ErrIdentityLinked       = errors.New("this login is already linked to another account")
+	ErrLastLoginMethod      = errors.New("cannot remove the only way to sign in")
+	ErrIdentityNotFound     = errors.New("identity not found")
+	ErrProviderAlreadyInUse = errors.New("a login from this provider is already linked")
+)
+
+// Identity links a User to one external login (provider + provider subject)
+type Identity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index;not null" json:"-"`
+	Provider  string    `gorm:"size:50;not null;uniqueIndex:idx_identity_subject" json:"provider"`
+	Subject   string    `gorm:"size:255;not null;uniqueIndex:idx_identity_subject" json:"-"`
+	Email     string    `gorm:"size:255" json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ListIdentities returns the external logins linked to a user
+func ListIdentities(s database.Service, userID uint) ([]Identity, error) {
+	var identities []Identity
+	err := s.GormDB().Where("user_id = ?", userID).Order("created_at").Find(&identities).Error
+	return identities, err
+}
+
+// LinkIdentity attaches provider/subject to user. It fails if the login belongs
+// to another account or the user already has a login from that provider.
+func LinkIdentity(s database.Service, user *User, provider, subject, email string) (*Identity, error) {
+	var identity Identity
+	err := s.GormDB().Transaction(func(tx *gorm.DB) error {
+		var existing Identity
+		err := tx.Where("provider = ? AND subject = ?", provider, subject).First(&existing).Error
+		if err == nil {
+			if existing.UserID != user.ID {
+				return ErrIdentityLinked
+			}
+			identity = existing
+			return nil
+		} else if err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&Identity{}).Where("user_id = ? AND provider = ?", user.ID, provider).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrProviderAlreadyInUse
+		}
+
+		identity = Identity{UserID: user.ID, Provider: provider, Subject: subject, Email: email}
+		return tx.Create(&identity).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// UnlinkIdentity removes the user's login for provider, refusing to remove
+// the last way the user can sign in
+func UnlinkIdentity(s database.Service, user *User, provider string) error {
+	return s.GormDB().Transaction(func(tx *gorm.DB) error {
+		var identities []Identity
+		if err := tx.Where("user_id = ?", user.ID).Find(&identities).Error; err != nil {
+			return err
+		}
+
+		var target *Identity
+		for i := range identities {
+			if identities[i].Provider == provider {
+				target = &identities[i]
+			}
+		}
+		if target == nil {
+			return ErrIdentityNotFound
+		}
+		var passkeys int64
+		if err := tx.Model(&Passkey{}).Where("user_id = ?", user.ID).Count(&passkeys).Error; err != nil {
+			return err
+		}
+		if len(identities) == 1 && passkeys == 0 && user.Password == "" {
+			return ErrLastLoginMethod
+		}
+		if err := tx.Delete(target).Error; err != nil {
+			return err
+		}
+
+		// Don't let the legacy provider_id lookup silently re-link this login
+		if user.Provider == provider && user.ProviderID == target.Subject {
+			user.ProviderID = ""
+			return tx.Model(user).Update("provider_id", "").Error
+		}
+		return nil
+	})
+}
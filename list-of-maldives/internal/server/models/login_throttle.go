@@ -0,0 +1,121 @@
+// models/login_throttle.go
+package models
+
+import (
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/database"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginThrottle counts consecutive failed logins for one key, either an
+// account ("account:<email>") or a client IP ("ip:<addr>")
+type LoginThrottle struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Key           string     `gorm:"size:320;uniqueIndex;not null" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	BlockedUntil  *time.Time `gorm:"index" json:"blocked_until,omitempty"`
+	Locked        bool       `gorm:"default:false" json:"locked"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// AccountThrottleKey is the throttle key for login attempts against email
+func AccountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// IPThrottleKey is the throttle key for login attempts from ip
+func IPThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// MFAChallengeThrottleKey is the throttle key for codes tried against the
+// MFA challenge with token id jti
+func MFAChallengeThrottleKey(jti string) string {
+	return "mfa:" + jti
+}
+
+// LoginBlockedFor returns how much longer the most restricted of keys is blocked
+func LoginBlockedFor(s database.Service, keys ...string) (time.Duration, error) {
+	var throttles []LoginThrottle
+	now := time.Now()
+	err := s.GormDB().Where("key IN ? AND blocked_until > ?", keys, now).Find(&throttles).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var wait time.Duration
+	for _, t := range throttles {
+		wait = max(wait, t.BlockedUntil.Sub(now))
+	}
+	return wait, nil
+}
+
+// RecordLoginFailure counts a failed attempt for key and blocks it as policy says
+func RecordLoginFailure(s database.Service, key string, policy auth.LockoutPolicy) (*LoginThrottle, error) {
+	var throttle LoginThrottle
+	err := s.GormDB().Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&LoginThrottle{Key: key, LastFailureAt: now}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("key = ?", key).First(&throttle).Error; err != nil {
+			return err
+		}
+
+		// Start over once earlier failures have aged out
+		if policy.Window > 0 && now.Sub(throttle.LastFailureAt) > policy.Window {
+			throttle.Failures = 0
+		}
+		throttle.Failures++
+		throttle.LastFailureAt = now
+
+		delay, locked := policy.BlockFor(throttle.Failures)
+		throttle.Locked = locked
+		throttle.BlockedUntil = nil
+		if delay > 0 {
+			until := now.Add(delay)
+			throttle.BlockedUntil = &until
+		}
+
+		return tx.Model(&throttle).Updates(map[string]interface{}{
+			"failures":        throttle.Failures,
+			"last_failure_at": throttle.LastFailureAt,
+			"blocked_until":   throttle.BlockedUntil,
+			"locked":          throttle.Locked,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+// ClearLoginThrottle forgets the failures recorded for key
+func ClearLoginThrottle(s database.Service, key string) (bool, error) {
+	result := s.GormDB().Where("key = ?", key).Delete(&LoginThrottle{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListLoginThrottles returns throttles whose key starts with prefix, most
+// recent failure first. Only currently blocked keys are returned if blocked is set.
+func ListLoginThrottles(s database.Service, prefix string, blocked bool, limit int) ([]LoginThrottle, error) {
+	query := s.GormDB().Order("last_failure_at DESC").Limit(limit)
+	if prefix != "" {
+		query = query.Where("key LIKE ?", strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix)+"%")
+	}
+	if blocked {
+		query = query.Where("blocked_until > ?", time.Now())
+	}
+
+	var throttles []LoginThrottle
+	err := query.Find(&throttles).Error
+	return throttles, err
+}
@@ -0,0 +1,111 @@
+// models/magic_link.go
+package models
+
+import (
+	"crypto/subtle"
+	"errors"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/database"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrMagicLinkInvalid = errors.New("magic link is invalid or expired")
+
+// MagicLinkToken is a single-use sign-in link sent to Email. It can only be
+// redeemed by the browser holding the nonce cookie set when it was requested.
+// Only SHA-256 hashes of the token and nonce are stored.
+type MagicLinkToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	Email     string     `gorm:"size:255;index;not null" json:"email"`
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	NonceHash string     `gorm:"size:64;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// CreateMagicLinkToken issues a sign-in token for email bound to nonce and
+// invalidates any earlier ones that haven't been used
+func CreateMagicLinkToken(s database.Service, email, nonce string, ttl time.Duration) (string, error) {
+	raw, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.GormDB().Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&MagicLinkToken{}).
+			Where("email = ? AND used_at IS NULL", email).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&MagicLinkToken{
+			Email:     email,
+			TokenHash: auth.HashOpaqueToken(raw),
+			NonceHash: auth.HashOpaqueToken(nonce),
+			ExpiresAt: now.Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// ConsumeMagicLinkToken redeems raw with the requesting browser's nonce and
+// returns the user for its email, creating a "magic" user if there is none.
+// Opening the link proves the address, so the user is marked verified; an
+// unverified account is claimed first, see claimUnverifiedAccount.
+func ConsumeMagicLinkToken(s database.Service, raw, nonce string) (*User, error) {
+	var user User
+	err := s.GormDB().Transaction(func(tx *gorm.DB) error {
+		var token MagicLinkToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", auth.HashOpaqueToken(raw)).
+			First(&token).Error
+		if err == gorm.ErrRecordNotFound {
+			return ErrMagicLinkInvalid
+		} else if err != nil {
+			return err
+		}
+		if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+			return ErrMagicLinkInvalid
+		}
+		if subtle.ConstantTimeCompare([]byte(token.NonceHash), []byte(auth.HashOpaqueToken(nonce))) != 1 {
+			return ErrMagicLinkInvalid
+		}
+		if err := tx.Model(&token).Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		// Deleted accounts keep their email until they are purged
+		err = tx.Unscoped().Where("email = ?", token.Email).First(&user).Error
+		if err == nil && user.DeletedAt.Valid {
+			return ErrAccountDeleted
+		} else if err == gorm.ErrRecordNotFound {
+			user = User{
+				Email:      token.Email,
+				Provider:   "magic",
+				IsVerified: true,
+			}
+			return tx.Create(&user).Error
+		} else if err != nil {
+			return err
+		}
+
+		if !user.IsVerified {
+			if err := claimUnverifiedAccount(tx, &user); err != nil {
+				return err
+			}
+			return MarkVerified(tx, &user)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
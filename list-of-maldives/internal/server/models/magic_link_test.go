@@ -0,0 +1,96 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConsumeMagicLinkTokenClaimsUnverifiedAccount(t *testing.T) {
+	s := testDB(t)
+
+	// Someone registers the victim's email with a password and never verifies it
+	squatter := createUser(t, s, "victim@example.com", "squatter-password", false)
+	session, err := CreateSession(s, squatter.ID, "email", "127.0.0.1", "test", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	// The victim signs in with a magic link
+	token, err := CreateMagicLinkToken(s, "victim@example.com", "nonce", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create magic link: %v", err)
+	}
+	user, err := ConsumeMagicLinkToken(s, token, "nonce")
+	if err != nil {
+		t.Fatalf("ConsumeMagicLinkToken failed: %v", err)
+	}
+	if user.ID != squatter.ID {
+		t.Fatalf("expected the existing account to be claimed")
+	}
+
+	var stored User
+	if err := s.GormDB().First(&stored, user.ID).Error; err != nil {
+		t.Fatalf("failed to reload user: %v", err)
+	}
+	if !stored.IsVerified {
+		t.Errorf("expected the account to be verified")
+	}
+	if stored.Password != "" || stored.CheckPassword("squatter-password") {
+		t.Errorf("expected the squatter's password to be removed")
+	}
+	if err := CheckSession(s, session.UUID, user.ID, "127.0.0.1"); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("expected the squatter's session to be revoked; got %v", err)
+	}
+}
+
+func TestConsumeMagicLinkToken(t *testing.T) {
+	s := testDB(t)
+	owner := createUser(t, s, "owner@example.com", "owner-password", true)
+
+	token, err := CreateMagicLinkToken(s, "owner@example.com", "nonce", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create magic link: %v", err)
+	}
+	if _, err := ConsumeMagicLinkToken(s, token, "other-browser"); !errors.Is(err, ErrMagicLinkInvalid) {
+		t.Fatalf("expected a link opened in another browser to be rejected; got %v", err)
+	}
+
+	// A verified account keeps its password
+	user, err := ConsumeMagicLinkToken(s, token, "nonce")
+	if err != nil {
+		t.Fatalf("ConsumeMagicLinkToken failed: %v", err)
+	}
+	if user.ID != owner.ID || !user.CheckPassword("owner-password") {
+		t.Errorf("expected the verified account to be signed in unchanged")
+	}
+	if _, err := ConsumeMagicLinkToken(s, token, "nonce"); !errors.Is(err, ErrMagicLinkInvalid) {
+		t.Errorf("expected the link to be single-use; got %v", err)
+	}
+
+	// Unknown addresses get a new account
+	token, _ = CreateMagicLinkToken(s, "new@example.com", "nonce", time.Minute)
+	user, err = ConsumeMagicLinkToken(s, token, "nonce")
+	if err != nil {
+		t.Fatalf("ConsumeMagicLinkToken failed: %v", err)
+	}
+	if user.Provider != "magic" || !user.IsVerified {
+		t.Errorf("expected a verified magic user; got %s, %t", user.Provider, user.IsVerified)
+	}
+}
+
+func TestConsumeMagicLinkTokenRefusesDeletedAccount(t *testing.T) {
+	s := testDB(t)
+	owner := createUser(t, s, "owner@example.com", "owner-password", true)
+	if err := DeleteOwnAccount(s, owner, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("failed to delete account: %v", err)
+	}
+
+	token, err := CreateMagicLinkToken(s, "owner@example.com", "nonce", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create magic link: %v", err)
+	}
+	if _, err := ConsumeMagicLinkToken(s, token, "nonce"); !errors.Is(err, ErrAccountDeleted) {
+		t.Errorf("expected ErrAccountDeleted; got %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+// models/mfa.go
+package models
+
+import (
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/database"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCode is a one-time MFA backup code; only its SHA-256 hash is stored
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	CodeHash  string     `gorm:"size:64;not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// EnableTOTP turns MFA on for the user and replaces their recovery codes
+func EnableTOTP(s database.Service, user *User, step int64, recoveryCodes []string) error {
+	return s.GormDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		for _, code := range recoveryCodes {
+			rc := RecoveryCode{UserID: user.ID, CodeHash: auth.HashOpaqueToken(auth.NormalizeRecoveryCode(code))}
+			if err := tx.Create(&rc).Error; err != nil {
+				return err
+			}
+		}
+
+		user.MFAEnabled = true
+		user.TOTPLastStep = step
+		return tx.Model(user).Updates(map[string]interface{}{
+			"mfa_enabled":    true,
+			"totp_last_step": step,
+		}).Error
+	})
+}
+
+// AcceptTOTPStep records step as used; it fails if that step or a later one
+// was already accepted, which stops a code from being replayed
+func AcceptTOTPStep(s database.Service, userID uint, step int64) (bool, error) {
+	result := s.GormDB().Model(&User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+// UseRecoveryCode consumes one of the user's unused recovery codes
+func UseRecoveryCode(s database.Service, userID uint, code string) (bool, error) {
+	result := s.GormDB().Model(&RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, auth.HashOpaqueToken(auth.NormalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
@@ -0,0 +1,38 @@
+// models/migrations.go
+package models
+
+// Migration is a model AutoMigrate creates a table for
+type Migration struct {
+	Name  string
+	Model interface{}
+}
+
+// Migrations lists every model in the order the server migrates them
+var Migrations = []Migration{
+	{"User", &User{}},
+	{"UserRole", &UserRole{}},
+	{"AccountStatusChange", &AccountStatusChange{}},
+	{"Identity", &Identity{}},
+	{"RefreshToken", &RefreshToken{}},
+	{"Session", &Session{}},
+	{"RevokedToken", &RevokedToken{}},
+	{"UserRevocation", &UserRevocation{}},
+	{"PasswordResetToken", &PasswordResetToken{}},
+	{"RecoveryCode", &RecoveryCode{}},
+	{"Passkey", &Passkey{}},
+	{"PasskeyCeremony", &PasskeyCeremony{}},
+	{"MagicLinkToken", &MagicLinkToken{}},
+	{"LoginThrottle", &LoginThrottle{}},
+	{"RateLimitBucket", &RateLimitBucket{}},
+	{"HTTPSession", &HTTPSession{}},
+	{"AuditEvent", &AuditEvent{}},
+}
+
+// Tables returns the model of every migration, e.g. for dbtest.Open
+func Tables() []interface{} {
+	tables := make([]interface{}, len(Migrations))
+	for i, m := range Migrations {
+		tables[i] = m.Model
+	}
+	return tables
+}
@@ -0,0 +1,164 @@
+// models/passkey.go
+package models
+
+import (
+	"errors"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/database"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPasskeyNotFound = errors.New("passkey not found")
+	ErrPasskeyInUse    = errors.New("this passkey is already registered")
+)
+
+// Passkey is a WebAuthn credential registered by a user
+type Passkey struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	UserID          uint       `gorm:"index;not null" json:"-"`
+	Name            string     `gorm:"size:100" json:"name"`
+	CredentialID    []byte     `gorm:"uniqueIndex;not null" json:"-"`
+	PublicKey       []byte     `gorm:"not null" json:"-"`
+	AttestationType string     `gorm:"size:50" json:"-"`
+	Transports      string     `gorm:"size:255" json:"transports"`
+	AAGUID          []byte     `json:"-"`
+	SignCount       uint32     `json:"-"`
+	BackupEligible  bool       `json:"backup_eligible"`
+	BackupState     bool       `json:"backup_state"`
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// Credential converts the stored row back into a webauthn.Credential
+func (p *Passkey) Credential() webauthn.Credential {
+	var transports []protocol.AuthenticatorTransport
+	for _, t := range strings.Split(p.Transports, ",") {
+		if t != "" {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+	}
+	return webauthn.Credential{
+		ID:              p.CredentialID,
+		PublicKey:       p.PublicKey,
+		AttestationType: p.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			BackupEligible: p.BackupEligible,
+			BackupState:    p.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    p.AAGUID,
+			SignCount: p.SignCount,
+		},
+	}
+}
+
+// PasskeyUser loads user's passkeys into the form the relying party expects.
+// The UUID is the user handle so no email ends up on the authenticator.
+func PasskeyUser(s database.Service, user *User) (*auth.PasskeyUser, error) {
+	passkeys, err := ListPasskeys(s, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	pu := &auth.PasskeyUser{
+		Handle:      []byte(user.UUID),
+		Name:        user.Email,
+		DisplayName: user.NickName,
+	}
+	if pu.DisplayName == "" {
+		pu.DisplayName = user.Email
+	}
+	for i := range passkeys {
+		pu.Credentials = append(pu.Credentials, passkeys[i].Credential())
+	}
+	return pu, nil
+}
+
+// ListPasskeys returns the passkeys registered by a user
+func ListPasskeys(s database.Service, userID uint) ([]Passkey, error) {
+	var passkeys []Passkey
+	err := s.GormDB().Where("user_id = ?", userID).Order("created_at").Find(&passkeys).Error
+	return passkeys, err
+}
+
+// CreatePasskey stores a credential produced by a registration ceremony
+func CreatePasskey(s database.Service, userID uint, name string, cred *webauthn.Credential) (*Passkey, error) {
+	db := s.GormDB()
+
+	var count int64
+	if err := db.Model(&Passkey{}).Where("credential_id = ?", cred.ID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrPasskeyInUse
+	}
+
+	transports := make([]string, 0, len(cred.Transport))
+	for _, t := range cred.Transport {
+		transports = append(transports, string(t))
+	}
+	passkey := Passkey{
+		UserID:          userID,
+		Name:            name,
+		CredentialID:    cred.ID,
+		PublicKey:       cred.PublicKey,
+		AttestationType: cred.AttestationType,
+		Transports:      strings.Join(transports, ","),
+		AAGUID:          cred.Authenticator.AAGUID,
+		SignCount:       cred.Authenticator.SignCount,
+		BackupEligible:  cred.Flags.BackupEligible,
+		BackupState:     cred.Flags.BackupState,
+	}
+	if err := db.Create(&passkey).Error; err != nil {
+		return nil, err
+	}
+	return &passkey, nil
+}
+
+// RecordPasskeyUse saves the sign count and backup state from a successful assertion
+func RecordPasskeyUse(s database.Service, cred *webauthn.Credential) error {
+	return s.GormDB().Model(&Passkey{}).
+		Where("credential_id = ?", cred.ID).
+		Updates(map[string]interface{}{
+			"sign_count":   cred.Authenticator.SignCount,
+			"backup_state": cred.Flags.BackupState,
+			"last_used_at": time.Now(),
+		}).Error
+}
+
+// DeletePasskey removes one of user's passkeys, refusing to remove the last
+// way the user can sign in
+func DeletePasskey(s database.Service, user *User, id uint) error {
+	return s.GormDB().Transaction(func(tx *gorm.DB) error {
+		var passkeys []Passkey
+		if err := tx.Where("user_id = ?", user.ID).Find(&passkeys).Error; err != nil {
+			return err
+		}
+
+		var target *Passkey
+		for i := range passkeys {
+			if passkeys[i].ID == id {
+				target = &passkeys[i]
+			}
+		}
+		if target == nil {
+			return ErrPasskeyNotFound
+		}
+
+		var identities int64
+		if err := tx.Model(&Identity{}).Where("user_id = ?", user.ID).Count(&identities).Error; err != nil {
+			return err
+		}
+		if len(passkeys) == 1 && identities == 0 && user.Password == "" {
+			return ErrLastLoginMethod
+		}
+		return tx.Delete(target).Error
+	})
+}
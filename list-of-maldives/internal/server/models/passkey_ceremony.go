@@ -0,0 +1,45 @@
+// models/passkey_ceremony.go
+package models
+
+import (
+	"list-of-maldives/internal/database"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PasskeyCeremony is a WebAuthn begin/finish ceremony. Its state lives in a
+// cookie the browser could send again, so finish claims the row to make sure
+// each challenge is only answered once.
+type PasskeyCeremony struct {
+	ID        string    `gorm:"primaryKey;size:36"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// CreatePasskeyCeremony starts a ceremony that can be claimed until ttl
+// passes and returns its ID
+func CreatePasskeyCeremony(s database.Service, ttl time.Duration) (string, error) {
+	db := s.GormDB()
+	ceremony := PasskeyCeremony{ID: uuid.New().String(), ExpiresAt: time.Now().Add(ttl)}
+	if err := db.Create(&ceremony).Error; err != nil {
+		return "", err
+	}
+
+	// Expired ceremonies can't be claimed anyway
+	if err := db.Where("expires_at < ?", time.Now()).Delete(&PasskeyCeremony{}).Error; err != nil {
+		return "", err
+	}
+	return ceremony.ID, nil
+}
+
+// ClaimPasskeyCeremony marks the ceremony as used and reports whether it was
+// still unused and unexpired. Only one of several concurrent claims succeeds.
+func ClaimPasskeyCeremony(s database.Service, id string) (bool, error) {
+	now := time.Now()
+	result := s.GormDB().Model(&PasskeyCeremony{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", id, now).
+		Update("used_at", now)
+	return result.RowsAffected == 1, result.Error
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClaimPasskeyCeremonyOnce(t *testing.T) {
+	s := testDB(t)
+
+	id, err := CreatePasskeyCeremony(s, time.Minute)
+	if err != nil {
+		t.Fatalf("CreatePasskeyCeremony failed: %v", err)
+	}
+	if claimed, err := ClaimPasskeyCeremony(s, id); err != nil || !claimed {
+		t.Fatalf("expected the first claim to succeed; got %t, %v", claimed, err)
+	}
+	if claimed, err := ClaimPasskeyCeremony(s, id); err != nil || claimed {
+		t.Errorf("expected a second claim to fail; got %t, %v", claimed, err)
+	}
+
+	if claimed, _ := ClaimPasskeyCeremony(s, "00000000-0000-0000-0000-000000000000"); claimed {
+		t.Errorf("expected an unknown ceremony not to be claimed")
+	}
+
+	expired, err := CreatePasskeyCeremony(s, -time.Second)
+	if err != nil {
+		t.Fatalf("CreatePasskeyCeremony failed: %v", err)
+	}
+	if claimed, _ := ClaimPasskeyCeremony(s, expired); claimed {
+		t.Errorf("expected an expired ceremony not to be claimed")
+	}
+}
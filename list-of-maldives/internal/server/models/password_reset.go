@@ -0,0 +1,96 @@
+// models/password_reset.go
+package models
+
+import (
+	"errors"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/database"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrResetTokenInvalid = errors.New("password reset token is invalid or expired")
+
+// PasswordResetToken is a single-use token emailed to reset a password.
+// Only its SHA-256 hash is stored.
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// CreatePasswordResetToken issues a reset token for userID and invalidates
+// any earlier ones that haven't been used
+func CreatePasswordResetToken(s database.Service, userID uint, ttl time.Duration) (string, error) {
+	raw, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.GormDB().Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", userID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&PasswordResetToken{
+			UserID:    userID,
+			TokenHash: auth.HashOpaqueToken(raw),
+			ExpiresAt: now.Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// ResetPassword consumes raw, stores newPassword for its user and signs the
+// user out everywhere, all in one transaction
+func ResetPassword(s database.Service, raw, newPassword string) (*User, error) {
+	var user User
+	err := s.GormDB().Transaction(func(tx *gorm.DB) error {
+		var token PasswordResetToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", auth.HashOpaqueToken(raw)).
+			First(&token).Error
+		if err == gorm.ErrRecordNotFound {
+			return ErrResetTokenInvalid
+		} else if err != nil {
+			return err
+		}
+		if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+			return ErrResetTokenInvalid
+		}
+
+		if err := tx.First(&user, token.UserID).Error; err != nil {
+			return ErrResetTokenInvalid
+		}
+		if err := tx.Model(&token).Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		user.Password = newPassword
+		if err := user.HashPassword(); err != nil {
+			return err
+		}
+		user.MustResetPassword = false
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"password":            user.Password,
+			"must_reset_password": false,
+		}).Error; err != nil {
+			return err
+		}
+		return signOutEverywhere(tx, &user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
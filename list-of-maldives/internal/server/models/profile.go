@@ -0,0 +1,105 @@
+// models/profile.go
+package models
+
+import (
+	"errors"
+	"list-of-maldives/internal/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrEmailChangeInvalid = errors.New("email change link is invalid or was replaced")
+
+// EmailTaken reports whether an account other than exceptUserID, deleted
+// ones included, uses email
+func EmailTaken(s database.Service, email string, exceptUserID uint) (bool, error) {
+	var count int64
+	err := s.GormDB().Unscoped().Model(&User{}).Where("email = ? AND id <> ?", email, exceptUserID).Count(&count).Error
+	return count > 0, err
+}
+
+// RequestEmailChange remembers the address user wants to switch to. Only a
+// link for the latest requested address can confirm the change.
+func RequestEmailChange(s database.Service, user *User, email string) error {
+	if err := s.GormDB().Model(user).Update("pending_email", email).Error; err != nil {
+		return err
+	}
+	user.PendingEmail = email
+	return nil
+}
+
+// ConfirmEmailChange switches the user to email, which it proved it owns, and
+// marks the account verified. It also returns the previous address.
+func ConfirmEmailChange(s database.Service, userUUID, email string) (*User, string, error) {
+	var user User
+	var previous string
+	err := s.GormDB().Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("uuid = ?", userUUID).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrEmailChangeInvalid
+		} else if err != nil {
+			return err
+		}
+		if user.PendingEmail == "" || user.PendingEmail != email {
+			return ErrEmailChangeInvalid
+		}
+
+		var count int64
+		if err := tx.Unscoped().Model(&User{}).Where("email = ? AND id <> ?", email, user.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrEmailInUse
+		}
+
+		previous = user.Email
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"email":         email,
+			"pending_email": "",
+		}).Error; err != nil {
+			return err
+		}
+		user.Email, user.PendingEmail = email, ""
+		return MarkVerified(tx, &user)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return &user, previous, nil
+}
+
+// ChangePassword replaces user's password, which also satisfies a forced
+// reset, and signs the user out on every other device in the same
+// transaction. keepSessionUUID is the session the change was made from; if
+// it isn't a live session of user, the user is signed out everywhere and
+// kept is false.
+func ChangePassword(s database.Service, user *User, password, keepSessionUUID string) (kept bool, err error) {
+	hashed := User{Password: password}
+	if err := hashed.HashPassword(); err != nil {
+		return false, err
+	}
+	err = s.GormDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"password":            hashed.Password,
+			"must_reset_password": false,
+		}).Error; err != nil {
+			return err
+		}
+
+		var keep Session
+		err := tx.Where("uuid = ? AND user_id = ? AND revoked_at IS NULL", keepSessionUUID, user.ID).First(&keep).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return signOutEverywhere(tx, user)
+		} else if err != nil {
+			return err
+		}
+		kept = true
+		return revokeOtherSessions(tx, user.ID, &keep)
+	})
+	if err != nil {
+		return false, err
+	}
+	user.Password, user.MustResetPassword = hashed.Password, false
+	return kept, nil
+}
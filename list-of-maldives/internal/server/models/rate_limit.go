@@ -0,0 +1,72 @@
+// models/rate_limit.go
+package models
+
+import (
+	"context"
+	"list-of-maldives/internal/database"
+	"list-of-maldives/internal/ratelimit"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RateLimitBucket stores a GCRA bucket shared by every API instance.
+// TAT is the bucket's theoretical arrival time; it is full once TAT has passed.
+type RateLimitBucket struct {
+	Key string    `gorm:"primaryKey;size:400" json:"key"`
+	TAT time.Time `gorm:"index;not null" json:"tat"`
+}
+
+// RateLimitStore is the Postgres-backed ratelimit.Store
+type RateLimitStore struct {
+	db database.Service
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewRateLimitStore(s database.Service) *RateLimitStore {
+	return &RateLimitStore{db: s}
+}
+
+func (s *RateLimitStore) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	var result ratelimit.Result
+	err := s.db.GormDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&RateLimitBucket{Key: key, TAT: now}).Error; err != nil {
+			return err
+		}
+
+		var bucket RateLimitBucket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("key = ?", key).First(&bucket).Error; err != nil {
+			return err
+		}
+
+		var tat time.Time
+		tat, result = limit.Allow(bucket.TAT, now)
+		return tx.Model(&bucket).Update("tat", tat).Error
+	})
+	if err != nil {
+		return result, err
+	}
+
+	s.sweep(ctx)
+	return result, nil
+}
+
+// sweep deletes full buckets at most once a minute per instance
+func (s *RateLimitStore) sweep(ctx context.Context) {
+	s.mu.Lock()
+	if time.Since(s.lastSweep) < time.Minute {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = time.Now()
+	s.mu.Unlock()
+
+	s.db.GormDB().WithContext(ctx).Where("tat < ?", time.Now()).Delete(&RateLimitBucket{})
+}
@@ -0,0 +1,153 @@
+// models/refresh_token.go
+package models
+
+import (
+	"errors"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/database"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// RefreshToken is an opaque, single-use token exchanged for a new access token.
+// Every rotation stays in the same family, so replaying a token that was
+// already used revokes the whole chain.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	FamilyID  string     `gorm:"size:36;index;not null" json:"family_id"`
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// IssueRefreshToken stores a new refresh token for the user and returns its raw value.
+// An empty familyID starts a new family.
+func IssueRefreshToken(s database.Service, userID uint, familyID string, ttl time.Duration) (string, *RefreshToken, error) {
+	return issueRefreshToken(s.GormDB(), userID, familyID, ttl)
+}
+
+func issueRefreshToken(db *gorm.DB, userID uint, familyID string, ttl time.Duration) (string, *RefreshToken, error) {
+	raw, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+	if familyID == "" {
+		familyID = uuid.New().String()
+	}
+
+	token := RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: auth.HashOpaqueToken(raw),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := db.Create(&token).Error; err != nil {
+		return "", nil, err
+	}
+	return raw, &token, nil
+}
+
+// RotateRefreshToken consumes raw and issues its successor in the same family.
+// Presenting a token that was already used revokes the entire family and
+// returns ErrRefreshTokenReused.
+func RotateRefreshToken(s database.Service, raw string, ttl time.Duration) (string, *RefreshToken, error) {
+	db := s.GormDB()
+
+	var (
+		next      string
+		rotated   *RefreshToken
+		reusedFam string
+	)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var current RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", auth.HashOpaqueToken(raw)).
+			First(&current).Error
+		if err == gorm.ErrRecordNotFound {
+			return ErrRefreshTokenInvalid
+		} else if err != nil {
+			return err
+		}
+
+		if current.UsedAt != nil && current.RevokedAt == nil {
+			reusedFam = current.FamilyID
+			return nil
+		}
+		if current.RevokedAt != nil || time.Now().After(current.ExpiresAt) {
+			return ErrRefreshTokenInvalid
+		}
+
+		now := time.Now()
+		if err := tx.Model(&current).Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		next, rotated, err = issueRefreshToken(tx, current.UserID, current.FamilyID, ttl)
+		return err
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	if reusedFam != "" {
+		if err := RevokeRefreshFamily(s, reusedFam); err != nil {
+			return "", nil, err
+		}
+		return "", nil, ErrRefreshTokenReused
+	}
+	return next, rotated, nil
+}
+
+// RevokeRefreshFamily revokes every live token descending from the same login,
+// and the session that login started
+func RevokeRefreshFamily(s database.Service, familyID string) error {
+	now := time.Now()
+	return s.GormDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Session{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now).Error
+	})
+}
+
+// RevokeRefreshToken revokes the family raw belongs to. Unknown tokens are ignored.
+func RevokeRefreshToken(s database.Service, raw string) error {
+	var token RefreshToken
+	err := s.GormDB().Where("token_hash = ?", auth.HashOpaqueToken(raw)).First(&token).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return RevokeRefreshFamily(s, token.FamilyID)
+}
+
+// RevokeUserRefreshTokens revokes all refresh tokens and sessions belonging to a user
+func RevokeUserRefreshTokens(s database.Service, userID uint) error {
+	now := time.Now()
+	return s.GormDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error
+	})
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	s := testDB(t)
+	user := createUser(t, s, "rotate@example.com", "", true)
+
+	first, issued, err := IssueRefreshToken(s, user.ID, "", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to issue refresh token: %v", err)
+	}
+	second, rotated, err := RotateRefreshToken(s, first, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to rotate refresh token: %v", err)
+	}
+	if second == first {
+		t.Fatalf("expected a new refresh token")
+	}
+	if rotated.FamilyID != issued.FamilyID || rotated.UserID != user.ID {
+		t.Fatalf("expected the rotated token in family %s of user %d; got %s of %d", issued.FamilyID, user.ID, rotated.FamilyID, rotated.UserID)
+	}
+
+	if _, _, err := RotateRefreshToken(s, second, time.Hour); err != nil {
+		t.Fatalf("failed to rotate the successor: %v", err)
+	}
+
+	if _, _, err := RotateRefreshToken(s, "not-a-token", time.Hour); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("expected ErrRefreshTokenInvalid for an unknown token; got %v", err)
+	}
+}
+
+func TestRotateRefreshTokenReuseRevokesFamily(t *testing.T) {
+	s := testDB(t)
+	user := createUser(t, s, "reuse@example.com", "", true)
+
+	session, err := CreateSession(s, user.ID, "email", "127.0.0.1", "test", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	first, _, err := IssueRefreshToken(s, user.ID, session.FamilyID, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to issue refresh token: %v", err)
+	}
+	second, _, err := RotateRefreshToken(s, first, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to rotate refresh token: %v", err)
+	}
+
+	// Replaying the rotated-out token revokes the whole family
+	if _, _, err := RotateRefreshToken(s, first, time.Hour); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused; got %v", err)
+	}
+	if _, _, err := RotateRefreshToken(s, second, time.Hour); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("expected the current token to be revoked with its family; got %v", err)
+	}
+	if err := CheckSession(s, session.UUID, user.ID, "127.0.0.1"); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("expected the family's session to be revoked; got %v", err)
+	}
+
+	// Other logins of the same user are untouched
+	other, _, err := IssueRefreshToken(s, user.ID, "", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to issue refresh token: %v", err)
+	}
+	if _, _, err := RotateRefreshToken(s, other, time.Hour); err != nil {
+		t.Fatalf("expected another family to keep rotating; got %v", err)
+	}
+}
+
+func TestRotateRefreshTokenExpired(t *testing.T) {
+	s := testDB(t)
+	user := createUser(t, s, "expired@example.com", "", true)
+
+	raw, _, err := IssueRefreshToken(s, user.ID, "", -time.Minute)
+	if err != nil {
+		t.Fatalf("failed to issue refresh token: %v", err)
+	}
+	if _, _, err := RotateRefreshToken(s, raw, time.Hour); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("expected ErrRefreshTokenInvalid for an expired token; got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+// models/role.go
+package models
+
+import (
+	"errors"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/database"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrUnknownRole  = errors.New("unknown role")
+	ErrRoleNotFound = errors.New("user does not have this role")
+	ErrLastAdmin    = errors.New("cannot revoke the last admin")
+)
+
+// UserRole grants one of the roles defined in auth to a user
+type UserRole struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	UserID    uint      `gorm:"uniqueIndex:idx_user_role;not null" json:"-"`
+	Role      string    `gorm:"uniqueIndex:idx_user_role;size:50;not null" json:"role"`
+	GrantedBy *uint     `json:"-"`
+	CreatedAt time.Time `json:"granted_at"`
+}
+
+// RoleNames returns the names of the roles loaded into u.Roles
+func (u *User) RoleNames() []string {
+	names := make([]string, 0, len(u.Roles))
+	for _, role := range u.Roles {
+		names = append(names, role.Role)
+	}
+	return names
+}
+
+// UserRoles returns the names of the roles granted to a user
+func UserRoles(s database.Service, userID uint) ([]string, error) {
+	var roles []string
+	err := s.GormDB().Model(&UserRole{}).Where("user_id = ?", userID).Order("role").Pluck("role", &roles).Error
+	return roles, err
+}
+
+// GrantRole gives user role; granting a role twice is a no-op.
+// grantedBy is nil for roles granted from configuration.
+func GrantRole(s database.Service, userID uint, role string, grantedBy *uint) error {
+	if !auth.ValidRole(role) {
+		return ErrUnknownRole
+	}
+	return s.GormDB().Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&UserRole{UserID: userID, Role: role, GrantedBy: grantedBy}).Error
+}
+
+// RevokeRole takes role away from user. The last admin can't be removed,
+// so the admin API always stays reachable.
+func RevokeRole(s database.Service, userID uint, role string) error {
+	return s.GormDB().Transaction(func(tx *gorm.DB) error {
+		if role == auth.RoleAdmin {
+			// Lock the admin grants so two admins can't demote each other at once
+			var admins []UserRole
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("role = ?", auth.RoleAdmin).Find(&admins).Error; err != nil {
+				return err
+			}
+			if len(admins) == 1 && admins[0].UserID == userID {
+				return ErrLastAdmin
+			}
+		}
+
+		result := tx.Where("user_id = ? AND role = ?", userID, role).Delete(&UserRole{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRoleNotFound
+		}
+		return nil
+	})
+}
+
+// BootstrapAdmin grants admin to user if its verified email is listed in
+// emails (ADMIN_EMAILS), so the first admin can sign in and grant the rest.
+// Removing an email from the list does not revoke the role.
+func BootstrapAdmin(s database.Service, user *User, emails []string) error {
+	if !user.IsVerified {
+		return nil
+	}
+	for _, email := range emails {
+		if strings.EqualFold(email, user.Email) {
+			return GrantRole(s, user.ID, auth.RoleAdmin, nil)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"errors"
+	"list-of-maldives/internal/auth"
+	"testing"
+)
+
+func TestRevokeRoleKeepsLastAdmin(t *testing.T) {
+	s := testDB(t)
+	first := createUser(t, s, "first@example.com", "password123", true)
+	second := createUser(t, s, "second@example.com", "password123", true)
+
+	if err := GrantRole(s, first.ID, auth.RoleAdmin, nil); err != nil {
+		t.Fatalf("failed to grant admin: %v", err)
+	}
+	if err := RevokeRole(s, first.ID, auth.RoleAdmin); !errors.Is(err, ErrLastAdmin) {
+		t.Fatalf("expected ErrLastAdmin; got %v", err)
+	}
+
+	// With a second admin the first can step down, and then the second can't
+	if err := GrantRole(s, second.ID, auth.RoleAdmin, &first.ID); err != nil {
+		t.Fatalf("failed to grant admin: %v", err)
+	}
+	if err := RevokeRole(s, first.ID, auth.RoleAdmin); err != nil {
+		t.Fatalf("expected the first admin to be revoked; got %v", err)
+	}
+	if err := RevokeRole(s, second.ID, auth.RoleAdmin); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("expected ErrLastAdmin; got %v", err)
+	}
+	if err := RevokeRole(s, first.ID, auth.RoleAdmin); !errors.Is(err, ErrRoleNotFound) {
+		t.Errorf("expected ErrRoleNotFound; got %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+// models/session.go
+package models
+
+import (
+	"errors"
+	"list-of-maldives/internal/database"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionRevoked  = errors.New("session has been signed out")
+)
+
+// sessionTouchInterval limits how often a session's last-seen details are written
+const sessionTouchInterval = time.Minute
+
+// Session is one signed-in device. It owns a refresh-token family, and its
+// UUID is the sid claim of the access tokens minted for it.
+type Session struct {
+	ID         uint       `gorm:"primaryKey" json:"-"`
+	UUID       string     `gorm:"size:36;uniqueIndex;not null" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"-"`
+	FamilyID   string     `gorm:"size:36;uniqueIndex;not null" json:"-"`
+	Provider   string     `gorm:"size:50" json:"provider"`
+	IP         string     `gorm:"size:45" json:"ip"`
+	UserAgent  string     `gorm:"size:512" json:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `gorm:"index" json:"expires_at"`
+	RevokedAt  *time.Time `json:"-"`
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// CreateSession records a new sign-in with its own refresh-token family
+func CreateSession(s database.Service, userID uint, provider, ip, userAgent string, expiresAt time.Time) (*Session, error) {
+	now := time.Now()
+	session := Session{
+		UUID:       uuid.New().String(),
+		UserID:     userID,
+		FamilyID:   uuid.New().String(),
+		Provider:   provider,
+		IP:         ip,
+		UserAgent:  truncate(userAgent, 512),
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt,
+	}
+	if err := s.GormDB().Create(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// RefreshSession looks up the live session owning a refresh-token family
+// and records that it was used again from ip. Families issued before
+// sessions were recorded get one now.
+func RefreshSession(s database.Service, userID uint, familyID, ip, userAgent string, expiresAt time.Time) (*Session, error) {
+	var session Session
+	err := s.GormDB().Where("family_id = ?", familyID).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		session = Session{
+			UUID:       uuid.New().String(),
+			UserID:     userID,
+			FamilyID:   familyID,
+			IP:         ip,
+			UserAgent:  truncate(userAgent, 512),
+			LastSeenAt: time.Now(),
+			ExpiresAt:  expiresAt,
+		}
+		return &session, s.GormDB().Create(&session).Error
+	} else if err != nil {
+		return nil, err
+	}
+	if session.RevokedAt != nil {
+		return nil, ErrSessionRevoked
+	}
+
+	err = s.GormDB().Model(&session).Updates(map[string]interface{}{
+		"last_seen_at": time.Now(),
+		"ip":           ip,
+		"user_agent":   truncate(userAgent, 512),
+		"expires_at":   expiresAt,
+	}).Error
+	return &session, err
+}
+
+// CheckSession fails with ErrSessionRevoked once the session was signed out.
+// Last-seen details are updated at most once a minute.
+func CheckSession(s database.Service, sessionUUID string, userID uint, ip string) error {
+	var session Session
+	err := s.GormDB().Where("uuid = ? AND user_id = ?", sessionUUID, userID).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrSessionRevoked
+	} else if err != nil {
+		return err
+	}
+	if session.RevokedAt != nil {
+		return ErrSessionRevoked
+	}
+
+	if time.Since(session.LastSeenAt) > sessionTouchInterval {
+		return s.GormDB().Model(&session).Updates(map[string]interface{}{
+			"last_seen_at": time.Now(),
+			"ip":           ip,
+		}).Error
+	}
+	return nil
+}
+
+// ListSessions returns a user's signed-in devices, most recently used first
+func ListSessions(s database.Service, userID uint) ([]Session, error) {
+	var sessions []Session
+	err := s.GormDB().
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// RevokeSession signs one of the user's devices out
+func RevokeSession(s database.Service, userID uint, sessionUUID string) error {
+	session, err := FindSession(s, userID, sessionUUID)
+	if err != nil {
+		return err
+	}
+	return RevokeRefreshFamily(s, session.FamilyID)
+}
+
+// FindSession returns one of the user's live sessions
+func FindSession(s database.Service, userID uint, sessionUUID string) (*Session, error) {
+	var session Session
+	err := s.GormDB().Where("uuid = ? AND user_id = ? AND revoked_at IS NULL", sessionUUID, userID).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSessionNotFound
+	}
+	return &session, err
+}
+
+// revokeOtherSessions signs the user out on every device but keep within tx,
+// e.g. after a password change made from keep
+func revokeOtherSessions(tx *gorm.DB, userID uint, keep *Session) error {
+	now := time.Now()
+	if err := tx.Model(&RefreshToken{}).
+		Where("user_id = ? AND family_id <> ? AND revoked_at IS NULL", userID, keep.FamilyID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	return tx.Model(&Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keep.ID).
+		Update("revoked_at", now).Error
+}
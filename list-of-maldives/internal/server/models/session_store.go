@@ -0,0 +1,74 @@
+// models/session_store.go
+package models
+
+import (
+	"context"
+	"errors"
+	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/database"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// HTTPSession is a server-side session (OAuth state, link and passkey
+// ceremonies), stored sealed with the session keys
+type HTTPSession struct {
+	ID        string    `gorm:"primaryKey;size:64"`
+	Data      string    `gorm:"type:text;not null"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+}
+
+// SessionBackend is the Postgres-backed auth.SessionBackend
+type SessionBackend struct {
+	db database.Service
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewSessionBackend(s database.Service) *SessionBackend {
+	return &SessionBackend{db: s}
+}
+
+func (b *SessionBackend) Load(ctx context.Context, id string) (string, error) {
+	var session HTTPSession
+	err := b.db.GormDB().WithContext(ctx).
+		Where("id = ? AND expires_at > ?", id, time.Now()).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", auth.ErrSessionNotFound
+	}
+	return session.Data, err
+}
+
+func (b *SessionBackend) Save(ctx context.Context, id, data string, expiresAt time.Time) error {
+	err := b.db.GormDB().WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"data", "expires_at"}),
+	}).Create(&HTTPSession{ID: id, Data: data, ExpiresAt: expiresAt}).Error
+	if err != nil {
+		return err
+	}
+
+	b.sweep(ctx)
+	return nil
+}
+
+func (b *SessionBackend) Delete(ctx context.Context, id string) error {
+	return b.db.GormDB().WithContext(ctx).Where("id = ?", id).Delete(&HTTPSession{}).Error
+}
+
+// sweep deletes expired sessions at most once a minute per instance
+func (b *SessionBackend) sweep(ctx context.Context) {
+	b.mu.Lock()
+	if time.Since(b.lastSweep) < time.Minute {
+		b.mu.Unlock()
+		return
+	}
+	b.lastSweep = time.Now()
+	b.mu.Unlock()
+
+	b.db.GormDB().WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&HTTPSession{})
+}
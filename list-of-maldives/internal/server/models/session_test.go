@@ -0,0 +1,108 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckSession(t *testing.T) {
+	s := testDB(t)
+	user := createUser(t, s, "user@example.com", "password123", true)
+	other := createUser(t, s, "other@example.com", "password123", true)
+	session, err := CreateSession(s, user.ID, "password", "127.0.0.1", "test", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	if err := CheckSession(s, session.UUID, user.ID, "127.0.0.1"); err != nil {
+		t.Fatalf("expected a live session to pass; got %v", err)
+	}
+	if err := CheckSession(s, session.UUID, other.ID, "127.0.0.1"); err != ErrSessionRevoked {
+		t.Errorf("expected another user's session to count as revoked; got %v", err)
+	}
+	if err := CheckSession(s, "unknown", user.ID, "127.0.0.1"); err != ErrSessionRevoked {
+		t.Errorf("expected an unknown session to count as revoked; got %v", err)
+	}
+
+	if err := RevokeSession(s, user.ID, session.UUID); err != nil {
+		t.Fatalf("failed to revoke session: %v", err)
+	}
+	if err := CheckSession(s, session.UUID, user.ID, "127.0.0.1"); err != ErrSessionRevoked {
+		t.Errorf("expected a signed-out session to be revoked; got %v", err)
+	}
+}
+
+func TestCheckSessionTouchesAtMostOnceAMinute(t *testing.T) {
+	s := testDB(t)
+	user := createUser(t, s, "user@example.com", "password123", true)
+	session, err := CreateSession(s, user.ID, "password", "127.0.0.1", "test", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	lastSeen := func() Session {
+		var stored Session
+		if err := s.GormDB().First(&stored, session.ID).Error; err != nil {
+			t.Fatalf("failed to reload session: %v", err)
+		}
+		return stored
+	}
+
+	// A fresh session isn't written to on every request
+	if err := CheckSession(s, session.UUID, user.ID, "10.0.0.1"); err != nil {
+		t.Fatalf("CheckSession failed: %v", err)
+	}
+	if stored := lastSeen(); stored.IP != "127.0.0.1" {
+		t.Errorf("expected the session to be left alone within the touch interval; got IP %s", stored.IP)
+	}
+
+	stale := time.Now().Add(-2 * sessionTouchInterval)
+	if err := s.GormDB().Model(&Session{}).Where("id = ?", session.ID).Update("last_seen_at", stale).Error; err != nil {
+		t.Fatalf("failed to age session: %v", err)
+	}
+	if err := CheckSession(s, session.UUID, user.ID, "10.0.0.1"); err != nil {
+		t.Fatalf("CheckSession failed: %v", err)
+	}
+	if stored := lastSeen(); stored.IP != "10.0.0.1" || !stored.LastSeenAt.After(stale) {
+		t.Errorf("expected a stale session to be touched; got IP %s, last seen %s", stored.IP, stored.LastSeenAt)
+	}
+}
+
+func TestListAndRevokeSessions(t *testing.T) {
+	s := testDB(t)
+	user := createUser(t, s, "user@example.com", "password123", true)
+	other := createUser(t, s, "other@example.com", "password123", true)
+	now := time.Now()
+	live, err := CreateSession(s, user.ID, "password", "127.0.0.1", "test", now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	revoked, err := CreateSession(s, user.ID, "password", "127.0.0.2", "test", now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if _, err := CreateSession(s, user.ID, "password", "127.0.0.3", "test", now.Add(-time.Minute)); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if _, err := CreateSession(s, other.ID, "password", "127.0.0.4", "test", now.Add(time.Hour)); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	// Nobody can sign out someone else's device
+	if err := RevokeSession(s, other.ID, revoked.UUID); err != ErrSessionNotFound {
+		t.Fatalf("expected another user's session to be not found; got %v", err)
+	}
+	if err := RevokeSession(s, user.ID, revoked.UUID); err != nil {
+		t.Fatalf("RevokeSession failed: %v", err)
+	}
+	if err := RevokeSession(s, user.ID, revoked.UUID); err != ErrSessionNotFound {
+		t.Errorf("expected a signed-out session to be not found; got %v", err)
+	}
+
+	sessions, err := ListSessions(s, user.ID)
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].UUID != live.UUID {
+		t.Errorf("expected only the live, unexpired session; got %+v", sessions)
+	}
+}
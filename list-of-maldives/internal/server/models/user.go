@@ -22,6 +22,30 @@ type User struct {
 	CreatedAt  time.Time      `json:"created_at"`
 	UpdatedAt  time.Time      `json:"updated_at"`
 	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// VerificationSentAt throttles verification email resends
+	VerificationSentAt *time.Time `json:"-"`
+	// PendingEmail is the address the user asked to switch to; Email only
+	// changes once a link sent there is opened
+	PendingEmail string `gorm:"size:255" json:"pending_email,omitempty"`
+
+	// TOTPSecret is encrypted; MFAEnabled is only set once a code confirmed it
+	TOTPSecret   string `json:"-"`
+	TOTPLastStep int64  `gorm:"default:0" json:"-"`
+	MFAEnabled   bool   `gorm:"default:false" json:"mfa_enabled"`
+
+	// Status is the account lifecycle state (see account_status.go); only
+	// SetAccountStatus and MarkVerified change it
+	Status         string     `gorm:"size:30;index" json:"status"`
+	StatusReason   string     `gorm:"size:500" json:"-"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+	// PurgeAt is when an account its owner deleted is erased for good
+	PurgeAt *time.Time `gorm:"index" json:"-"`
+	// MustResetPassword refuses password logins until the emailed reset is used
+	MustResetPassword bool `gorm:"default:false" json:"must_reset_password"`
+
+	// Roles is only loaded where needed, e.g. Preload("Roles")
+	Roles []UserRole `gorm:"foreignKey:UserID" json:"roles,omitempty"`
 }
 
 // HashPassword hashes the user's password
@@ -42,6 +66,9 @@ func (u *User) CheckPassword(password string) bool {
 
 func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
 	u.UUID = uuid.New().String()
+	if u.Status == "" {
+		u.Status = statusAfterVerification(u.IsVerified)
+	}
 
 	// Hash password if it's provided and not already hashed
 	if u.Password != "" && len(u.Password) < 60 {
@@ -52,49 +79,126 @@ func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
 	return nil
 }
 
-// FindOrCreateByProvider finds or creates a user by OAuth provider
-func FindOrCreateByProvider(s database.Service, provider, providerID, email, name string) (*User, error) {
+// FindOrCreateByProvider resolves an OAuth login to a user. Logins are matched
+// through the identities table; an unknown login is linked to an existing
+// account with the same email only when the provider asserted the email is
+// verified, otherwise a new user is created. An unverified account claimed
+// that way loses what its creator set up; see claimUnverifiedAccount.
+func FindOrCreateByProvider(s database.Service, provider, providerID, email, name string, emailVerified bool) (*User, error) {
 	db := s.GormDB()
 
 	var user User
-	// 1. Try to find the user by provider and providerID
-	err := db.Where("provider = ? AND provider_id = ?", provider, providerID).First(&user).Error
-
-	if err != nil && err != gorm.ErrRecordNotFound {
-		return nil, err
-	}
-
-	if err == gorm.ErrRecordNotFound {
-		// 2. User not found, create a new one
-		user = User{
-			Email:      email,
-			NickName:   name,
-			Provider:   provider,
-			ProviderID: providerID,
-			IsVerified: true,
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 1. Known login: find the user through its identity
+		var identity Identity
+		err := tx.Where("provider = ? AND subject = ?", provider, providerID).First(&identity).Error
+		if err == nil {
+			if err := tx.Unscoped().First(&user, identity.UserID).Error; err != nil {
+				return err
+			}
+			if user.DeletedAt.Valid {
+				return ErrAccountDeleted
+			}
+			if err := tx.Model(&identity).Update("email", email).Error; err != nil {
+				return err
+			}
+			if name != "" && name != user.NickName {
+				user.NickName = name
+				return tx.Model(&user).Update("nick_name", name).Error
+			}
+			return nil
+		} else if err != gorm.ErrRecordNotFound {
+			return err
 		}
-		if err = db.Create(&user).Error; err != nil {
-			return nil, err
+
+		// 2. Accounts created before identities existed keep provider/provider_id on the user
+		err = tx.Unscoped().Where("provider = ? AND provider_id = ?", provider, providerID).First(&user).Error
+		if err == nil && user.DeletedAt.Valid {
+			return ErrAccountDeleted
+		} else if err != nil && err != gorm.ErrRecordNotFound {
+			return err
 		}
-	} else {
-		// 3. User found, update NickName and Email
-		updates := map[string]interface{}{
-			"email":     email,
-			"nick_name": name,
+
+		// 3. An account with the same email is only linked if the email is verified
+		matchedByEmail := false
+		if err == gorm.ErrRecordNotFound && email != "" {
+			// Deleted accounts keep their email until they are purged
+			err = tx.Unscoped().Where("email = ?", email).First(&user).Error
+			if err == nil && user.DeletedAt.Valid {
+				return ErrAccountDeleted
+			} else if err == nil && !emailVerified {
+				return ErrEmailInUse
+			} else if err != nil && err != gorm.ErrRecordNotFound {
+				return err
+			}
+			matchedByEmail = err == nil
 		}
 
-		// Update the database record
-		if err = db.Model(&user).Updates(updates).Error; err != nil {
-			return nil, err
+		// 4. User not found, create a new one
+		if err == gorm.ErrRecordNotFound {
+			user = User{
+				Email:      email,
+				NickName:   name,
+				Provider:   provider,
+				ProviderID: providerID,
+				IsVerified: emailVerified,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		} else if emailVerified && !user.IsVerified && user.Email == email {
+			if matchedByEmail {
+				if err := claimUnverifiedAccount(tx, &user); err != nil {
+					return err
+				}
+			}
+			if err := MarkVerified(tx, &user); err != nil {
+				return err
+			}
 		}
 
-		// Update the local struct to reflect the new data before returning
-		user.Email = email
-		user.NickName = name
+		return tx.Create(&Identity{UserID: user.ID, Provider: provider, Subject: providerID, Email: email}).Error
+	})
+	if err != nil {
+		return nil, err
 	}
 	return &user, nil
 }
 
+// claimUnverifiedAccount hands an unverified account over to whoever just
+// proved they own its email, through a verified provider or a magic link.
+// Anyone could have registered the address, so the password, logins,
+// passkeys, MFA and sessions they set up are removed first; otherwise they
+// would keep access to the account its real owner now uses.
+func claimUnverifiedAccount(tx *gorm.DB, user *User) error {
+	for _, model := range []interface{}{&Identity{}, &Passkey{}, &RecoveryCode{}, &PasswordResetToken{}} {
+		if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	if err := signOutEverywhere(tx, user); err != nil {
+		return err
+	}
+
+	if err := tx.Model(user).Updates(map[string]interface{}{
+		"password":            "",
+		"totp_secret":         "",
+		"totp_last_step":      0,
+		"mfa_enabled":         false,
+		"pending_email":       "",
+		"must_reset_password": false,
+	}).Error; err != nil {
+		return err
+	}
+	user.Password = ""
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+	user.MFAEnabled = false
+	user.PendingEmail = ""
+	user.MustResetPassword = false
+	return nil
+}
+
 func MigrateUser(db *gorm.DB) error {
 	return db.AutoMigrate(&User{})
 }
@@ -0,0 +1,140 @@
+// models/user_admin.go
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"list-of-maldives/internal/database"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// UserFilter narrows ListUsers. Zero values don't filter.
+type UserFilter struct {
+	Provider string
+	Verified *bool
+	// Status is an account status; StatusDeleted lists soft-deleted users
+	Status        string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// Search matches email or nickname, case-insensitively
+	Search string
+
+	Limit  int
+	Cursor string
+}
+
+// encodeCursor makes the opaque cursor of a page that continues after id,
+// for lists ordered by id descending
+func encodeCursor(id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(id), 10)))
+}
+
+func decodeCursor(cursor string) (uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseUint(string(raw), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	return uint(id), nil
+}
+
+// escapeLike escapes LIKE wildcards in user input
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// ListUsers returns one page of users, newest first, and the cursor of the
+// next page ("" on the last page)
+func ListUsers(s database.Service, f UserFilter) ([]User, string, error) {
+	if f.Limit <= 0 || f.Limit > 100 {
+		f.Limit = 50
+	}
+
+	query := s.GormDB().Model(&User{})
+	now := time.Now()
+	switch f.Status {
+	case "":
+	case StatusDeleted:
+		query = query.Unscoped().Where("deleted_at IS NOT NULL")
+	case StatusSuspended:
+		query = query.Where("status = ? AND (suspended_until IS NULL OR suspended_until > ?)", StatusSuspended, now)
+	case StatusActive, StatusPendingVerification:
+		// Accounts whose suspension ran out are back in good standing, and
+		// ones from before statuses existed have none; see EffectiveStatus
+		query = query.Where("(status = ? OR ((status IS NULL OR status = '' OR (status = ? AND suspended_until <= ?)) AND is_verified = ?))",
+			f.Status, StatusSuspended, now, f.Status == StatusActive)
+	default:
+		return nil, "", ErrInvalidStatus
+	}
+	if f.Provider != "" {
+		// Match the signup provider or any linked login
+		query = query.Where("(provider = ? OR id IN (?))", f.Provider,
+			s.GormDB().Model(&Identity{}).Select("user_id").Where("provider = ?", f.Provider))
+	}
+	if f.Verified != nil {
+		query = query.Where("is_verified = ?", *f.Verified)
+	}
+	if !f.CreatedAfter.IsZero() {
+		query = query.Where("created_at >= ?", f.CreatedAfter)
+	}
+	if !f.CreatedBefore.IsZero() {
+		query = query.Where("created_at < ?", f.CreatedBefore)
+	}
+	if f.Search != "" {
+		pattern := "%" + escapeLike(f.Search) + "%"
+		query = query.Where("(email ILIKE ? OR nick_name ILIKE ?)", pattern, pattern)
+	}
+	if f.Cursor != "" {
+		after, err := decodeCursor(f.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query = query.Where("id < ?", after)
+	}
+
+	var users []User
+	if err := query.Order("id DESC").Limit(f.Limit + 1).Find(&users).Error; err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(users) > f.Limit {
+		users = users[:f.Limit]
+		next = encodeCursor(users[len(users)-1].ID)
+	}
+	return users, next, nil
+}
+
+// FindUserByUUID loads a user with its roles. withDeleted also finds
+// soft-deleted users.
+func FindUserByUUID(s database.Service, uuid string, withDeleted bool) (*User, error) {
+	query := s.GormDB().Preload("Roles")
+	if withDeleted {
+		query = query.Unscoped()
+	}
+
+	var user User
+	err := query.Where("uuid = ?", uuid).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	return &user, err
+}
+
+// RequirePasswordReset refuses password logins for user until it resets its password
+func RequirePasswordReset(s database.Service, user *User) error {
+	user.MustResetPassword = true
+	return s.GormDB().Model(user).Update("must_reset_password", true).Error
+}
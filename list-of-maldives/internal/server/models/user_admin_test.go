@@ -0,0 +1,95 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestListUsersCursorPaging(t *testing.T) {
+	s := testDB(t)
+	var created []*User
+	for i := 0; i < 5; i++ {
+		created = append(created, createUser(t, s, fmt.Sprintf("user%d@example.com", i), "password123", true))
+	}
+
+	var seen []uint
+	cursor := ""
+	for page := 0; ; page++ {
+		users, next, err := ListUsers(s, UserFilter{Limit: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("ListUsers failed: %v", err)
+		}
+		for _, user := range users {
+			seen = append(seen, user.ID)
+		}
+		if next == "" {
+			break
+		}
+		if page > 5 {
+			t.Fatalf("paging did not end")
+		}
+		cursor = next
+	}
+
+	// Newest first, each user exactly once
+	if len(seen) != len(created) {
+		t.Fatalf("expected %d users; got %d", len(created), len(seen))
+	}
+	for i, id := range seen {
+		if want := created[len(created)-1-i].ID; id != want {
+			t.Errorf("expected user %d at position %d; got %d", want, i, id)
+		}
+	}
+
+	if _, _, err := ListUsers(s, UserFilter{Cursor: "not-a-cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor; got %v", err)
+	}
+}
+
+func TestListUsersFilters(t *testing.T) {
+	s := testDB(t)
+	active := createUser(t, s, "active@example.com", "password123", true)
+	pending := createUser(t, s, "pending@example.com", "password123", false)
+	suspended := createUser(t, s, "suspended@example.com", "password123", true)
+	if err := SetAccountStatus(s, suspended, StatusSuspended, "abuse", nil, nil); err != nil {
+		t.Fatalf("failed to suspend user: %v", err)
+	}
+	lapsed := createUser(t, s, "lapsed@example.com", "password123", true)
+	past := time.Now().Add(-time.Hour)
+	if err := s.GormDB().Model(lapsed).Updates(map[string]interface{}{"status": StatusSuspended, "suspended_until": past}).Error; err != nil {
+		t.Fatalf("failed to backdate suspension: %v", err)
+	}
+
+	verified := false
+	cases := []struct {
+		name   string
+		filter UserFilter
+		want   []*User
+	}{
+		{"suspended", UserFilter{Status: StatusSuspended}, []*User{suspended}},
+		{"active includes lapsed suspensions", UserFilter{Status: StatusActive}, []*User{lapsed, active}},
+		{"unverified", UserFilter{Verified: &verified}, []*User{pending}},
+		{"search", UserFilter{Search: "PEND"}, []*User{pending}},
+	}
+	for _, c := range cases {
+		users, _, err := ListUsers(s, c.filter)
+		if err != nil {
+			t.Fatalf("%s: ListUsers failed: %v", c.name, err)
+		}
+		if len(users) != len(c.want) {
+			t.Errorf("%s: expected %d users; got %d", c.name, len(c.want), len(users))
+			continue
+		}
+		for i, user := range users {
+			if user.ID != c.want[i].ID {
+				t.Errorf("%s: expected %s at position %d; got %s", c.name, c.want[i].Email, i, user.Email)
+			}
+		}
+	}
+
+	if _, _, err := ListUsers(s, UserFilter{Status: "banned"}); !errors.Is(err, ErrInvalidStatus) {
+		t.Errorf("expected ErrInvalidStatus; got %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFindOrCreateByProviderClaimsUnverifiedAccount(t *testing.T) {
+	s := testDB(t)
+	db := s.GormDB()
+
+	// Someone registers the victim's email with a password and never verifies it
+	squatter := createUser(t, s, "victim@example.com", "squatter-password", false)
+	if err := db.Create(&Identity{UserID: squatter.ID, Provider: "github", Subject: "squatter", Email: "squatter@example.com"}).Error; err != nil {
+		t.Fatalf("failed to link identity: %v", err)
+	}
+	if err := db.Create(&Passkey{UserID: squatter.ID, CredentialID: []byte("cred"), PublicKey: []byte("key")}).Error; err != nil {
+		t.Fatalf("failed to create passkey: %v", err)
+	}
+	session, err := CreateSession(s, squatter.ID, "email", "127.0.0.1", "test", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	refresh, _, err := IssueRefreshToken(s, squatter.ID, session.FamilyID, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to issue refresh token: %v", err)
+	}
+
+	// The victim signs in with a provider that verified the address
+	user, err := FindOrCreateByProvider(s, "google", "victim", "victim@example.com", "Victim", true)
+	if err != nil {
+		t.Fatalf("FindOrCreateByProvider failed: %v", err)
+	}
+	if user.ID != squatter.ID {
+		t.Fatalf("expected the existing account to be claimed")
+	}
+
+	var stored User
+	if err := db.First(&stored, user.ID).Error; err != nil {
+		t.Fatalf("failed to reload user: %v", err)
+	}
+	if !stored.IsVerified || stored.Status != StatusActive {
+		t.Errorf("expected the account to be verified and active; got %t, %s", stored.IsVerified, stored.Status)
+	}
+	if stored.Password != "" || stored.CheckPassword("squatter-password") {
+		t.Errorf("expected the squatter's password to be removed")
+	}
+
+	identities, err := ListIdentities(s, user.ID)
+	if err != nil {
+		t.Fatalf("failed to list identities: %v", err)
+	}
+	if len(identities) != 1 || identities[0].Provider != "google" {
+		t.Errorf("expected only the google identity; got %+v", identities)
+	}
+	passkeys, err := ListPasskeys(s, user.ID)
+	if err != nil {
+		t.Fatalf("failed to list passkeys: %v", err)
+	}
+	if len(passkeys) != 0 {
+		t.Errorf("expected the squatter's passkeys to be removed; got %d", len(passkeys))
+	}
+	if err := CheckSession(s, session.UUID, user.ID, "127.0.0.1"); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("expected the squatter's session to be revoked; got %v", err)
+	}
+	if _, _, err := RotateRefreshToken(s, refresh, time.Hour); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("expected the squatter's refresh token to be revoked; got %v", err)
+	}
+	before, err := NewRevocationStore(s).UserTokensRevokedBefore(user.UUID)
+	if err != nil || before.IsZero() {
+		t.Errorf("expected the squatter's access tokens to be revoked; got %v, %v", before, err)
+	}
+}
+
+func TestFindOrCreateByProviderLinking(t *testing.T) {
+	s := testDB(t)
+	owner := createUser(t, s, "owner@example.com", "owner-password", true)
+
+	// A provider that doesn't vouch for the email can't take over an account
+	if _, err := FindOrCreateByProvider(s, "github", "someone", "owner@example.com", "", false); !errors.Is(err, ErrEmailInUse) {
+		t.Fatalf("expected ErrEmailInUse; got %v", err)
+	}
+
+	// A verified account is linked as is
+	user, err := FindOrCreateByProvider(s, "google", "owner", "owner@example.com", "", true)
+	if err != nil {
+		t.Fatalf("FindOrCreateByProvider failed: %v", err)
+	}
+	var stored User
+	s.GormDB().First(&stored, owner.ID)
+	if user.ID != owner.ID || !stored.CheckPassword("owner-password") {
+		t.Errorf("expected the verified account to be linked and keep its password")
+	}
+
+	// The same login finds the account again
+	again, err := FindOrCreateByProvider(s, "google", "owner", "owner@example.com", "Owner", true)
+	if err != nil || again.ID != owner.ID || again.NickName != "Owner" {
+		t.Errorf("expected the known login to resolve to the same user with its new name; got %+v, %v", again, err)
+	}
+
+	// An unknown email creates a new account
+	created, err := FindOrCreateByProvider(s, "google", "new", "new@example.com", "New", false)
+	if err != nil || created.ID == owner.ID || created.IsVerified {
+		t.Errorf("expected a new unverified account; got %+v, %v", created, err)
+	}
+}
+
+func TestFindOrCreateByProviderRefusesDeletedAccount(t *testing.T) {
+	s := testDB(t)
+	owner := createUser(t, s, "owner@example.com", "owner-password", true)
+	if _, err := FindOrCreateByProvider(s, "google", "owner", "owner@example.com", "", true); err != nil {
+		t.Fatalf("FindOrCreateByProvider failed: %v", err)
+	}
+	if err := DeleteOwnAccount(s, owner, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("failed to delete account: %v", err)
+	}
+
+	// Neither the linked login nor another provider vouching for the email
+	// gets in, or creates a second account with the same email
+	if _, err := FindOrCreateByProvider(s, "google", "owner", "owner@example.com", "", true); !errors.Is(err, ErrAccountDeleted) {
+		t.Errorf("expected the linked login to get ErrAccountDeleted; got %v", err)
+	}
+	if _, err := FindOrCreateByProvider(s, "github", "owner", "owner@example.com", "", true); !errors.Is(err, ErrAccountDeleted) {
+		t.Errorf("expected a new login with the email to get ErrAccountDeleted; got %v", err)
+	}
+}
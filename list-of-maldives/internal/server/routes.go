@@ -7,47 +7,155 @@ import (
 	"time"
 
 	"list-of-maldives/internal/auth"
+	"list-of-maldives/internal/config"
+	"list-of-maldives/internal/cors"
+	"list-of-maldives/internal/mailer"
+	"list-of-maldives/internal/ratelimit"
 	"list-of-maldives/internal/server/handlers"
 	"list-of-maldives/internal/server/middleware"
 	"list-of-maldives/internal/server/models"
 
 	"github.com/gorilla/mux"
+	"github.com/markbates/goth/gothic"
 )
 
 // server/server.go (update RegisterRoutes method)
 func (s *Server) RegisterRoutes() http.Handler {
 	r := mux.NewRouter()
 
-	// Apply CORS middleware
-	r.Use(s.corsMiddleware)
+	// Apply CORS middleware (CORS_ALLOWED_ORIGINS); the JWKS is public
+	corsPolicy, err := cors.FromEnv(cors.Route{
+		Prefix: "/.well-known/",
+		Policy: cors.Policy{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET", "OPTIONS"}},
+	})
+	if err != nil {
+		log.Fatalf("failed to configure CORS: %v", err)
+	}
+	r.Use(corsPolicy.Handler)
 	r.Use(s.requestLogger)
 
+	// Client IPs come from X-Forwarded-For only as far as our own proxies
+	// (TRUSTED_PROXY_HOPS) appended to it
+	middleware.SetTrustedProxyHops(middleware.TrustedProxyHopsFromEnv())
+
 	r.HandleFunc("/", s.HelloWorldHandler)
 	r.HandleFunc("/health", s.healthHandler)
 
 	// Initialize JWT service
 	jwtService := auth.NewJWTService()
+	r.HandleFunc("/.well-known/jwks.json", handlers.JWKS(jwtService)).Methods("GET", "OPTIONS")
+
+	// Revoked access tokens live in Postgres, cached in memory per instance
+	revocations := auth.NewCachedRevocationStore(models.NewRevocationStore(s.db), 30*time.Second)
 
 	// Apply auth middleware (sets user in context if authenticated)
-	r.Use(middleware.AuthMiddleware(jwtService, s.db))
+	// Access tokens are read from "Authorization: Bearer" and/or the auth_token cookie
+	r.Use(middleware.AuthMiddleware(jwtService, s.db, revocations, middleware.TokenSourcesFromEnv()))
+
+	// Rate limits per route prefix (RATE_LIMITS); RATE_LIMIT_STORE=postgres shares them across instances
+	rateLimits, err := middleware.RateLimitRulesFromEnv()
+	if err != nil {
+		log.Fatalf("failed to configure rate limits: %v", err)
+	}
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if config.String("RATE_LIMIT_STORE", "memory") == "postgres" {
+		rateLimitStore = models.NewRateLimitStore(s.db)
+	}
+	r.Use(middleware.RateLimit(rateLimitStore, rateLimits))
+
+	// Cookie-authenticated POST/PUT/PATCH/DELETE need an X-CSRF-Token from GET /auth/csrf
+	csrf, err := middleware.CSRFFromEnv()
+	if err != nil {
+		log.Fatalf("failed to configure CSRF protection: %v", err)
+	}
+	r.Use(csrf.Protect)
+
+	// OAuth state and the link, passkey and return-to flows share one sealed
+	// session store (SESSION_KEYS); SESSION_STORE=postgres shares it across instances
+	sessionStore, err := auth.SessionStoreFromEnv(models.NewSessionBackend(s.db))
+	if err != nil {
+		log.Fatalf("failed to configure session store: %v", err)
+	}
+	gothic.Store = sessionStore
+
+	// Verification and other account emails (MAIL_DRIVER=smtp|file|log)
+	mail, err := mailer.FromEnv()
+	if err != nil {
+		log.Fatalf("failed to configure mailer: %v", err)
+	}
 
 	// Auth routes (UNPROTECTED: register, login, oauth)
-	authHandler := handlers.NewAuthHandler(s.db, jwtService)
+	authHandler := handlers.NewAuthHandler(s.db, jwtService, revocations, mail)
 
 	// User Info/Protected Auth Routes (PROTECTED: /auth/me)
 	userAuth := r.PathPrefix("/auth/me").Subrouter()
 	userAuth.Use(middleware.RequireAuth)
 	userAuth.HandleFunc("", authHandler.GetUser).Methods("GET")
+	userAuth.HandleFunc("", authHandler.UpdateProfile).Methods("PATCH", "OPTIONS")
+	userAuth.HandleFunc("", authHandler.DeleteAccount).Methods("DELETE", "OPTIONS")
+	userAuth.HandleFunc("/export", authHandler.ExportAccount).Methods("GET", "OPTIONS")
+	userAuth.HandleFunc("/password", authHandler.ChangePassword).Methods("POST", "OPTIONS")
+	userAuth.HandleFunc("/identities", authHandler.ListIdentities).Methods("GET", "OPTIONS")
+	userAuth.HandleFunc("/identities/{provider}", authHandler.LinkIdentity).Methods("POST", "OPTIONS")
+	userAuth.HandleFunc("/identities/{provider}", authHandler.UnlinkIdentity).Methods("DELETE", "OPTIONS")
+	userAuth.HandleFunc("/passkeys", authHandler.ListPasskeys).Methods("GET", "OPTIONS")
+	userAuth.HandleFunc("/passkeys/{id}", authHandler.DeletePasskey).Methods("DELETE", "OPTIONS")
+	userAuth.HandleFunc("/sessions", authHandler.ListSessions).Methods("GET", "OPTIONS")
+	userAuth.HandleFunc("/sessions/{id}", authHandler.RevokeSession).Methods("DELETE", "OPTIONS")
+	userAuth.HandleFunc("/activity", authHandler.ListActivity).Methods("GET", "OPTIONS")
+
+	// Support and admin routes. Roles are re-read from the DB so a revoked
+	// role stops working at once; ADMIN_EMAILS bootstraps the first admins.
+	admin := r.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.RequireAuth, middleware.RecheckRoles(s.db))
+	requirePermission := func(permission string, handler http.HandlerFunc) http.Handler {
+		return middleware.RequirePermission(permission)(handler)
+	}
+	admin.Handle("/login-throttles", requirePermission(auth.PermLoginThrottlesRead, authHandler.ListLoginThrottles)).Methods("GET", "OPTIONS")
+	admin.Handle("/login-throttles", requirePermission(auth.PermLoginThrottlesEdit, authHandler.ClearLoginThrottle)).Methods("DELETE", "OPTIONS")
+	admin.Handle("/audit-events", requirePermission(auth.PermAuditRead, authHandler.ListAuditEvents)).Methods("GET", "OPTIONS")
+	admin.Handle("/users", requirePermission(auth.PermUsersRead, authHandler.ListUsers)).Methods("GET", "OPTIONS")
+	admin.Handle("/users/{uuid}", requirePermission(auth.PermUsersRead, authHandler.GetUserDetails)).Methods("GET", "OPTIONS")
+	admin.Handle("/users/{uuid}", requirePermission(auth.PermUsersManage, authHandler.DeleteUser)).Methods("DELETE", "OPTIONS")
+	admin.Handle("/users/{uuid}/restore", requirePermission(auth.PermUsersManage, authHandler.RestoreUser)).Methods("POST", "OPTIONS")
+	admin.Handle("/users/{uuid}/suspend", requirePermission(auth.PermUsersManage, authHandler.SuspendUser)).Methods("POST", "OPTIONS")
+	admin.Handle("/users/{uuid}/reactivate", requirePermission(auth.PermUsersManage, authHandler.ReactivateUser)).Methods("POST", "OPTIONS")
+	admin.Handle("/users/{uuid}/logout", requirePermission(auth.PermUsersManage, authHandler.ForceLogout)).Methods("POST", "OPTIONS")
+	admin.Handle("/users/{uuid}/password-reset", requirePermission(auth.PermUsersManage, authHandler.ForcePasswordReset)).Methods("POST", "OPTIONS")
+	admin.Handle("/roles", requirePermission(auth.PermRolesManage, authHandler.ListRoles)).Methods("GET", "OPTIONS")
+	admin.Handle("/users/{uuid}/roles", requirePermission(auth.PermRolesManage, authHandler.GetUserRoles)).Methods("GET", "OPTIONS")
+	admin.Handle("/users/{uuid}/roles", requirePermission(auth.PermRolesManage, authHandler.GrantRole)).Methods("POST", "OPTIONS")
+	admin.Handle("/users/{uuid}/roles/{role}", requirePermission(auth.PermRolesManage, authHandler.RevokeRole)).Methods("DELETE", "OPTIONS")
 
 	auth := r.PathPrefix("/auth").Subrouter()
 	// Register all routes EXCEPT /me here
+	auth.HandleFunc("/providers", authHandler.GetProviders).Methods("GET", "OPTIONS")
+	auth.HandleFunc("/csrf", handlers.CSRFToken(csrf)).Methods("GET", "OPTIONS")
+	auth.HandleFunc("/verify-email", authHandler.VerifyEmail).Methods("POST", "OPTIONS")
+	auth.HandleFunc("/email/confirm", authHandler.ConfirmEmailChange).Methods("POST", "OPTIONS")
+	auth.HandleFunc("/password/forgot", authHandler.ForgotPassword).Methods("POST", "OPTIONS")
+	auth.HandleFunc("/password/reset", authHandler.ResetPassword).Methods("POST", "OPTIONS")
+	auth.HandleFunc("/unlock", authHandler.UnlockAccount).Methods("POST", "OPTIONS")
+	auth.HandleFunc("/mfa/verify", authHandler.VerifyMFA).Methods("POST", "OPTIONS")
+	auth.Handle("/mfa/totp/setup", middleware.RequireAuth(http.HandlerFunc(authHandler.SetupTOTP))).Methods("POST", "OPTIONS")
+	auth.Handle("/mfa/totp/confirm", middleware.RequireAuth(http.HandlerFunc(authHandler.ConfirmTOTP))).Methods("POST", "OPTIONS")
+	auth.Handle("/webauthn/register/begin", middleware.RequireAuth(http.HandlerFunc(authHandler.BeginPasskeyRegistration))).Methods("POST", "OPTIONS")
+	auth.Handle("/webauthn/register/finish", middleware.RequireAuth(http.HandlerFunc(authHandler.FinishPasskeyRegistration))).Methods("POST", "OPTIONS")
+	auth.HandleFunc("/webauthn/login/begin", authHandler.BeginPasskeyLogin).Methods("POST", "OPTIONS")
+	auth.HandleFunc("/webauthn/login/finish", authHandler.FinishPasskeyLogin).Methods("POST", "OPTIONS")
+	auth.Handle("/verify-email/resend", middleware.RequireAuth(http.HandlerFunc(authHandler.ResendVerification))).Methods("POST", "OPTIONS")
+	auth.HandleFunc("/magic-link", authHandler.RequestMagicLink).Methods("POST", "OPTIONS")
+	auth.HandleFunc("/magic-link/callback", authHandler.MagicLinkCallback).Methods("GET", "OPTIONS")
 	auth.HandleFunc("/{provider}/callback", authHandler.GetAuthCallback).Methods("GET", "OPTIONS")
 	auth.HandleFunc("/{provider}", authHandler.GetAuth).Methods("GET", "OPTIONS")
 	auth.HandleFunc("/register", authHandler.Register).Methods("POST", "OPTIONS")
 	auth.HandleFunc("/login", authHandler.Login).Methods("POST", "OPTIONS")
+	auth.HandleFunc("/refresh", authHandler.Refresh).Methods("POST", "OPTIONS")
 	auth.HandleFunc("/log-out", authHandler.Logout).Methods("POST", "OPTIONS")
 
-	// Protected API routes example (already correctly protected)
+	// Protected API routes example (already correctly protected).
+	// Use middleware.RequireVerified instead to also require a verified email,
+	// or middleware.RequireRole / RequirePermission to limit it to some users.
 	protectedAPI := r.PathPrefix("/api").Subrouter()
 	protectedAPI.Use(middleware.RequireAuth)
 	protectedAPI.HandleFunc("/protected", s.protectedHandler).Methods("GET", "OPTIONS")
@@ -69,28 +177,6 @@ func (s *Server) protectedHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// CORS middleware
-func (s *Server) corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// CORS Headers
-		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:5173")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-
-		// You also need to add the Max-Age header for preflight caching
-		w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
-
-		// Handle preflight OPTIONS requests
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
-}
-
 // loggingResponseWriter captures status code and bytes written
 type loggingResponseWriter struct {
 	http.ResponseWriter
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 
 	_ "github.com/joho/godotenv/autoload"
 
+	"list-of-maldives/internal/config"
 	"list-of-maldives/internal/database"
 	"list-of-maldives/internal/server/models"
 )
@@ -29,13 +31,17 @@ func NewServer() *http.Server {
 	}
 
 	// Migrate models
-	User := models.User{}
-	if err := NewServer.db.GormDB().AutoMigrate(&User); err != nil {
-		log.Fatalf("failed to migrate User: %v", err)
-	} else {
-		log.Println("User migrated successfully")
+	for _, m := range models.Migrations {
+		if err := NewServer.db.GormDB().AutoMigrate(m.Model); err != nil {
+			log.Fatalf("failed to migrate %s: %v", m.Name, err)
+		} else {
+			log.Printf("%s migrated successfully", m.Name)
+		}
 	}
 
+	// Erase accounts whose owners deleted them once the grace period is over
+	go models.RunAccountPurge(context.Background(), NewServer.db, config.Duration("ACCOUNT_PURGE_INTERVAL", time.Hour))
+
 	// Declare Server config
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", NewServer.port),
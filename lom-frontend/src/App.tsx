@@ -6,6 +6,10 @@ import { Header } from './components/Header';
 import { LoginForm } from './components/LoginForm';
 import { RegisterForm } from './components/RegisterForm';
 import { Home } from './components/Home';
+import { VerifyEmail } from './components/VerifyEmail';
+import { ConfirmEmailChange } from './components/ConfirmEmailChange';
+import { ResetPassword } from './components/ResetPassword';
+import { UnlockAccount } from './components/UnlockAccount';
 import { ProtectedRoute } from './components/ProtectedRoute';
 
 function App() {
@@ -33,6 +37,10 @@ function App() {
             <Route path="/" element={<Home />} />
             <Route path="/login" element={<LoginForm />} />
             <Route path="/register" element={<RegisterForm />} />
+            <Route path="/verify-email" element={<VerifyEmail />} />
+            <Route path="/confirm-email" element={<ConfirmEmailChange />} />
+            <Route path="/reset-password" element={<ResetPassword />} />
+            <Route path="/unlock-account" element={<UnlockAccount />} />
             <Route
               path="/protected"
               element={
@@ -0,0 +1,46 @@
+import React, { useEffect, useState } from 'react';
+import { Link, useSearchParams } from 'react-router-dom';
+import { authAPI } from '../services/api';
+import { useAuthStore } from '../store/authStore';
+
+export const ConfirmEmailChange: React.FC = () => {
+  const [searchParams] = useSearchParams();
+  const [status, setStatus] = useState<'pending' | 'changed' | 'failed'>('pending');
+  const { user, setUser } = useAuthStore();
+
+  useEffect(() => {
+    const token = searchParams.get('token');
+    if (!token) {
+      setStatus('failed');
+      return;
+    }
+
+    authAPI
+      .confirmEmailChange(token)
+      .then((changed) => {
+        if (user?.uuid === changed.uuid) {
+          setUser(changed);
+        }
+        setStatus('changed');
+      })
+      .catch(() => setStatus('failed'));
+    // Confirm once per token
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [searchParams]);
+
+  return (
+    <div className="container mx-auto px-4 py-8 text-center">
+      {status === 'pending' && (
+        <p className="text-gray-600 dark:text-gray-300">Confirming your new email…</p>
+      )}
+      {status === 'changed' && (
+        <p className="text-gray-900 dark:text-white">
+          Your email address has been changed. <Link to="/" className="text-blue-600">Continue</Link>
+        </p>
+      )}
+      {status === 'failed' && (
+        <p className="text-red-600">This confirmation link is invalid, has expired or was replaced by a newer one.</p>
+      )}
+    </div>
+  );
+};
@@ -1,32 +1,64 @@
 import React, { useEffect, useState } from 'react';
 import { useAuth } from '../hooks/useAuth';
-import { Link, useNavigate } from 'react-router-dom';
+import { OAuthButtons } from './OAuthButtons';
+import { authAPI } from '../services/api';
+import { Link, useLocation, useNavigate, useSearchParams } from 'react-router-dom';
+
+// Messages for the ?error= codes the OAuth and magic-link callbacks redirect with
+const callbackErrors: Record<string, string> = {
+  invalid_return_to: 'That sign-in link points somewhere we cannot send you back to.',
+  oauth_failed: 'Signing in with that provider failed. Please try again.',
+  email_in_use: 'An account with this email already exists. Sign in and link this provider from your profile.',
+  reauth_required: 'Sign in again to link this account.',
+  identity_linked: 'This login is already linked to another account.',
+  provider_in_use: 'A login from this provider is already linked.',
+  magic_link_invalid: 'This sign-in link is invalid or has expired.',
+  magic_link_other_browser: 'Open the sign-in link in the browser you requested it from.',
+  account_suspended: 'This account has been suspended. Contact support if you think this is a mistake.',
+  account_deleted: 'This account has been deleted.',
+  server_error: 'Something went wrong while signing you in.',
+};
 
 export const LoginForm: React.FC = () => {
   const [email, setEmail] = useState('');
   const [password, setPassword] = useState('');
-  const [error, setError] = useState('');
+  const [searchParams] = useSearchParams();
+  const errorCode = searchParams.get('error');
+  const [error, setError] = useState(errorCode ? callbackErrors[errorCode] ?? callbackErrors.server_error : '');
   const [isLoading, setIsLoading] = useState(false);
+  // OAuth and magic-link sign-ins for MFA accounts land here with ?mfa=required;
+  // their challenge is in an HttpOnly cookie, so mfaToken stays empty
+  const [mfaRequired, setMfaRequired] = useState(searchParams.get('mfa') === 'required');
+  const [mfaToken, setMfaToken] = useState('');
+  const [code, setCode] = useState('');
+  const [message, setMessage] = useState('');
 
-  const { login, oauthLogin } = useAuth();
+  const { login, verifyMFA, oauthLogin } = useAuth();
   const navigate = useNavigate();
+  const location = useLocation();
   const { isAuthenticated } = useAuth();
+  const from: string = location.state?.from ?? '/';
 
   useEffect(() => {
     if (isAuthenticated) {
-      navigate('/');
+      navigate(from);
     }
-  }, [isAuthenticated, navigate]);
+  }, [isAuthenticated, navigate, from]);
 
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
     setError('');
     setIsLoading(true);
 
-    const result = await login({ email, password });
-    
+    const result = mfaRequired
+      ? await verifyMFA(mfaToken, code)
+      : await login({ email, password });
+
     if (result.success) {
-      navigate('/');
+      navigate(from);
+    } else if ('mfaToken' in result && result.mfaToken) {
+      setMfaToken(result.mfaToken);
+      setMfaRequired(true);
     } else {
       setError(result.error);
     }
@@ -34,8 +66,23 @@ export const LoginForm: React.FC = () => {
     setIsLoading(false);
   };
 
+  const handleMagicLink = async () => {
+    setError('');
+    setMessage('');
+    if (!email) {
+      setError('Enter your email address first');
+      return;
+    }
+    try {
+      await authAPI.requestMagicLink(email);
+      setMessage('Check your email for a sign-in link');
+    } catch (err: any) {
+      setError(err.response?.data || 'Could not send a sign-in link');
+    }
+  };
+
   const handleOAuth = (provider: string) => {
-    oauthLogin(provider);
+    oauthLogin(provider, from);
   };
 
   return (
@@ -52,28 +99,62 @@ export const LoginForm: React.FC = () => {
               {error}
             </div>
           )}
-          
-          <div className="rounded-md shadow-sm -space-y-px">
-            <div>
-              <input
-                type="email"
-                required
-                className="appearance-none rounded-none relative block w-full px-3 py-2 border border-gray-300 dark:border-gray-600 placeholder-gray-500 dark:placeholder-gray-400 text-gray-900 dark:text-white dark:bg-gray-700 rounded-t-md focus:outline-none focus:ring-blue-500 focus:border-blue-500 focus:z-10 sm:text-sm"
-                placeholder="Email address"
-                value={email}
-                onChange={(e) => setEmail(e.target.value)}
-              />
+          {message && (
+            <div className="bg-green-100 border border-green-400 text-green-700 px-4 py-3 rounded">
+              {message}
             </div>
-            <div>
-              <input
-                type="password"
-                required
-                className="appearance-none rounded-none relative block w-full px-3 py-2 border border-gray-300 dark:border-gray-600 placeholder-gray-500 dark:placeholder-gray-400 text-gray-900 dark:text-white dark:bg-gray-700 rounded-b-md focus:outline-none focus:ring-blue-500 focus:border-blue-500 focus:z-10 sm:text-sm"
-                placeholder="Password"
-                value={password}
-                onChange={(e) => setPassword(e.target.value)}
-              />
+          )}
+          
+          {mfaRequired ? (
+            <input
+              type="text"
+              inputMode="numeric"
+              autoComplete="one-time-code"
+              required
+              className="appearance-none rounded-md relative block w-full px-3 py-2 border border-gray-300 dark:border-gray-600 placeholder-gray-500 dark:placeholder-gray-400 text-gray-900 dark:text-white dark:bg-gray-700 focus:outline-none focus:ring-blue-500 focus:border-blue-500 sm:text-sm"
+              placeholder="Authentication code"
+              value={code}
+              onChange={(e) => setCode(e.target.value)}
+            />
+          ) : (
+            <div className="rounded-md shadow-sm -space-y-px">
+              <div>
+                <input
+                  type="email"
+                  required
+                  className="appearance-none rounded-none relative block w-full px-3 py-2 border border-gray-300 dark:border-gray-600 placeholder-gray-500 dark:placeholder-gray-400 text-gray-900 dark:text-white dark:bg-gray-700 rounded-t-md focus:outline-none focus:ring-blue-500 focus:border-blue-500 focus:z-10 sm:text-sm"
+                  placeholder="Email address"
+                  value={email}
+                  onChange={(e) => setEmail(e.target.value)}
+                />
+              </div>
+              <div>
+                <input
+                  type="password"
+                  required
+                  className="appearance-none rounded-none relative block w-full px-3 py-2 border border-gray-300 dark:border-gray-600 placeholder-gray-500 dark:placeholder-gray-400 text-gray-900 dark:text-white dark:bg-gray-700 rounded-b-md focus:outline-none focus:ring-blue-500 focus:border-blue-500 focus:z-10 sm:text-sm"
+                  placeholder="Password"
+                  value={password}
+                  onChange={(e) => setPassword(e.target.value)}
+                />
+              </div>
             </div>
+          )}
+
+          <div className="text-sm flex justify-between">
+            <button
+              type="button"
+              onClick={handleMagicLink}
+              className="font-medium text-blue-600 hover:text-blue-500 dark:text-blue-400"
+            >
+              Email me a sign-in link
+            </button>
+            <Link
+              to="/reset-password"
+              className="font-medium text-blue-600 hover:text-blue-500 dark:text-blue-400"
+            >
+              Forgot your password?
+            </Link>
           </div>
 
           <div>
@@ -98,15 +179,7 @@ export const LoginForm: React.FC = () => {
               </div>
             </div>
 
-            <div className="mt-6 grid grid-cols-1 gap-3">
-              <button
-                type="button"
-                onClick={() => handleOAuth('google')}
-                className="w-full inline-flex justify-center py-2 px-4 border border-gray-300 dark:border-gray-600 rounded-md shadow-sm bg-white dark:bg-gray-700 text-sm font-medium text-gray-500 dark:text-gray-300 hover:bg-gray-50 dark:hover:bg-gray-600"
-              >
-                Sign in with Google
-              </button>
-            </div>
+            <OAuthButtons verb="Sign in" onSelect={handleOAuth} />
           </div>
 
           <div className="text-center">
@@ -0,0 +1,34 @@
+import React, { useEffect, useState } from 'react';
+import { authAPI } from '../services/api';
+import type { AuthProvider } from '../types';
+
+interface OAuthButtonsProps {
+  verb: string;
+  onSelect: (provider: string) => void;
+}
+
+export const OAuthButtons: React.FC<OAuthButtonsProps> = ({ verb, onSelect }) => {
+  const [providers, setProviders] = useState<AuthProvider[]>([]);
+
+  useEffect(() => {
+    authAPI
+      .getProviders()
+      .then(setProviders)
+      .catch(() => setProviders([]));
+  }, []);
+
+  return (
+    <div className="mt-6 grid grid-cols-1 gap-3">
+      {providers.map((provider) => (
+        <button
+          key={provider.name}
+          type="button"
+          onClick={() => onSelect(provider.name)}
+          className="w-full inline-flex justify-center py-2 px-4 border border-gray-300 dark:border-gray-600 rounded-md shadow-sm bg-white dark:bg-gray-700 text-sm font-medium text-gray-500 dark:text-gray-300 hover:bg-gray-50 dark:hover:bg-gray-600"
+        >
+          {verb} with {provider.display_name}
+        </button>
+      ))}
+    </div>
+  );
+};
@@ -1,5 +1,6 @@
 import React, { useEffect, useState } from 'react';
 import { useAuth } from '../hooks/useAuth';
+import { OAuthButtons } from './OAuthButtons';
 import { Link, useNavigate } from 'react-router-dom';
 
 export const RegisterForm: React.FC = () => {
@@ -121,15 +122,7 @@ export const RegisterForm: React.FC = () => {
               </div>
             </div>
 
-            <div className="mt-6 grid grid-cols-1 gap-3">
-              <button
-                type="button"
-                onClick={() => handleOAuth('google')}
-                className="w-full inline-flex justify-center py-2 px-4 border border-gray-300 dark:border-gray-600 rounded-md shadow-sm bg-white dark:bg-gray-700 text-sm font-medium text-gray-500 dark:text-gray-300 hover:bg-gray-50 dark:hover:bg-gray-600"
-              >
-                Sign up with Google
-              </button>
-            </div>
+            <OAuthButtons verb="Sign up" onSelect={handleOAuth} />
           </div>
 
           <div className="text-center">
@@ -0,0 +1,73 @@
+import React, { useState } from 'react';
+import { Link, useSearchParams } from 'react-router-dom';
+import { authAPI } from '../services/api';
+
+const inputClass =
+  'appearance-none rounded-md relative block w-full px-3 py-2 border border-gray-300 dark:border-gray-600 placeholder-gray-500 dark:placeholder-gray-400 text-gray-900 dark:text-white dark:bg-gray-700 focus:outline-none focus:ring-blue-500 focus:border-blue-500 sm:text-sm';
+
+// Without a token this asks for the account email; with one it sets the new password.
+export const ResetPassword: React.FC = () => {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token');
+  const [value, setValue] = useState('');
+  const [message, setMessage] = useState('');
+  const [error, setError] = useState('');
+  const [isLoading, setIsLoading] = useState(false);
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError('');
+    setIsLoading(true);
+    try {
+      if (token) {
+        await authAPI.resetPassword(token, value);
+        setMessage('Your password has been reset.');
+      } else {
+        await authAPI.forgotPassword(value);
+        setMessage('If an account exists for that email, a reset link has been sent.');
+      }
+    } catch (err: any) {
+      setError(err.response?.data || 'Something went wrong');
+    }
+    setIsLoading(false);
+  };
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gray-50 dark:bg-gray-900 py-12 px-4 sm:px-6 lg:px-8">
+      <div className="max-w-md w-full space-y-8">
+        <h2 className="mt-6 text-center text-3xl font-extrabold text-gray-900 dark:text-white">
+          {token ? 'Choose a new password' : 'Reset your password'}
+        </h2>
+        {message ? (
+          <p className="text-center text-gray-600 dark:text-gray-300">
+            {message} <Link to="/login" className="text-blue-600">Sign in</Link>
+          </p>
+        ) : (
+          <form className="mt-8 space-y-6" onSubmit={handleSubmit}>
+            {error && (
+              <div className="bg-red-100 border border-red-400 text-red-700 px-4 py-3 rounded">
+                {error}
+              </div>
+            )}
+            <input
+              type={token ? 'password' : 'email'}
+              required
+              minLength={token ? 8 : undefined}
+              className={inputClass}
+              placeholder={token ? 'New password' : 'Email address'}
+              value={value}
+              onChange={(e) => setValue(e.target.value)}
+            />
+            <button
+              type="submit"
+              disabled={isLoading}
+              className="group relative w-full flex justify-center py-2 px-4 border border-transparent text-sm font-medium rounded-md text-white bg-blue-600 hover:bg-blue-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-blue-500 disabled:opacity-50"
+            >
+              {token ? 'Set password' : 'Send reset link'}
+            </button>
+          </form>
+        )}
+      </div>
+    </div>
+  );
+};
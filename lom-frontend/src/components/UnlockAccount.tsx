@@ -0,0 +1,37 @@
+import React, { useEffect, useState } from 'react';
+import { Link, useSearchParams } from 'react-router-dom';
+import { authAPI } from '../services/api';
+
+export const UnlockAccount: React.FC = () => {
+  const [searchParams] = useSearchParams();
+  const [status, setStatus] = useState<'pending' | 'unlocked' | 'failed'>('pending');
+
+  useEffect(() => {
+    const token = searchParams.get('token');
+    if (!token) {
+      setStatus('failed');
+      return;
+    }
+
+    authAPI
+      .unlockAccount(token)
+      .then(() => setStatus('unlocked'))
+      .catch(() => setStatus('failed'));
+  }, [searchParams]);
+
+  return (
+    <div className="container mx-auto px-4 py-8 text-center">
+      {status === 'pending' && (
+        <p className="text-gray-600 dark:text-gray-300">Unlocking your account…</p>
+      )}
+      {status === 'unlocked' && (
+        <p className="text-gray-900 dark:text-white">
+          Your account is unlocked. <Link to="/login" className="text-blue-600">Sign in</Link>
+        </p>
+      )}
+      {status === 'failed' && (
+        <p className="text-red-600">This unlock link is invalid or has expired.</p>
+      )}
+    </div>
+  );
+};
@@ -0,0 +1,46 @@
+import React, { useEffect, useState } from 'react';
+import { Link, useSearchParams } from 'react-router-dom';
+import { authAPI } from '../services/api';
+import { useAuthStore } from '../store/authStore';
+
+export const VerifyEmail: React.FC = () => {
+  const [searchParams] = useSearchParams();
+  const [status, setStatus] = useState<'pending' | 'verified' | 'failed'>('pending');
+  const { user, setUser } = useAuthStore();
+
+  useEffect(() => {
+    const token = searchParams.get('token');
+    if (!token) {
+      setStatus('failed');
+      return;
+    }
+
+    authAPI
+      .verifyEmail(token)
+      .then((verified) => {
+        if (user?.uuid === verified.uuid) {
+          setUser(verified);
+        }
+        setStatus('verified');
+      })
+      .catch(() => setStatus('failed'));
+    // Verify once per token
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [searchParams]);
+
+  return (
+    <div className="container mx-auto px-4 py-8 text-center">
+      {status === 'pending' && (
+        <p className="text-gray-600 dark:text-gray-300">Verifying your email…</p>
+      )}
+      {status === 'verified' && (
+        <p className="text-gray-900 dark:text-white">
+          Your email address is verified. <Link to="/" className="text-blue-600">Continue</Link>
+        </p>
+      )}
+      {status === 'failed' && (
+        <p className="text-red-600">This verification link is invalid or has expired.</p>
+      )}
+    </div>
+  );
+};
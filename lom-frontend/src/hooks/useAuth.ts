@@ -24,6 +24,9 @@ export const useAuth = () => {
   const login = async (credentials: LoginRequest) => {
     try {
       const response = await authAPI.login(credentials);
+      if ('mfa_required' in response) {
+        return { success: false, mfaToken: response.mfa_token, error: '' };
+      }
       setUser(response.user);
       return { success: true };
     } catch (error: any) {
@@ -34,6 +37,19 @@ export const useAuth = () => {
     }
   };
 
+  const verifyMFA = async (mfaToken: string, code: string) => {
+    try {
+      const response = await authAPI.verifyMFA(mfaToken, code);
+      setUser(response.user);
+      return { success: true };
+    } catch (error: any) {
+      return {
+        success: false,
+        error: error.response?.data || 'Invalid code'
+      };
+    }
+  };
+
   const register = async (userData: RegisterRequest) => {
     try {
       const response = await authAPI.register(userData);
@@ -57,8 +73,8 @@ export const useAuth = () => {
     }
   };
 
-  const oauthLogin = (provider: string) => {
-    authAPI.oauthLogin(provider);
+  const oauthLogin = (provider: string, returnTo?: string) => {
+    authAPI.oauthLogin(provider, returnTo);
   };
 
   return {
@@ -66,6 +82,7 @@ export const useAuth = () => {
     isAuthenticated,
     isLoading,
     login,
+    verifyMFA,
     register,
     logout,
     oauthLogin,
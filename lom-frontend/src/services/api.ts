@@ -1,5 +1,5 @@
 import axios from 'axios';
-import type { LoginRequest, RegisterRequest, AuthResponse, User } from '../types';
+import type { LoginRequest, RegisterRequest, UpdateProfileRequest, AuditEvent, AuthResponse, AuthProvider, MFAChallenge, User } from '../types';
 
 const API_BASE_URL = 'http://localhost:8082';
 
@@ -8,9 +8,70 @@ const api = axios.create({
   withCredentials: true,
 });
 
+// Cookie-authenticated writes need the CSRF token from /auth/csrf in X-CSRF-Token.
+let csrfToken: Promise<string> | null = null;
+
+const fetchCSRFToken = () =>
+  api.get<{ csrf_token: string }>('/auth/csrf').then((response) => response.data.csrf_token);
+
+api.interceptors.request.use(async (config) => {
+  const method = (config.method ?? 'get').toLowerCase();
+  if (['post', 'put', 'patch', 'delete'].includes(method)) {
+    csrfToken ??= fetchCSRFToken().catch((error) => {
+      csrfToken = null;
+      throw error;
+    });
+    config.headers.set('X-CSRF-Token', await csrfToken);
+  }
+  return config;
+});
+
+// Access tokens are short-lived: on a 401, rotate the refresh cookie once and retry.
+let refreshing: Promise<unknown> | null = null;
+
+api.interceptors.response.use(
+  (response) => response,
+  async (error) => {
+    const original = error.config;
+    const url: string = original?.url ?? '';
+
+    // The token is bound to the session, so it stops validating after signing in or out
+    // (or if the server's CSRF secret changed): fetch a new one once
+    const csrfRejected =
+      error.response?.status === 403 &&
+      String(error.response.data).startsWith('Missing or invalid CSRF token');
+    if (csrfRejected && !original._csrfRetried) {
+      original._csrfRetried = true;
+      csrfToken = null;
+      return api(original);
+    }
+
+    if (
+      error.response?.status !== 401 ||
+      original._retried ||
+      url.startsWith('/auth/refresh') ||
+      url.startsWith('/auth/login')
+    ) {
+      return Promise.reject(error);
+    }
+
+    original._retried = true;
+    refreshing ??= api.post('/auth/refresh').finally(() => {
+      refreshing = null;
+    });
+    await refreshing;
+    return api(original);
+  },
+);
+
 export const authAPI = {
-  login: async (credentials: LoginRequest): Promise<AuthResponse> => {
-    const response = await api.post<AuthResponse>('/auth/login', credentials);
+  login: async (credentials: LoginRequest): Promise<AuthResponse | MFAChallenge> => {
+    const response = await api.post<AuthResponse | MFAChallenge>('/auth/login', credentials);
+    return response.data;
+  },
+
+  verifyMFA: async (mfaToken: string, code: string): Promise<AuthResponse> => {
+    const response = await api.post<AuthResponse>('/auth/mfa/verify', { mfa_token: mfaToken, code });
     return response.data;
   },
 
@@ -23,14 +84,82 @@ export const authAPI = {
     await api.post('/auth/log-out');
   },
 
+  verifyEmail: async (token: string): Promise<User> => {
+    const response = await api.post<{ user: User }>('/auth/verify-email', { token });
+    return response.data.user;
+  },
+
+  resendVerification: async (): Promise<void> => {
+    await api.post('/auth/verify-email/resend');
+  },
+
+  forgotPassword: async (email: string): Promise<void> => {
+    await api.post('/auth/password/forgot', { email });
+  },
+
+  resetPassword: async (token: string, password: string): Promise<void> => {
+    await api.post('/auth/password/reset', { token, password });
+  },
+
+  unlockAccount: async (token: string): Promise<void> => {
+    await api.post('/auth/unlock', { token });
+  },
+
+  requestMagicLink: async (email: string): Promise<void> => {
+    await api.post('/auth/magic-link', { email });
+  },
+
   getCurrentUser: async (): Promise<User> => {
     const response = await api.get<User>('/auth/me');
     return response.data;
   },
 
+  // A new email lands in pending_email until the link sent to it is opened
+  updateProfile: async (changes: UpdateProfileRequest): Promise<User> => {
+    const response = await api.patch<{ user: User }>('/auth/me', changes);
+    return response.data.user;
+  },
+
+  confirmEmailChange: async (token: string): Promise<User> => {
+    const response = await api.post<{ user: User }>('/auth/email/confirm', { token });
+    return response.data.user;
+  },
+
+  // currentPassword is omitted when setting a first password after a fresh sign-in
+  changePassword: async (newPassword: string, currentPassword?: string): Promise<void> => {
+    await api.post('/auth/me/password', {
+      current_password: currentPassword,
+      new_password: newPassword,
+    });
+  },
+
+  deleteAccount: async (currentPassword?: string): Promise<void> => {
+    await api.delete('/auth/me', { data: { current_password: currentPassword } });
+  },
+
+  // Opens the download of everything stored about the user
+  exportAccount: (format: 'json' | 'zip' = 'json') => {
+    window.location.href = `${API_BASE_URL}/auth/me/export?format=${format}`;
+  },
+
+  // Pass the returned nextCursor back in to load older events
+  getActivity: async (cursor?: string): Promise<{ events: AuditEvent[]; nextCursor: string }> => {
+    const response = await api.get<{ events: AuditEvent[]; next_cursor: string }>('/auth/me/activity', {
+      params: cursor ? { cursor } : undefined,
+    });
+    return { events: response.data.events, nextCursor: response.data.next_cursor };
+  },
+
   // OAuth endpoints
-  oauthLogin: (provider: string) => {
-    window.location.href = `${API_BASE_URL}/auth/${provider}`;
+  getProviders: async (): Promise<AuthProvider[]> => {
+    const response = await api.get<{ providers: AuthProvider[] }>('/auth/providers');
+    return response.data.providers;
+  },
+
+  // returnTo is a frontend path the callback redirects back to after login
+  oauthLogin: (provider: string, returnTo?: string) => {
+    const query = returnTo ? `?return_to=${encodeURIComponent(returnTo)}` : '';
+    window.location.href = `${API_BASE_URL}/auth/${provider}${query}`;
   },
 };
 
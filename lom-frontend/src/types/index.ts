@@ -3,15 +3,25 @@ export interface User {
   email: string;
   nickname: string;
   provider: string;
+  is_verified: boolean;
+  pending_email?: string;
   created_at: string;
   updated_at: string;
 }
 
 export interface AuthResponse {
   token: string;
+  refresh_token?: string;
+  expires_in?: number;
   user: User;
 }
 
+export interface MFAChallenge {
+  mfa_required: true;
+  mfa_token: string;
+  expires_in: number;
+}
+
 export interface LoginRequest {
   email: string;
   password: string;
@@ -21,4 +31,27 @@ export interface RegisterRequest {
   email: string;
   password: string;
   nickname: string;
-}
\ No newline at end of file
+}
+
+export interface UpdateProfileRequest {
+  nickname?: string;
+  email?: string;
+  current_password?: string;
+}
+
+export interface AuditEvent {
+  id: number;
+  type: string;
+  ip: string;
+  user_agent: string;
+  session_id?: string;
+  details?: Record<string, string>;
+  created_at: string;
+}
+
+export interface AuthProvider {
+  name: string;
+  display_name: string;
+  type: string;
+  login_url: string;
+}